@@ -43,13 +43,14 @@ func runWeb() error {
 
 func printWeb(models []toolapp.ModelDetail) {
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
-	fmt.Fprintln(w, "ID\tOWNED BY\tMODEL FAMILY\tSIZE\tEXPIRES\tSESSIONS")
+	fmt.Fprintln(w, "ID\tOWNED BY\tMODEL FAMILY\tSIZE\tSHARED WEIGHTS\tINSTANCES\tEXPIRES\tSESSIONS")
 
 	for _, model := range models {
 		size := formatSize(model.Size)
+		weights := formatSize(int64(model.SharedWeights))
 		expiresIn := time.Until(model.ExpiresAt).Truncate(time.Second)
 
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\n", model.ID, model.OwnedBy, model.ModelFamily, size, expiresIn, model.ActiveStreams)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%d\n", model.ID, model.OwnedBy, model.ModelFamily, size, weights, model.Instances, expiresIn, model.ActiveStreams)
 	}
 
 	w.Flush()
@@ -82,6 +82,7 @@ func printWeb(mi toolapp.ModelInfoResponse) {
 	fmt.Printf("OwnedBy:     %s\n", mi.OwnedBy)
 	fmt.Printf("Desc:        %s\n", mi.Desc)
 	fmt.Printf("Size:        %.2f MiB\n", float64(mi.Size)/(1024*1024))
+	fmt.Printf("Instances:   %d\n", mi.Instances)
 	fmt.Printf("HasProj:     %t\n", mi.HasProjection)
 	fmt.Printf("HasEncoder:  %t\n", mi.HasEncoder)
 	fmt.Printf("HasDecoder:  %t\n", mi.HasDecoder)
@@ -101,6 +102,7 @@ func printLocal(mi models.Info, details model.ModelInfo) {
 	fmt.Printf("OwnedBy:     %s\n", mi.OwnedBy)
 	fmt.Printf("Desc:        %s\n", details.Desc)
 	fmt.Printf("Size:        %.2f MiB\n", float64(details.Size)/(1024*1024))
+	fmt.Printf("Instances:   %d\n", details.Instances)
 	fmt.Printf("HasProj:     %t\n", details.HasProjection)
 	fmt.Printf("HasEncoder:  %t\n", details.HasEncoder)
 	fmt.Printf("HasDecoder:  %t\n", details.HasDecoder)
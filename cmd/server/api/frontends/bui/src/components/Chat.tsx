@@ -174,7 +174,14 @@ export default function Chat() {
           currentReasoning += choice.delta.reasoning;
         }
         if (choice?.delta?.tool_calls && choice.delta.tool_calls.length > 0) {
-          currentToolCalls = [...currentToolCalls, ...choice.delta.tool_calls];
+          const merged = [...currentToolCalls];
+          for (const tc of choice.delta.tool_calls) {
+            const existing = merged[tc.index];
+            merged[tc.index] = existing
+              ? { ...existing, function: { ...existing.function, arguments: existing.function.arguments + (tc.function.arguments ?? '') } }
+              : { id: tc.id ?? '', index: tc.index, type: tc.type ?? 'function', function: { name: tc.function.name ?? '', arguments: tc.function.arguments ?? '' } };
+          }
+          currentToolCalls = merged;
         }
         if (data.usage) {
           lastUsage = data.usage;
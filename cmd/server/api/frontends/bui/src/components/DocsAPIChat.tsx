@@ -84,6 +84,12 @@ export default function DocsAPIChat() {
                     <td>No</td>
                     <td>Array of tool definitions for function calling. See Tool Definitions section below.</td>
                   </tr>
+                  <tr>
+                    <td><code>tool_choice</code></td>
+                    <td><code>string | object</code></td>
+                    <td>No</td>
+                    <td>How the model uses tools: auto, none hides the tools, required forces a tool call, or &#123;type: function, function: &#123;name&#125;&#125; forces a call to that function with arguments matching its parameters schema (default: auto)</td>
+                  </tr>
                   <tr>
                     <td><code>temperature</code></td>
                     <td><code>float32</code></td>
@@ -126,6 +132,90 @@ export default function DocsAPIChat() {
                     <td>No</td>
                     <td>Reasoning level for GPT models (default: medium)</td>
                   </tr>
+                  <tr>
+                    <td><code>max_reasoning_tokens</code></td>
+                    <td><code>int</code></td>
+                    <td>No</td>
+                    <td>Thinking budget, once the model reasoned for this many tokens it is moved on to the answer. Works with response_format and tool_choice but can't be used with grammar (default: 0, no budget)</td>
+                  </tr>
+                  <tr>
+                    <td><code>seed</code></td>
+                    <td><code>int</code></td>
+                    <td>No</td>
+                    <td>Random seed for reproducible sampling (default: random)</td>
+                  </tr>
+                  <tr>
+                    <td><code>presence_penalty</code></td>
+                    <td><code>float32</code></td>
+                    <td>No</td>
+                    <td>Penalize tokens that already appear in the output, between -2.0 and 2.0 (default: 0.0)</td>
+                  </tr>
+                  <tr>
+                    <td><code>frequency_penalty</code></td>
+                    <td><code>float32</code></td>
+                    <td>No</td>
+                    <td>Penalize tokens by how often they appear in the output, between -2.0 and 2.0 (default: 0.0)</td>
+                  </tr>
+                  <tr>
+                    <td><code>logit_bias</code></td>
+                    <td><code>object</code></td>
+                    <td>No</td>
+                    <td>Map of token ids or single-token text to a bias between -100 and 100, where -100 bans the token. Text is matched with and without a leading space, text of several tokens is rejected (default: none)</td>
+                  </tr>
+                  <tr>
+                    <td><code>stop</code></td>
+                    <td><code>string | array</code></td>
+                    <td>No</td>
+                    <td>Up to 4 sequences where generation stops, not included in the output (default: none)</td>
+                  </tr>
+                  <tr>
+                    <td><code>logprobs</code></td>
+                    <td><code>boolean</code></td>
+                    <td>No</td>
+                    <td>Return the log probability of each output token (default: false)</td>
+                  </tr>
+                  <tr>
+                    <td><code>top_logprobs</code></td>
+                    <td><code>int</code></td>
+                    <td>No</td>
+                    <td>Number of most likely tokens (0-20) to return at each position, implies logprobs (default: 0)</td>
+                  </tr>
+                  <tr>
+                    <td><code>response_format</code></td>
+                    <td><code>object</code></td>
+                    <td>No</td>
+                    <td>Constrain output to JSON: &#123;type: text | json_object | json_schema, json_schema: &#123;name, schema&#125;&#125; (default: text)</td>
+                  </tr>
+                  <tr>
+                    <td><code>grammar</code></td>
+                    <td><code>string</code></td>
+                    <td>No</td>
+                    <td>GBNF grammar with a root rule that constrains the output (default: none)</td>
+                  </tr>
+                  <tr>
+                    <td><code>priority</code></td>
+                    <td><code>string</code></td>
+                    <td>No</td>
+                    <td>Scheduling class: low, normal or high. Waiting requests start by priority, then in turns across subjects, and can pause running lower priority requests when all slots are busy (default: normal)</td>
+                  </tr>
+                  <tr>
+                    <td><code>tool_call_repair</code></td>
+                    <td><code>boolean</code></td>
+                    <td>No</td>
+                    <td>Ask the model once more when a tool call isn't valid JSON or doesn't match the parameters schema of the tool, the first attempt is held back until its tool calls are checked (default: false)</td>
+                  </tr>
+                  <tr>
+                    <td><code>continue_final_message</code></td>
+                    <td><code>boolean</code></td>
+                    <td>No</td>
+                    <td>Continue the final message when it's from the assistant, the response holds only the text that follows it (default: false)</td>
+                  </tr>
+                  <tr>
+                    <td><code>context_overflow</code></td>
+                    <td><code>string</code></td>
+                    <td>No</td>
+                    <td>When the request doesn't fit the context window: error, truncate_middle or shift (default: model config, error)</td>
+                  </tr>
                 </tbody>
               </table>
               <h5>Response</h5>
@@ -253,6 +343,35 @@ export default function DocsAPIChat() {
         }
       }
     ]
+  }'`}</code>
+              </pre>
+              <p className="example-label"><strong>Force a call to a function with tool_choice, the arguments are constrained to its parameters schema:</strong></p>
+              <pre className="code-block">
+                <code>{`curl -X POST http://localhost:8080/v1/chat/completions \\
+  -H "Authorization: Bearer $KRONK_TOKEN" \\
+  -H "Content-Type: application/json" \\
+  -d '{
+    "model": "qwen3-8b-q8_0",
+    "messages": [
+      {"role": "user", "content": "Tokyo please"}
+    ],
+    "tools": [
+      {
+        "type": "function",
+        "function": {
+          "name": "get_weather",
+          "description": "Get the current weather for a location",
+          "parameters": {
+            "type": "object",
+            "properties": {
+              "location": {"type": "string"}
+            },
+            "required": ["location"]
+          }
+        }
+      }
+    ],
+    "tool_choice": {"type": "function", "function": {"name": "get_weather"}}
   }'`}</code>
               </pre>
             </div>
@@ -264,7 +383,7 @@ export default function DocsAPIChat() {
 
             <div className="doc-section" id="response-formats--non-streaming-response">
               <h4>Non-Streaming Response</h4>
-              <p className="doc-description">For non-streaming requests (stream=false or omitted), the response uses the 'message' field in each choice. The 'delta' field is empty.</p>
+              <p className="doc-description">For non-streaming requests (stream=false or omitted), the response uses the 'message' field in each choice. The 'delta' field is empty. The finish_reason is 'stop', 'tool_calls', or 'length' when max_tokens or the context window cut the response short.</p>
               <h5>Example</h5>
               <pre className="code-block">
                 <code>{`{
@@ -272,6 +391,7 @@ export default function DocsAPIChat() {
   "object": "chat.completion",
   "created": 1234567890,
   "model": "qwen3-8b-q8_0",
+  "system_fingerprint": "fp_3f2a9c1b7d4e",
   "choices": [
     {
       "index": 0,
@@ -285,6 +405,7 @@ export default function DocsAPIChat() {
   ],
   "usage": {
     "prompt_tokens": 25,
+    "cached_tokens": 0,
     "reasoning_tokens": 0,
     "completion_tokens": 12,
     "output_tokens": 12,
@@ -92,9 +92,9 @@ export default function DocsAPIResponses() {
                   </tr>
                   <tr>
                     <td><code>tool_choice</code></td>
-                    <td><code>string</code></td>
+                    <td><code>string | object</code></td>
                     <td>No</td>
-                    <td>How the model should use tools: auto, none, or required</td>
+                    <td>How the model uses tools: auto, none hides the tools, required forces a tool call, or &#123;type: function, name&#125; forces a call to that function with arguments matching its parameters schema (default: auto)</td>
                   </tr>
                   <tr>
                     <td><code>parallel_tool_calls</code></td>
@@ -112,7 +112,13 @@ export default function DocsAPIResponses() {
                     <td><code>truncation</code></td>
                     <td><code>string</code></td>
                     <td>No</td>
-                    <td>Truncation strategy: auto or disabled (default: disabled)</td>
+                    <td>Truncation strategy: auto drops the oldest turns when the input doesn't fit the context window, or disabled (default: disabled)</td>
+                  </tr>
+                  <tr>
+                    <td><code>text</code></td>
+                    <td><code>object</code></td>
+                    <td>No</td>
+                    <td>Output format: &#123;format: &#123;type: text | json_object | json_schema, name, schema, strict&#125;&#125; (default: text)</td>
                   </tr>
                   <tr>
                     <td><code>temperature</code></td>
@@ -156,6 +162,90 @@ export default function DocsAPIResponses() {
                     <td>No</td>
                     <td>Reasoning level for GPT models (default: medium)</td>
                   </tr>
+                  <tr>
+                    <td><code>max_reasoning_tokens</code></td>
+                    <td><code>int</code></td>
+                    <td>No</td>
+                    <td>Thinking budget, once the model reasoned for this many tokens it is moved on to the answer. Works with response_format and tool_choice but can't be used with grammar (default: 0, no budget)</td>
+                  </tr>
+                  <tr>
+                    <td><code>seed</code></td>
+                    <td><code>int</code></td>
+                    <td>No</td>
+                    <td>Random seed for reproducible sampling (default: random)</td>
+                  </tr>
+                  <tr>
+                    <td><code>presence_penalty</code></td>
+                    <td><code>float32</code></td>
+                    <td>No</td>
+                    <td>Penalize tokens that already appear in the output, between -2.0 and 2.0 (default: 0.0)</td>
+                  </tr>
+                  <tr>
+                    <td><code>frequency_penalty</code></td>
+                    <td><code>float32</code></td>
+                    <td>No</td>
+                    <td>Penalize tokens by how often they appear in the output, between -2.0 and 2.0 (default: 0.0)</td>
+                  </tr>
+                  <tr>
+                    <td><code>logit_bias</code></td>
+                    <td><code>object</code></td>
+                    <td>No</td>
+                    <td>Map of token ids or single-token text to a bias between -100 and 100, where -100 bans the token. Text is matched with and without a leading space, text of several tokens is rejected (default: none)</td>
+                  </tr>
+                  <tr>
+                    <td><code>stop</code></td>
+                    <td><code>string | array</code></td>
+                    <td>No</td>
+                    <td>Up to 4 sequences where generation stops, not included in the output (default: none)</td>
+                  </tr>
+                  <tr>
+                    <td><code>logprobs</code></td>
+                    <td><code>boolean</code></td>
+                    <td>No</td>
+                    <td>Return the log probability of each output token (default: false)</td>
+                  </tr>
+                  <tr>
+                    <td><code>top_logprobs</code></td>
+                    <td><code>int</code></td>
+                    <td>No</td>
+                    <td>Number of most likely tokens (0-20) to return at each position, implies logprobs (default: 0)</td>
+                  </tr>
+                  <tr>
+                    <td><code>response_format</code></td>
+                    <td><code>object</code></td>
+                    <td>No</td>
+                    <td>Constrain output to JSON: &#123;type: text | json_object | json_schema, json_schema: &#123;name, schema&#125;&#125; (default: text)</td>
+                  </tr>
+                  <tr>
+                    <td><code>grammar</code></td>
+                    <td><code>string</code></td>
+                    <td>No</td>
+                    <td>GBNF grammar with a root rule that constrains the output (default: none)</td>
+                  </tr>
+                  <tr>
+                    <td><code>priority</code></td>
+                    <td><code>string</code></td>
+                    <td>No</td>
+                    <td>Scheduling class: low, normal or high. Waiting requests start by priority, then in turns across subjects, and can pause running lower priority requests when all slots are busy (default: normal)</td>
+                  </tr>
+                  <tr>
+                    <td><code>tool_call_repair</code></td>
+                    <td><code>boolean</code></td>
+                    <td>No</td>
+                    <td>Ask the model once more when a tool call isn't valid JSON or doesn't match the parameters schema of the tool, the first attempt is held back until its tool calls are checked (default: false)</td>
+                  </tr>
+                  <tr>
+                    <td><code>continue_final_message</code></td>
+                    <td><code>boolean</code></td>
+                    <td>No</td>
+                    <td>Continue the final message when it's from the assistant, the response holds only the text that follows it (default: false)</td>
+                  </tr>
+                  <tr>
+                    <td><code>context_overflow</code></td>
+                    <td><code>string</code></td>
+                    <td>No</td>
+                    <td>When the request doesn't fit the context window: error, truncate_middle or shift (default: model config, error)</td>
+                  </tr>
                 </tbody>
               </table>
               <h5>Response</h5>
@@ -258,7 +348,7 @@ export default function DocsAPIResponses() {
 
             <div className="doc-section" id="response-format--streaming-events">
               <h4>Streaming Events</h4>
-              <p className="doc-description">When stream=true, the API returns Server-Sent Events with different event types.</p>
+              <p className="doc-description">When stream=true, the API returns Server-Sent Events with different event types. A response cut short by the output limit ends with a response.incomplete event, a status of 'incomplete' and incomplete_details.reason set to 'max_output_tokens'.</p>
               <h5>Example</h5>
               <pre className="code-block">
                 <code>{`event: response.created
@@ -300,6 +390,25 @@ data: {"type":"response.completed","response":{...}}`}</code>
 }`}</code>
               </pre>
             </div>
+
+            <div className="doc-section" id="response-format--streaming-function-calls">
+              <h4>Streaming Function Calls</h4>
+              <p className="doc-description">When stream=true, a function call is streamed while the model writes it. The function_call item is added with the name first, and the arguments follow in response.function_call_arguments.delta events.</p>
+              <h5>Example</h5>
+              <pre className="code-block">
+                <code>{`event: response.output_item.added
+data: {"type":"response.output_item.added","item":{"type":"function_call","id":"fc_abc123","call_id":"call_xyz789","name":"get_weather","status":"in_progress"}}
+
+event: response.function_call_arguments.delta
+data: {"type":"response.function_call_arguments.delta","item_id":"fc_abc123","delta":"{\\"location\\":"}
+
+event: response.function_call_arguments.delta
+data: {"type":"response.function_call_arguments.delta","item_id":"fc_abc123","delta":"\\"London\\"}"}
+
+event: response.function_call_arguments.done
+data: {"type":"response.function_call_arguments.done","item_id":"fc_abc123","name":"get_weather","arguments":"{\\"location\\":\\"London\\"}"}`}</code>
+              </pre>
+            </div>
           </div>
         </div>
 
@@ -320,6 +429,7 @@ data: {"type":"response.completed","response":{...}}`}</code>
                 <li><a href="#response-format--response-object">Response Object</a></li>
                 <li><a href="#response-format--streaming-events">Streaming Events</a></li>
                 <li><a href="#response-format--function-call-output">Function Call Output</a></li>
+                <li><a href="#response-format--streaming-function-calls">Streaming Function Calls</a></li>
               </ul>
             </div>
           </div>
@@ -169,7 +169,7 @@ export default function DocsAPITools() {
                 </tbody>
               </table>
               <h5>Response</h5>
-              <p>Returns a list of running models with id, owned_by, model_family, size, expires_at, and active_streams.</p>
+              <p>Returns a list of running models with id, owned_by, model_family, size, shared_weights, instances, expires_at, and active_streams.</p>
               <h5>Example</h5>
               <p className="example-label"><strong>List running models:</strong></p>
               <pre className="code-block">
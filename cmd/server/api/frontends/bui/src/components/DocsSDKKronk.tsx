@@ -145,10 +145,13 @@ export default function DocsSDKKronk() {
               <h4>ResponseFormatType</h4>
               <pre className="code-block">
                 <code>{`type ResponseFormatType struct {
-	Type string \`json:"type"\`
+	Type   string \`json:"type"\`
+	Name   string \`json:"name,omitempty"\`
+	Schema any    \`json:"schema,omitempty"\`
+	Strict *bool  \`json:"strict,omitempty"\`
 }`}</code>
               </pre>
-              <p className="doc-description">ResponseFormatType specifies the format type.</p>
+              <p className="doc-description">ResponseFormatType specifies the format type. Name, Schema and Strict are only set for the json_schema type.</p>
             </div>
 
             <div className="doc-section" id="type-responseoutputitem">
@@ -200,7 +203,7 @@ export default function DocsSDKKronk() {
 	Store            bool                   \`json:"store"\`
 	Temperature      float64                \`json:"temperature"\`
 	Text             ResponseTextFormat     \`json:"text"\`
-	ToolChoice       string                 \`json:"tool_choice"\`
+	ToolChoice       any                    \`json:"tool_choice"\`
 	Tools            []any                  \`json:"tools"\`
 	TopP             float64                \`json:"top_p"\`
 	Truncation       string                 \`json:"truncation"\`
@@ -274,7 +277,7 @@ export default function DocsSDKKronk() {
               <pre className="code-block">
                 <code>func (krn *Kronk) Chat(ctx context.Context, d model.D) (model.ChatResponse, error)</code>
               </pre>
-              <p className="doc-description">Chat provides support to interact with an inference model. For text models, NSeqMax controls parallel sequence processing within a single model instance. For vision/audio models, NSeqMax creates multiple model instances in a pool for concurrent request handling.</p>
+              <p className="doc-description">Chat provides support to interact with an inference model. NSeqMax controls parallel sequence processing within a single model instance, for text requests as well as requests with vision or audio content.</p>
             </div>
 
             <div className="doc-section" id="method-kronk-chatstreaming">
@@ -282,7 +285,7 @@ export default function DocsSDKKronk() {
               <pre className="code-block">
                 <code>func (krn *Kronk) ChatStreaming(ctx context.Context, d model.D) (&lt;-chan model.ChatResponse, error)</code>
               </pre>
-              <p className="doc-description">ChatStreaming provides support to interact with an inference model. For text models, NSeqMax controls parallel sequence processing within a single model instance. For vision/audio models, NSeqMax creates multiple model instances in a pool for concurrent request handling.</p>
+              <p className="doc-description">ChatStreaming provides support to interact with an inference model. NSeqMax controls parallel sequence processing within a single model instance, for text requests as well as requests with vision or audio content.</p>
             </div>
 
             <div className="doc-section" id="method-kronk-chatstreaminghttp">
@@ -290,7 +293,7 @@ export default function DocsSDKKronk() {
               <pre className="code-block">
                 <code>func (krn *Kronk) ChatStreamingHTTP(ctx context.Context, w http.ResponseWriter, d model.D) (model.ChatResponse, error)</code>
               </pre>
-              <p className="doc-description">ChatStreamingHTTP provides http handler support for a chat/completions call. For text models, NSeqMax controls parallel sequence processing within a single model instance. For vision/audio models, NSeqMax creates multiple model instances in a pool for concurrent request handling.</p>
+              <p className="doc-description">ChatStreamingHTTP provides http handler support for a chat/completions call. NSeqMax controls parallel sequence processing within a single model instance, for text requests as well as requests with vision or audio content.</p>
             </div>
 
             <div className="doc-section" id="method-kronk-embeddings">
@@ -298,7 +301,7 @@ export default function DocsSDKKronk() {
               <pre className="code-block">
                 <code>func (krn *Kronk) Embeddings(ctx context.Context, d model.D) (model.EmbedReponse, error)</code>
               </pre>
-              <p className="doc-description">Embeddings provides support to interact with an embedding model. Supported options in d: - input (string): the text to embed (required) - truncate (bool): if true, truncate input to fit context window (default: false) - truncate_direction (string): "right" (default) or "left" - dimensions (int): reduce output to first N dimensions (for Matryoshka models) Each model instance processes calls sequentially (llama.cpp only supports sequence 0 for embedding extraction). Use NSeqMax &gt; 1 to create multiple model instances for concurrent request handling. Batch multiple texts in the input parameter for better performance within a single request.</p>
+              <p className="doc-description">Embeddings provides support to interact with an embedding model. Supported options in d: - input (string): the text to embed (required) - truncate (bool): if true, truncate input to fit context window (default: false) - truncate_direction (string): "right" (default) or "left" - dimensions (int): reduce output to first N dimensions (for Matryoshka models) Each model instance processes calls sequentially and packs the texts of a call into batches across sequences. Use NSeqMax &gt; 1 to create multiple model instances for concurrent request handling. Batch multiple texts in a single request for better performance.</p>
             </div>
 
             <div className="doc-section" id="method-kronk-embeddingshttp">
@@ -309,6 +312,14 @@ export default function DocsSDKKronk() {
               <p className="doc-description">EmbeddingsHTTP provides http handler support for an embeddings call.</p>
             </div>
 
+            <div className="doc-section" id="method-kronk-healthy">
+              <h4>Kronk.Healthy</h4>
+              <pre className="code-block">
+                <code>func (krn *Kronk) Healthy() bool</code>
+              </pre>
+              <p className="doc-description">Healthy returns false when a model instance failed to decode too many batches in a row. An unhealthy Kronk should be unloaded and created again.</p>
+            </div>
+
             <div className="doc-section" id="method-kronk-modelconfig">
               <h4>Kronk.ModelConfig</h4>
               <pre className="code-block">
@@ -330,7 +341,7 @@ export default function DocsSDKKronk() {
               <pre className="code-block">
                 <code>func (krn *Kronk) Rerank(ctx context.Context, d model.D) (model.RerankResponse, error)</code>
               </pre>
-              <p className="doc-description">Rerank provides support to interact with a reranker model. Supported options in d: - query (string): the query to rank documents against (required) - documents ([]string): the documents to rank (required) - top_n (int): return only the top N results (optional, default: all) - return_documents (bool): include document text in results (default: false) Each model instance processes calls sequentially (llama.cpp only supports sequence 0 for rerank extraction). Use NSeqMax &gt; 1 to create multiple model instances for concurrent request handling. Batch multiple texts in the input parameter for better performance within a single request.</p>
+              <p className="doc-description">Rerank provides support to interact with a reranker model. Supported options in d: - query (string): the query to rank documents against (required) - documents ([]string): the documents to rank (required) - top_n (int): return only the top N results (optional, default: all) - return_documents (bool): include document text in results (default: false) Each model instance processes calls sequentially and packs the documents of a call into batches across sequences. Use NSeqMax &gt; 1 to create multiple model instances for concurrent request handling. Batch multiple documents in a single request for better performance.</p>
             </div>
 
             <div className="doc-section" id="method-kronk-rerankhttp">
@@ -346,7 +357,7 @@ export default function DocsSDKKronk() {
               <pre className="code-block">
                 <code>func (krn *Kronk) Response(ctx context.Context, d model.D) (ResponseResponse, error)</code>
               </pre>
-              <p className="doc-description">Response provides support to interact with an inference model. For text models, NSeqMax controls parallel sequence processing within a single model instance. For vision/audio models, NSeqMax creates multiple model instances in a pool for concurrent request handling.</p>
+              <p className="doc-description">Response provides support to interact with an inference model. NSeqMax controls parallel sequence processing within a single model instance, for text requests as well as requests with vision or audio content.</p>
             </div>
 
             <div className="doc-section" id="method-kronk-responsestreaming">
@@ -354,7 +365,7 @@ export default function DocsSDKKronk() {
               <pre className="code-block">
                 <code>func (krn *Kronk) ResponseStreaming(ctx context.Context, d model.D) (&lt;-chan ResponseStreamEvent, error)</code>
               </pre>
-              <p className="doc-description">ResponseStreaming provides streaming support for the Responses API. For text models, NSeqMax controls parallel sequence processing within a single model instance. For vision/audio models, NSeqMax creates multiple model instances in a pool for concurrent request handling.</p>
+              <p className="doc-description">ResponseStreaming provides streaming support for the Responses API. NSeqMax controls parallel sequence processing within a single model instance, for text requests as well as requests with vision or audio content.</p>
             </div>
 
             <div className="doc-section" id="method-kronk-responsestreaminghttp">
@@ -362,7 +373,7 @@ export default function DocsSDKKronk() {
               <pre className="code-block">
                 <code>func (krn *Kronk) ResponseStreamingHTTP(ctx context.Context, w http.ResponseWriter, d model.D) (ResponseResponse, error)</code>
               </pre>
-              <p className="doc-description">ResponseStreamingHTTP provides http handler support for a responses call. For text models, NSeqMax controls parallel sequence processing within a single model instance. For vision/audio models, NSeqMax creates multiple model instances in a pool for concurrent request handling.</p>
+              <p className="doc-description">ResponseStreamingHTTP provides http handler support for a responses call. NSeqMax controls parallel sequence processing within a single model instance, for text requests as well as requests with vision or audio content.</p>
             </div>
 
             <div className="doc-section" id="method-kronk-systeminfo">
@@ -480,6 +491,7 @@ export default function DocsSDKKronk() {
                 <li><a href="#method-kronk-chatstreaminghttp">Kronk.ChatStreamingHTTP</a></li>
                 <li><a href="#method-kronk-embeddings">Kronk.Embeddings</a></li>
                 <li><a href="#method-kronk-embeddingshttp">Kronk.EmbeddingsHTTP</a></li>
+                <li><a href="#method-kronk-healthy">Kronk.Healthy</a></li>
                 <li><a href="#method-kronk-modelconfig">Kronk.ModelConfig</a></li>
                 <li><a href="#method-kronk-modelinfo">Kronk.ModelInfo</a></li>
                 <li><a href="#method-kronk-rerank">Kronk.Rerank</a></li>
@@ -26,6 +26,54 @@ export default function DocsSDKModel() {
               <p className="doc-description">CheckModel is check if the downloaded model is valid based on it's sha file. If no sha file exists, this check will return with no error.</p>
             </div>
 
+            <div className="doc-section" id="func-getsubject">
+              <h4>GetSubject</h4>
+              <pre className="code-block">
+                <code>func GetSubject(ctx context.Context) string</code>
+              </pre>
+              <p className="doc-description">GetSubject returns the subject from the context.</p>
+            </div>
+
+            <div className="doc-section" id="func-libraryversion">
+              <h4>LibraryVersion</h4>
+              <pre className="code-block">
+                <code>func LibraryVersion() string</code>
+              </pre>
+              <p className="doc-description">LibraryVersion returns the version of the llama.cpp library that is loaded or an empty string if it's not known.</p>
+            </div>
+
+            <div className="doc-section" id="func-registertoolcallformat">
+              <h4>RegisterToolCallFormat</h4>
+              <pre className="code-block">
+                <code>func RegisterToolCallFormat(format ToolCallFormat) error</code>
+              </pre>
+              <p className="doc-description">RegisterToolCallFormat adds a tool call format that can be selected with the ToolCallFormat config setting. Formats registered later are preferred when the format is detected, and registering a format with the name of an existing one replaces it. Formats need to be registered before the model is loaded.</p>
+            </div>
+
+            <div className="doc-section" id="func-setlibraryversion">
+              <h4>SetLibraryVersion</h4>
+              <pre className="code-block">
+                <code>func SetLibraryVersion(version string)</code>
+              </pre>
+              <p className="doc-description">SetLibraryVersion records the version of the llama.cpp library that was loaded so it can be reported as part of the system fingerprint.</p>
+            </div>
+
+            <div className="doc-section" id="func-setsubject">
+              <h4>SetSubject</h4>
+              <pre className="code-block">
+                <code>func SetSubject(ctx context.Context, subject string) context.Context</code>
+              </pre>
+              <p className="doc-description">SetSubject sets the subject making the request in the context. The batch engine uses it to share the slots fairly between subjects.</p>
+            </div>
+
+            <div className="doc-section" id="func-parsecontextoverflow">
+              <h4>ParseContextOverflow</h4>
+              <pre className="code-block">
+                <code>func ParseContextOverflow(s string) (ContextOverflow, error)</code>
+              </pre>
+              <p className="doc-description">ParseContextOverflow parses a string into a ContextOverflow. Supported values: "error", "truncate_middle", "shift".</p>
+            </div>
+
             <div className="doc-section" id="func-parseggmltype">
               <h4>ParseGGMLType</h4>
               <pre className="code-block">
@@ -41,6 +89,22 @@ export default function DocsSDKModel() {
               </pre>
             </div>
 
+            <div className="doc-section" id="func-parsemodeltype">
+              <h4>ParseModelType</h4>
+              <pre className="code-block">
+                <code>func ParseModelType(s string) (ModelType, error)</code>
+              </pre>
+              <p className="doc-description">ParseModelType parses a string into a ModelType. Supported values: "", "auto", "chat", "gpt", "embed", "rerank".</p>
+            </div>
+
+            <div className="doc-section" id="func-parsepriority">
+              <h4>ParsePriority</h4>
+              <pre className="code-block">
+                <code>func ParsePriority(s string) (Priority, error)</code>
+              </pre>
+              <p className="doc-description">ParsePriority parses a string into a Priority. Supported values: "low", "normal", "high".</p>
+            </div>
+
             <div className="doc-section" id="func-parsesplitmode">
               <h4>ParseSplitMode</h4>
               <pre className="code-block">
@@ -48,25 +112,53 @@ export default function DocsSDKModel() {
               </pre>
               <p className="doc-description">ParseSplitMode parses a string into a SplitMode. Supported values: "none", "layer", "row", "expert-parallel", "tensor-parallel".</p>
             </div>
+
+            <div className="doc-section" id="func-lookuptoolcallformat">
+              <h4>LookupToolCallFormat</h4>
+              <pre className="code-block">
+                <code>func LookupToolCallFormat(name string) (ToolCallFormat, bool)</code>
+              </pre>
+              <p className="doc-description">LookupToolCallFormat returns the registered tool call format by name.</p>
+            </div>
           </div>
 
           <div className="card" id="types">
             <h3>Types</h3>
 
+            <div className="doc-section" id="type-capabilities">
+              <h4>Capabilities</h4>
+              <pre className="code-block">
+                <code>{`type Capabilities struct {
+	Endpoint  string
+	Images    bool
+	Audio     bool
+	Video     bool
+	Streaming bool
+	Reasoning bool
+	Tooling   bool
+	Embedding bool
+	Rerank    bool
+}`}</code>
+              </pre>
+              <p className="doc-description">Capabilities represents the capabilities of a model. It matches the capabilities of a model in the catalog.</p>
+            </div>
+
             <div className="doc-section" id="type-chatresponse">
               <h4>ChatResponse</h4>
               <pre className="code-block">
                 <code>{`type ChatResponse struct {
-	ID      string   \`json:"id"\`
-	Object  string   \`json:"object"\`
-	Created int64    \`json:"created"\`
-	Model   string   \`json:"model"\`
-	Choice  []Choice \`json:"choices"\`
-	Usage   Usage    \`json:"usage"\`
-	Prompt  string   \`json:"prompt,omitempty"\`
+	ID                string          \`json:"id"\`
+	Object            string          \`json:"object"\`
+	Created           int64           \`json:"created"\`
+	Model             string          \`json:"model"\`
+	SystemFingerprint string          \`json:"system_fingerprint,omitempty"\`
+	ContextOverflow   ContextOverflow \`json:"context_overflow,omitempty"\`
+	Choice            []Choice        \`json:"choices"\`
+	Usage             Usage           \`json:"usage"\`
+	Prompt            string          \`json:"prompt,omitempty"\`
 }`}</code>
               </pre>
-              <p className="doc-description">ChatResponse represents output for inference models.</p>
+              <p className="doc-description">ChatResponse represents output for inference models. SystemFingerprint identifies the model files and llama.cpp version that produced the response. ContextOverflow is the strategy that was applied when the request didn't fit the context window.</p>
             </div>
 
             <div className="doc-section" id="type-choice">
@@ -76,6 +168,7 @@ export default function DocsSDKModel() {
 	Index           int              \`json:"index"\`
 	Message         *ResponseMessage \`json:"message,omitempty"\`
 	Delta           *ResponseMessage \`json:"delta,omitempty"\`
+	Logprobs        *Logprobs        \`json:"logprobs,omitempty"\`
 	FinishReasonPtr *string          \`json:"finish_reason"\`
 }`}</code>
               </pre>
@@ -106,9 +199,39 @@ export default function DocsSDKModel() {
 	OpOffload            *bool
 	NGpuLayers           *int32
 	SplitMode            SplitMode
+	DraftModelFiles      []string
+	DraftMax             int
+	DraftMin             int
+	ContextOverflow      ContextOverflow
+	PrefillBudget        int
+	StreamBacklog        int
+	MaxDecodeFailures    int
+	ModelType            ModelType
+	ToolCallFormat       string
+}`}</code>
+              </pre>
+              <p className="doc-description">Config represents model level configuration. These values if configured incorrectly can cause the system to panic. The defaults are used when these values are set to 0. ModelInstances is the number of instances of the model to create. Unless you have more than 1 GPU, the recommended number of instances is 1. ModelFiles is the path to the model files. This is mandatory to provide. ProjFiles is the path to the projection files. This is mandatory for media based models like vision and audio. JinjaFile is the path to the jinja file. This is not required and can be used if you want to override the templated provided by the model metadata. Device is the device to use for the model. If not set, the default device will be used. To see what devices are available, run the following command which will be found where you installed llama.cpp. $ llama-bench --list-devices ContextWindow (often referred to as context length) is the maximum number of tokens that a large language model can process and consider at one time when generating a response. It defines the model's effective "memory" for a single conversation or text generation task. When set to 0, the default value is 4096. NBatch is the logical batch size or the maximum number of tokens that can be in a single forward pass through the model at any given time. It defines the maximum capacity of the processing batch. If you are processing a very long prompt or multiple prompts simultaneously, the total number of tokens processed in one go will not exceed NBatch. Increasing n_batch can improve performance (throughput) if your hardware can handle it, as it better utilizes parallel computation. However, a very high n_batch can lead to out-of-memory errors on systems with limited VRAM. When set to 0, the default value is 2048. NUBatch is the physical batch size or the maximum number of tokens processed together during the initial prompt processing phase (also called "prompt ingestion") to populate the KV cache. It specifically optimizes the initial loading of prompt tokens into the KV cache. If a prompt is longer than NUBatch, it will be broken down and processed in chunks of n_ubatch tokens sequentially. This parameter is crucial for tuning performance on specific hardware (especially GPUs) because different values might yield better prompt processing times depending on the memory architecture. When set to 0, the default value is 512. NThreads is the number of threads to use for generation. When set to 0, the default llama.cpp value is used. NThreadsBatch is the number of threads to use for batch processing. When set to 0, the default llama.cpp value is used. CacheTypeK is the data type for the K (key) cache. This controls the precision of the key vectors in the KV cache. Lower precision types (like Q8_0 or Q4_0) reduce memory usage but may slightly affect quality. When set to GGMLTypeAuto or left as zero value, the default llama.cpp value (F16) is used. CacheTypeV is the data type for the V (value) cache. This controls the precision of the value vectors in the KV cache. When set to GGMLTypeAuto or left as zero value, the default llama.cpp value (F16) is used. FlashAttention controls Flash Attention mode. Flash Attention reduces memory usage and speeds up attention computation, especially for large context windows. When left as zero value, FlashAttentionEnabled is used (default on). Set to FlashAttentionDisabled to disable, or FlashAttentionAuto to let llama.cpp decide. IgnoreIntegrityCheck is a boolean that determines if the system should ignore a model integrity check before trying to use it. NSeqMax controls concurrency behavior based on model type. For text inference models, including vision and audio models, it sets the maximum number of sequences processed in parallel within a single model instance (batched inference). For sequential models (embeddings, reranking), it creates that many model instances in a pool for concurrent request handling. When set to 0, a default of 1 is used. OffloadKQV controls whether the KV cache is offloaded to the GPU. When nil or true, the KV cache is stored on the GPU (default behavior). Set to false to keep the KV cache on the CPU, which reduces VRAM usage but may slow inference. OpOffload controls whether host tensor operations are offloaded to the device (GPU). When nil or true, operations are offloaded (default behavior). Set to false to keep operations on the CPU. NGpuLayers is the number of model layers to offload to the GPU. When set to 0, all layers are offloaded (default). Set to -1 to keep all layers on CPU. Any positive value specifies the exact number of layers to offload. SplitMode controls how the model is split across multiple GPUs: - SplitModeNone (0): single GPU - SplitModeLayer (1): split layers and KV across GPUs - SplitModeRow (2): split layers and KV across GPUs with tensor parallelism (recommended for MoE models like Qwen3-MoE, Mixtral, DeepSeek) When not set, defaults to SplitModeRow for optimal MoE performance. DraftModelFiles is the path to the files of a smaller model from the same family that is used for speculative decoding. The draft model proposes tokens that the model verifies in a single forward pass, which increases tokens per second when the proposals are often accepted. Both models must share the same vocab. The draft weights are loaded once and shared by the instances of the model. When not set, speculative decoding is not used. DraftMax is the maximum number of tokens the draft model proposes at a time. When set to 0, the default value is 16. DraftMin is the minimum number of tokens the draft model needs to propose for them to be verified. When set to 0, any number of tokens is verified. ContextOverflow is the default strategy used when a request doesn't fit in the context window. Requests can override it with the context_overflow parameter. When not set, the request fails with ContextOverflowError. PrefillBudget is the maximum number of prompt tokens the batch engine adds to a batch while other slots are generating. The tokens of generating slots always go first, and the rest of NBatch is shared by the prompts being processed. A lower value keeps the time between tokens steady for requests that are generating, at the cost of a longer time to first token for new requests. When set to 0, all of NBatch can be used for prompts. StreamBacklog is the number of responses the batch engine queues for a streaming client that isn't reading them fast enough. A request whose client falls further behind is cancelled with ErrSlowClient so the other requests keep generating at full speed. When set to 0, the default value is 256. MaxDecodeFailures is the number of batches in a row the batch engine can fail to decode before the model is marked unhealthy. The requests in a failed batch get an error and the engine continues with a cleared KV cache. When set to 0, the default value is 3. ModelType overrides the type of the model, which is detected from the GGUF metadata by default. Use it when a model is classified incorrectly, for example an embedding model without a pooling type in its metadata. When not set, ModelTypeAuto is used. ToolCallFormat is the name of the format the model uses for tool calls, which is detected from the chat template and the special tokens of the model by default. The built-in formats are hermes, mistral, llama3 and deepseek, and more can be added with RegisterToolCallFormat.</p>
+            </div>
+
+            <div className="doc-section" id="type-contentlogprob">
+              <h4>ContentLogprob</h4>
+              <pre className="code-block">
+                <code>{`type ContentLogprob struct {
+	Token       string       \`json:"token"\`
+	Logprob     float32      \`json:"logprob"\`
+	Bytes       []int        \`json:"bytes"\`
+	TopLogprobs []TopLogprob \`json:"top_logprobs"\`
 }`}</code>
               </pre>
-              <p className="doc-description">Config represents model level configuration. These values if configured incorrectly can cause the system to panic. The defaults are used when these values are set to 0. ModelInstances is the number of instances of the model to create. Unless you have more than 1 GPU, the recommended number of instances is 1. ModelFiles is the path to the model files. This is mandatory to provide. ProjFiles is the path to the projection files. This is mandatory for media based models like vision and audio. JinjaFile is the path to the jinja file. This is not required and can be used if you want to override the templated provided by the model metadata. Device is the device to use for the model. If not set, the default device will be used. To see what devices are available, run the following command which will be found where you installed llama.cpp. $ llama-bench --list-devices ContextWindow (often referred to as context length) is the maximum number of tokens that a large language model can process and consider at one time when generating a response. It defines the model's effective "memory" for a single conversation or text generation task. When set to 0, the default value is 4096. NBatch is the logical batch size or the maximum number of tokens that can be in a single forward pass through the model at any given time. It defines the maximum capacity of the processing batch. If you are processing a very long prompt or multiple prompts simultaneously, the total number of tokens processed in one go will not exceed NBatch. Increasing n_batch can improve performance (throughput) if your hardware can handle it, as it better utilizes parallel computation. However, a very high n_batch can lead to out-of-memory errors on systems with limited VRAM. When set to 0, the default value is 2048. NUBatch is the physical batch size or the maximum number of tokens processed together during the initial prompt processing phase (also called "prompt ingestion") to populate the KV cache. It specifically optimizes the initial loading of prompt tokens into the KV cache. If a prompt is longer than NUBatch, it will be broken down and processed in chunks of n_ubatch tokens sequentially. This parameter is crucial for tuning performance on specific hardware (especially GPUs) because different values might yield better prompt processing times depending on the memory architecture. When set to 0, the default value is 512. NThreads is the number of threads to use for generation. When set to 0, the default llama.cpp value is used. NThreadsBatch is the number of threads to use for batch processing. When set to 0, the default llama.cpp value is used. CacheTypeK is the data type for the K (key) cache. This controls the precision of the key vectors in the KV cache. Lower precision types (like Q8_0 or Q4_0) reduce memory usage but may slightly affect quality. When set to GGMLTypeAuto or left as zero value, the default llama.cpp value (F16) is used. CacheTypeV is the data type for the V (value) cache. This controls the precision of the value vectors in the KV cache. When set to GGMLTypeAuto or left as zero value, the default llama.cpp value (F16) is used. FlashAttention controls Flash Attention mode. Flash Attention reduces memory usage and speeds up attention computation, especially for large context windows. When left as zero value, FlashAttentionEnabled is used (default on). Set to FlashAttentionDisabled to disable, or FlashAttentionAuto to let llama.cpp decide. IgnoreIntegrityCheck is a boolean that determines if the system should ignore a model integrity check before trying to use it. NSeqMax controls concurrency behavior based on model type. For text inference models, it sets the maximum number of sequences processed in parallel within a single model instance (batched inference). For sequential models (embeddings, reranking, vision, audio), it creates that many model instances in a pool for concurrent request handling. When set to 0, a default of 1 is used. OffloadKQV controls whether the KV cache is offloaded to the GPU. When nil or true, the KV cache is stored on the GPU (default behavior). Set to false to keep the KV cache on the CPU, which reduces VRAM usage but may slow inference. OpOffload controls whether host tensor operations are offloaded to the device (GPU). When nil or true, operations are offloaded (default behavior). Set to false to keep operations on the CPU. NGpuLayers is the number of model layers to offload to the GPU. When set to 0, all layers are offloaded (default). Set to -1 to keep all layers on CPU. Any positive value specifies the exact number of layers to offload. SplitMode controls how the model is split across multiple GPUs: - SplitModeNone (0): single GPU - SplitModeLayer (1): split layers and KV across GPUs - SplitModeRow (2): split layers and KV across GPUs with tensor parallelism (recommended for MoE models like Qwen3-MoE, Mixtral, DeepSeek) When not set, defaults to SplitModeRow for optimal MoE performance.</p>
+              <p className="doc-description">ContentLogprob represents the log probability of a generated token along with the most likely tokens at that position when top_logprobs is set.</p>
+            </div>
+
+            <div className="doc-section" id="type-contextoverflow">
+              <h4>ContextOverflow</h4>
+              <pre className="code-block">
+                <code>{`type ContextOverflow string`}</code>
+              </pre>
+              <p className="doc-description">ContextOverflow is the strategy used when a request doesn't fit in the context window.</p>
             </div>
 
             <div className="doc-section" id="type-d">
@@ -180,6 +303,16 @@ export default function DocsSDKModel() {
               <p className="doc-description">Logger provides a function for logging messages from different APIs.</p>
             </div>
 
+            <div className="doc-section" id="type-logprobs">
+              <h4>Logprobs</h4>
+              <pre className="code-block">
+                <code>{`type Logprobs struct {
+	Content []ContentLogprob \`json:"content"\`
+}`}</code>
+              </pre>
+              <p className="doc-description">Logprobs provides the log probability information for a choice.</p>
+            </div>
+
             <div className="doc-section" id="type-mediatype">
               <h4>MediaType</h4>
               <pre className="code-block">
@@ -201,23 +334,42 @@ export default function DocsSDKModel() {
               <h4>ModelInfo</h4>
               <pre className="code-block">
                 <code>{`type ModelInfo struct {
-	ID            string
-	HasProjection bool
-	Desc          string
-	Size          uint64
-	HasEncoder    bool
-	HasDecoder    bool
-	IsRecurrent   bool
-	IsHybrid      bool
-	IsGPTModel    bool
-	IsEmbedModel  bool
-	IsRerankModel bool
-	Metadata      map[string]string
-	TemplateFile  string
-	Template      Template
+	ID             string
+	HasProjection  bool
+	Desc           string
+	Size           uint64
+	Instances      int
+	HasEncoder     bool
+	HasDecoder     bool
+	IsRecurrent    bool
+	IsHybrid       bool
+	IsGPTModel     bool
+	IsEmbedModel   bool
+	IsRerankModel  bool
+	Metadata       map[string]string
+	TemplateFile   string
+	Template       Template
+	Capabilities   Capabilities
+	ToolCallFormat string
 }`}</code>
               </pre>
-              <p className="doc-description">ModelInfo represents the model's card information.</p>
+              <p className="doc-description">ModelInfo represents the model's card information. Size is the size of the model's tensors. The weights are loaded once and shared by the Instances of the model, which each add their own KV cache and compute buffers.</p>
+            </div>
+
+            <div className="doc-section" id="type-modeltype">
+              <h4>ModelType</h4>
+              <pre className="code-block">
+                <code>{`type ModelType string`}</code>
+              </pre>
+              <p className="doc-description">ModelType is the kind of model, which decides the APIs it supports.</p>
+            </div>
+
+            <div className="doc-section" id="type-priority">
+              <h4>Priority</h4>
+              <pre className="code-block">
+                <code>{`type Priority int`}</code>
+              </pre>
+              <p className="doc-description">Priority is the scheduling class of a request in the batch engine. Pending requests with a higher priority are started first.</p>
             </div>
 
             <div className="doc-section" id="type-rerankresponse">
@@ -261,13 +413,14 @@ export default function DocsSDKModel() {
               <h4>ResponseMessage</h4>
               <pre className="code-block">
                 <code>{`type ResponseMessage struct {
-	Role      string             \`json:"role,omitempty"\`
-	Content   string             \`json:"content,omitempty"\`
-	Reasoning string             \`json:"reasoning_content,omitempty"\`
-	ToolCalls []ResponseToolCall \`json:"tool_calls,omitempty"\`
+	Role           string                  \`json:"role,omitempty"\`
+	Content        string                  \`json:"content,omitempty"\`
+	Reasoning      string                  \`json:"reasoning_content,omitempty"\`
+	ToolCalls      []ResponseToolCall      \`json:"tool_calls,omitempty"\`
+	ToolCallDeltas []ResponseToolCallDelta \`json:"-"\`
 }`}</code>
               </pre>
-              <p className="doc-description">ResponseMessage represents a single message in a response.</p>
+              <p className="doc-description">ResponseMessage represents a single message in a response. ToolCallDeltas are only set on streamed deltas and are sent as the tool_calls field, the final response has the complete ToolCalls.</p>
             </div>
 
             <div className="doc-section" id="type-responsetoolcall">
@@ -283,6 +436,31 @@ export default function DocsSDKModel() {
 	Error    string                   \`json:"error,omitempty"\`
 }`}</code>
               </pre>
+              <p className="doc-description">ResponseToolCall is a tool call made by the model. Calls to a tool of the request are checked against the parameters schema of the tool, and calls that don't match it have the ToolCallStatusInvalid status.</p>
+            </div>
+
+            <div className="doc-section" id="type-responsetoolcalldelta">
+              <h4>ResponseToolCallDelta</h4>
+              <pre className="code-block">
+                <code>{`type ResponseToolCallDelta struct {
+	Index    int                           \`json:"index"\`
+	ID       string                        \`json:"id,omitempty"\`
+	Type     string                        \`json:"type,omitempty"\`
+	Function ResponseToolCallDeltaFunction \`json:"function"\`
+}`}</code>
+              </pre>
+              <p className="doc-description">ResponseToolCallDelta is a part of a tool call streamed while the model writes it. The first delta of a call has the ID, Type and Name, the deltas after it add to the Arguments. Index identifies the call the delta belongs to and matches the Index of the tool call in the final response.</p>
+            </div>
+
+            <div className="doc-section" id="type-responsetoolcalldeltafunction">
+              <h4>ResponseToolCallDeltaFunction</h4>
+              <pre className="code-block">
+                <code>{`type ResponseToolCallDeltaFunction struct {
+	Name      string \`json:"name,omitempty"\`
+	Arguments string \`json:"arguments"\`
+}`}</code>
+              </pre>
+              <p className="doc-description">ResponseToolCallDeltaFunction is the part of a function call in a delta.</p>
             </div>
 
             <div className="doc-section" id="type-responsetoolcallfunction">
@@ -332,11 +510,37 @@ export default function DocsSDKModel() {
               <p className="doc-description">ToolCallArguments represents tool call arguments that marshal to a JSON string per OpenAI API spec, but can unmarshal from either a string or object.</p>
             </div>
 
+            <div className="doc-section" id="type-toolcallformat">
+              <h4>ToolCallFormat</h4>
+              <pre className="code-block">
+                <code>{`type ToolCallFormat struct {
+	Name  string
+	Start string
+	End   string
+	Parse func(content string) []ResponseToolCall
+}`}</code>
+              </pre>
+              <p className="doc-description">ToolCallFormat describes how a model family marks the tool calls in its output. The Start and End markers are matched against whole tokens, so they need to be special tokens in the vocab of the model. When End is empty the tool calls run to the end of the generation. Content of several tool calls is passed to Parse separated by a newline. The harmony format of gpt-oss models is handled separately and isn't a registered format.</p>
+            </div>
+
+            <div className="doc-section" id="type-toplogprob">
+              <h4>TopLogprob</h4>
+              <pre className="code-block">
+                <code>{`type TopLogprob struct {
+	Token   string  \`json:"token"\`
+	Logprob float32 \`json:"logprob"\`
+	Bytes   []int   \`json:"bytes"\`
+}`}</code>
+              </pre>
+              <p className="doc-description">TopLogprob represents one of the most likely tokens at a position in the output.</p>
+            </div>
+
             <div className="doc-section" id="type-usage">
               <h4>Usage</h4>
               <pre className="code-block">
                 <code>{`type Usage struct {
 	PromptTokens     int     \`json:"prompt_tokens"\`
+	CachedTokens     int     \`json:"cached_tokens"\`
 	ReasoningTokens  int     \`json:"reasoning_tokens"\`
 	CompletionTokens int     \`json:"completion_tokens"\`
 	OutputTokens     int     \`json:"output_tokens"\`
@@ -344,7 +548,7 @@ export default function DocsSDKModel() {
 	TokensPerSecond  float64 \`json:"tokens_per_second"\`
 }`}</code>
               </pre>
-              <p className="doc-description">Usage provides details usage information for the request.</p>
+              <p className="doc-description">Usage provides details usage information for the request. CachedTokens is the part of PromptTokens that was reused from the KV cache and didn't need to be processed again.</p>
             </div>
           </div>
 
@@ -359,6 +563,22 @@ export default function DocsSDKModel() {
               <p className="doc-description">FinishReason return the finish reason as an empty string if it is nil.</p>
             </div>
 
+            <div className="doc-section" id="method-contextoverflow-string">
+              <h4>ContextOverflow.String</h4>
+              <pre className="code-block">
+                <code>func (co ContextOverflow) String() string</code>
+              </pre>
+              <p className="doc-description">String returns the string representation of a ContextOverflow.</p>
+            </div>
+
+            <div className="doc-section" id="method-contextoverflow-unmarshalyaml">
+              <h4>ContextOverflow.UnmarshalYAML</h4>
+              <pre className="code-block">
+                <code>func (co *ContextOverflow) UnmarshalYAML(unmarshal func(interface&#123;&#125;) error) error</code>
+              </pre>
+              <p className="doc-description">UnmarshalYAML implements yaml.Unmarshaler to validate string values.</p>
+            </div>
+
             <div className="doc-section" id="method-d-clone">
               <h4>D.Clone</h4>
               <pre className="code-block">
@@ -411,7 +631,7 @@ export default function DocsSDKModel() {
               <pre className="code-block">
                 <code>func (m *Model) Chat(ctx context.Context, d D) (ChatResponse, error)</code>
               </pre>
-              <p className="doc-description">Chat performs a chat request and returns the final response. Text inference requests can run concurrently based on the NSeqMax config value, which controls parallel sequence processing. However, requests that include vision or audio content are processed sequentially due to media pipeline constraints.</p>
+              <p className="doc-description">Chat performs a chat request and returns the final response. Requests can run concurrently based on the NSeqMax config value, which controls parallel sequence processing. Requests that include vision or audio content share the batch engine with text requests, each media item is encoded into the sequence of the request's slot in an iteration of its own.</p>
             </div>
 
             <div className="doc-section" id="method-model-chatstreaming">
@@ -419,7 +639,7 @@ export default function DocsSDKModel() {
               <pre className="code-block">
                 <code>func (m *Model) ChatStreaming(ctx context.Context, d D) &lt;-chan ChatResponse</code>
               </pre>
-              <p className="doc-description">ChatStreaming performs a chat request and streams the response. Text inference requests can run concurrently based on the NSeqMax config value, which controls parallel sequence processing. However, requests that include vision or audio content are processed sequentially due to media pipeline constraints.</p>
+              <p className="doc-description">ChatStreaming performs a chat request and streams the response. Requests can run concurrently based on the NSeqMax config value, which controls parallel sequence processing. Requests that include vision or audio content share the batch engine with text requests, each media item is encoded into the sequence of the request's slot in an iteration of its own.</p>
             </div>
 
             <div className="doc-section" id="method-model-config">
@@ -434,7 +654,15 @@ export default function DocsSDKModel() {
               <pre className="code-block">
                 <code>func (m *Model) Embeddings(ctx context.Context, d D) (EmbedReponse, error)</code>
               </pre>
-              <p className="doc-description">Embeddings performs batch embedding for multiple inputs in a single forward pass. This is more efficient than calling Embeddings multiple times. Supported options in d: - input ([]string): the texts to embed (required) - truncate (bool): if true, truncate inputs to fit context window (default: false) - truncate_direction (string): "right" (default) or "left" - dimensions (int): reduce output to first N dimensions (for Matryoshka models) Each model instance processes calls sequentially (llama.cpp only supports sequence 0 for embedding extraction). Use NSeqMax &gt; 1 to create multiple model instances for concurrent request handling. Batch multiple texts in the input parameter for better performance within a single request.</p>
+              <p className="doc-description">Embeddings performs batch embedding for multiple inputs. The inputs are packed into batches across sequences, up to NSeqMax+1 inputs and NBatch tokens per forward pass. This is more efficient than calling Embeddings multiple times. Supported options in d: - input ([]string): the texts to embed (required) - truncate (bool): if true, truncate inputs to fit context window (default: false) - truncate_direction (string): "right" (default) or "left" - dimensions (int): reduce output to first N dimensions (for Matryoshka models) Each model instance processes calls sequentially using the context of the instance. Use NSeqMax &gt; 1 to create multiple model instances for concurrent request handling. Batch multiple texts in the input parameter for better performance within a single request.</p>
+            </div>
+
+            <div className="doc-section" id="method-model-healthy">
+              <h4>Model.Healthy</h4>
+              <pre className="code-block">
+                <code>func (m *Model) Healthy() bool</code>
+              </pre>
+              <p className="doc-description">Healthy returns false once the batch engine failed to decode too many batches in a row. An unhealthy model should be unloaded and loaded again.</p>
             </div>
 
             <div className="doc-section" id="method-model-modelinfo">
@@ -444,12 +672,20 @@ export default function DocsSDKModel() {
               </pre>
             </div>
 
+            <div className="doc-section" id="method-model-newinstance">
+              <h4>Model.NewInstance</h4>
+              <pre className="code-block">
+                <code>func (m *Model) NewInstance(ctx context.Context) (*Model, error)</code>
+              </pre>
+              <p className="doc-description">NewInstance creates another instance of the model that shares the loaded weights. The instance has its own context, so it only adds the memory of its KV cache and compute buffers. The weights are freed when the last instance sharing them is unloaded.</p>
+            </div>
+
             <div className="doc-section" id="method-model-rerank">
               <h4>Model.Rerank</h4>
               <pre className="code-block">
                 <code>func (m *Model) Rerank(ctx context.Context, d D) (RerankResponse, error)</code>
               </pre>
-              <p className="doc-description">Rerank performs reranking for a query against multiple documents. It scores each document's relevance to the query and returns results sorted by relevance score (highest first). Supported options in d: - query (string): the query to rank documents against (required) - documents ([]string): the documents to rank (required) - top_n (int): return only the top N results (optional, default: all) - return_documents (bool): include document text in results (default: false) Each model instance processes calls sequentially (llama.cpp only supports sequence 0 for rerank extraction). Use NSeqMax &gt; 1 to create multiple model instances for concurrent request handling. Batch multiple texts in the input parameter for better performance within a single request.</p>
+              <p className="doc-description">Rerank performs reranking for a query against multiple documents. It scores each document's relevance to the query and returns results sorted by relevance score (highest first). Supported options in d: - query (string): the query to rank documents against (required) - documents ([]string): the documents to rank (required) - top_n (int): return only the top N results (optional, default: all) - return_documents (bool): include document text in results (default: false) The query-document pairs are packed into batches across sequences, up to NSeqMax+1 pairs and NBatch tokens per forward pass. Each model instance processes calls sequentially using the context of the instance. Use NSeqMax &gt; 1 to create multiple model instances for concurrent request handling. Batch multiple texts in the input parameter for better performance within a single request.</p>
             </div>
 
             <div className="doc-section" id="method-model-unload">
@@ -459,6 +695,22 @@ export default function DocsSDKModel() {
               </pre>
             </div>
 
+            <div className="doc-section" id="method-priority-string">
+              <h4>Priority.String</h4>
+              <pre className="code-block">
+                <code>func (p Priority) String() string</code>
+              </pre>
+              <p className="doc-description">String returns the string representation of a Priority.</p>
+            </div>
+
+            <div className="doc-section" id="method-responsemessage-marshaljson">
+              <h4>ResponseMessage.MarshalJSON</h4>
+              <pre className="code-block">
+                <code>func (rm ResponseMessage) MarshalJSON() ([]byte, error)</code>
+              </pre>
+              <p className="doc-description">MarshalJSON sends the tool call deltas in the tool_calls field like the OpenAI streaming format.</p>
+            </div>
+
             <div className="doc-section" id="method-splitmode-string">
               <h4>SplitMode.String</h4>
               <pre className="code-block">
@@ -501,6 +753,30 @@ export default function DocsSDKModel() {
           <div className="card" id="constants">
             <h3>Constants</h3>
 
+            <div className="doc-section" id="const-endpointchatcompletion">
+              <h4>EndpointChatCompletion</h4>
+              <pre className="code-block">
+                <code>{`const (
+	EndpointChatCompletion = "chat_completion"
+	EndpointEmbeddings     = "embeddings"
+	EndpointRerank         = "rerank"
+)`}</code>
+              </pre>
+              <p className="doc-description">Endpoints a model can be used with.</p>
+            </div>
+
+            <div className="doc-section" id="const-responseformattext">
+              <h4>ResponseFormatText</h4>
+              <pre className="code-block">
+                <code>{`const (
+	ResponseFormatText       = "text"
+	ResponseFormatJSONObject = "json_object"
+	ResponseFormatJSONSchema = "json_schema"
+)`}</code>
+              </pre>
+              <p className="doc-description">Response format types supported by the response_format parameter.</p>
+            </div>
+
             <div className="doc-section" id="const-objectchatunknown">
               <h4>ObjectChatUnknown</h4>
               <pre className="code-block">
@@ -530,14 +806,28 @@ export default function DocsSDKModel() {
               <h4>FinishReasonStop</h4>
               <pre className="code-block">
                 <code>{`const (
-	FinishReasonStop  = "stop"
-	FinishReasonTool  = "tool_calls"
-	FinishReasonError = "error"
+	FinishReasonStop   = "stop"
+	FinishReasonTool   = "tool_calls"
+	FinishReasonLength = "length"
+	FinishReasonError  = "error"
 )`}</code>
               </pre>
               <p className="doc-description">FinishReasons represent the different reasons a response can be finished.</p>
             </div>
 
+            <div className="doc-section" id="const-toolcallstatusok">
+              <h4>ToolCallStatusOK</h4>
+              <pre className="code-block">
+                <code>{`const (
+	ToolCallStatusOK        = 0
+	ToolCallStatusMissing   = 1
+	ToolCallStatusMalformed = 2
+	ToolCallStatusInvalid   = 3
+)`}</code>
+              </pre>
+              <p className="doc-description">Status values of a tool call. A call with a status other than ToolCallStatusOK has the reason in Error and the text the model wrote in Raw.</p>
+            </div>
+
             <div className="doc-section" id="const-thinkingenabled">
               <h4>ThinkingEnabled</h4>
               <pre className="code-block">
@@ -580,6 +870,43 @@ export default function DocsSDKModel() {
 )`}</code>
               </pre>
             </div>
+
+            <div className="doc-section" id="const-toolcallformathermes">
+              <h4>ToolCallFormatHermes</h4>
+              <pre className="code-block">
+                <code>{`const (
+	ToolCallFormatHermes   = "hermes"
+	ToolCallFormatMistral  = "mistral"
+	ToolCallFormatLlama3   = "llama3"
+	ToolCallFormatDeepSeek = "deepseek"
+)`}</code>
+              </pre>
+              <p className="doc-description">Names of the built-in tool call formats.</p>
+            </div>
+
+            <div className="doc-section" id="const-toolchoiceauto">
+              <h4>ToolChoiceAuto</h4>
+              <pre className="code-block">
+                <code>{`const (
+	ToolChoiceAuto     = "auto"
+	ToolChoiceNone     = "none"
+	ToolChoiceRequired = "required"
+)`}</code>
+              </pre>
+              <p className="doc-description">Tool choice modes supported by the tool_choice parameter.</p>
+            </div>
+          </div>
+
+          <div className="card" id="variables">
+            <h3>Variables</h3>
+
+            <div className="doc-section" id="var-errslowclient">
+              <h4>ErrSlowClient</h4>
+              <pre className="code-block">
+                <code>{`var ErrSlowClient = errors.New("client is not reading the stream fast enough")`}</code>
+              </pre>
+              <p className="doc-description">ErrSlowClient is returned when a streaming client falls too far behind reading the responses of a request.</p>
+            </div>
           </div>
         </div>
 
@@ -589,17 +916,29 @@ export default function DocsSDKModel() {
               <a href="#functions" className="doc-index-header">Functions</a>
               <ul>
                 <li><a href="#func-checkmodel">CheckModel</a></li>
+                <li><a href="#func-getsubject">GetSubject</a></li>
+                <li><a href="#func-libraryversion">LibraryVersion</a></li>
+                <li><a href="#func-registertoolcallformat">RegisterToolCallFormat</a></li>
+                <li><a href="#func-setlibraryversion">SetLibraryVersion</a></li>
+                <li><a href="#func-setsubject">SetSubject</a></li>
+                <li><a href="#func-parsecontextoverflow">ParseContextOverflow</a></li>
                 <li><a href="#func-parseggmltype">ParseGGMLType</a></li>
                 <li><a href="#func-newmodel">NewModel</a></li>
+                <li><a href="#func-parsemodeltype">ParseModelType</a></li>
+                <li><a href="#func-parsepriority">ParsePriority</a></li>
                 <li><a href="#func-parsesplitmode">ParseSplitMode</a></li>
+                <li><a href="#func-lookuptoolcallformat">LookupToolCallFormat</a></li>
               </ul>
             </div>
             <div className="doc-index-section">
               <a href="#types" className="doc-index-header">Types</a>
               <ul>
+                <li><a href="#type-capabilities">Capabilities</a></li>
                 <li><a href="#type-chatresponse">ChatResponse</a></li>
                 <li><a href="#type-choice">Choice</a></li>
                 <li><a href="#type-config">Config</a></li>
+                <li><a href="#type-contentlogprob">ContentLogprob</a></li>
+                <li><a href="#type-contextoverflow">ContextOverflow</a></li>
                 <li><a href="#type-d">D</a></li>
                 <li><a href="#type-embeddata">EmbedData</a></li>
                 <li><a href="#type-embedreponse">EmbedReponse</a></li>
@@ -607,19 +946,26 @@ export default function DocsSDKModel() {
                 <li><a href="#type-flashattentiontype">FlashAttentionType</a></li>
                 <li><a href="#type-ggmltype">GGMLType</a></li>
                 <li><a href="#type-logger">Logger</a></li>
+                <li><a href="#type-logprobs">Logprobs</a></li>
                 <li><a href="#type-mediatype">MediaType</a></li>
                 <li><a href="#type-model">Model</a></li>
                 <li><a href="#type-modelinfo">ModelInfo</a></li>
+                <li><a href="#type-modeltype">ModelType</a></li>
+                <li><a href="#type-priority">Priority</a></li>
                 <li><a href="#type-rerankresponse">RerankResponse</a></li>
                 <li><a href="#type-rerankresult">RerankResult</a></li>
                 <li><a href="#type-rerankusage">RerankUsage</a></li>
                 <li><a href="#type-responsemessage">ResponseMessage</a></li>
                 <li><a href="#type-responsetoolcall">ResponseToolCall</a></li>
+                <li><a href="#type-responsetoolcalldelta">ResponseToolCallDelta</a></li>
+                <li><a href="#type-responsetoolcalldeltafunction">ResponseToolCallDeltaFunction</a></li>
                 <li><a href="#type-responsetoolcallfunction">ResponseToolCallFunction</a></li>
                 <li><a href="#type-splitmode">SplitMode</a></li>
                 <li><a href="#type-template">Template</a></li>
                 <li><a href="#type-templateretriever">TemplateRetriever</a></li>
                 <li><a href="#type-toolcallarguments">ToolCallArguments</a></li>
+                <li><a href="#type-toolcallformat">ToolCallFormat</a></li>
+                <li><a href="#type-toplogprob">TopLogprob</a></li>
                 <li><a href="#type-usage">Usage</a></li>
               </ul>
             </div>
@@ -627,6 +973,8 @@ export default function DocsSDKModel() {
               <a href="#methods" className="doc-index-header">Methods</a>
               <ul>
                 <li><a href="#method-choice-finishreason">Choice.FinishReason</a></li>
+                <li><a href="#method-contextoverflow-string">ContextOverflow.String</a></li>
+                <li><a href="#method-contextoverflow-unmarshalyaml">ContextOverflow.UnmarshalYAML</a></li>
                 <li><a href="#method-d-clone">D.Clone</a></li>
                 <li><a href="#method-d-logsafe">D.LogSafe</a></li>
                 <li><a href="#method-flashattentiontype-unmarshalyaml">FlashAttentionType.UnmarshalYAML</a></li>
@@ -637,9 +985,13 @@ export default function DocsSDKModel() {
                 <li><a href="#method-model-chatstreaming">Model.ChatStreaming</a></li>
                 <li><a href="#method-model-config">Model.Config</a></li>
                 <li><a href="#method-model-embeddings">Model.Embeddings</a></li>
+                <li><a href="#method-model-healthy">Model.Healthy</a></li>
                 <li><a href="#method-model-modelinfo">Model.ModelInfo</a></li>
+                <li><a href="#method-model-newinstance">Model.NewInstance</a></li>
                 <li><a href="#method-model-rerank">Model.Rerank</a></li>
                 <li><a href="#method-model-unload">Model.Unload</a></li>
+                <li><a href="#method-priority-string">Priority.String</a></li>
+                <li><a href="#method-responsemessage-marshaljson">ResponseMessage.MarshalJSON</a></li>
                 <li><a href="#method-splitmode-string">SplitMode.String</a></li>
                 <li><a href="#method-splitmode-toyzmatype">SplitMode.ToYZMAType</a></li>
                 <li><a href="#method-splitmode-unmarshalyaml">SplitMode.UnmarshalYAML</a></li>
@@ -650,11 +1002,22 @@ export default function DocsSDKModel() {
             <div className="doc-index-section">
               <a href="#constants" className="doc-index-header">Constants</a>
               <ul>
+                <li><a href="#const-endpointchatcompletion">EndpointChatCompletion</a></li>
+                <li><a href="#const-responseformattext">ResponseFormatText</a></li>
                 <li><a href="#const-objectchatunknown">ObjectChatUnknown</a></li>
                 <li><a href="#const-roleuser">RoleUser</a></li>
                 <li><a href="#const-finishreasonstop">FinishReasonStop</a></li>
+                <li><a href="#const-toolcallstatusok">ToolCallStatusOK</a></li>
                 <li><a href="#const-thinkingenabled">ThinkingEnabled</a></li>
                 <li><a href="#const-reasoningeffortnone">ReasoningEffortNone</a></li>
+                <li><a href="#const-toolcallformathermes">ToolCallFormatHermes</a></li>
+                <li><a href="#const-toolchoiceauto">ToolChoiceAuto</a></li>
+              </ul>
+            </div>
+            <div className="doc-index-section">
+              <a href="#variables" className="doc-index-header">Variables</a>
+              <ul>
+                <li><a href="#var-errslowclient">ErrSlowClient</a></li>
               </ul>
             </div>
           </div>
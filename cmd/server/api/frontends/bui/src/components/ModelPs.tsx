@@ -58,6 +58,8 @@ export default function ModelPs() {
                     <th>Owner</th>
                     <th>Family</th>
                     <th>Size</th>
+                    <th>Shared Weights</th>
+                    <th>Instances</th>
                     <th>Expires At</th>
                     <th>Active Streams</th>
                   </tr>
@@ -69,6 +71,8 @@ export default function ModelPs() {
                       <td>{model.owned_by}</td>
                       <td>{model.model_family}</td>
                       <td>{formatBytes(model.size)}</td>
+                      <td>{formatBytes(model.shared_weights)}</td>
+                      <td>{model.instances}</td>
                       <td>{formatDate(model.expires_at)}</td>
                       <td>{model.active_streams}</td>
                     </tr>
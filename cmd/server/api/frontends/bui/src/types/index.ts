@@ -19,6 +19,8 @@ export interface ModelDetail {
   owned_by: string;
   model_family: string;
   size: number;
+  shared_weights: number;
+  instances: number;
   expires_at: string;
   active_streams: number;
 }
@@ -32,12 +34,14 @@ export interface ModelInfoResponse {
   owned_by: string;
   desc: string;
   size: number;
+  instances: number;
   has_projection: boolean;
   has_encoder: boolean;
   has_decoder: boolean;
   is_recurrent: boolean;
   is_hybrid: boolean;
   is_gpt: boolean;
+  capabilities: CatalogCapabilities;
   metadata: Record<string, string>;
 }
 
@@ -163,11 +167,18 @@ export interface ChatToolCall {
   function: ChatToolCallFunction;
 }
 
+export interface ChatToolCallDelta {
+  index: number;
+  id?: string;
+  type?: string;
+  function: Partial<ChatToolCallFunction>;
+}
+
 export interface ChatDelta {
   role?: string;
   content?: string;
   reasoning?: string;
-  tool_calls?: ChatToolCall[];
+  tool_calls?: ChatToolCallDelta[];
 }
 
 export interface ChatChoice {
@@ -178,6 +189,7 @@ export interface ChatChoice {
 
 export interface ChatUsage {
   prompt_tokens: number;
+  cached_tokens: number;
   completion_tokens: number;
   reasoning_tokens: number;
   output_tokens: number;
@@ -25,6 +25,7 @@ func (all) Add(app *web.App, cfg mux.Config) {
 	checkapp.Routes(app, checkapp.Config{
 		Build: cfg.Build,
 		Log:   cfg.Log,
+		Cache: cfg.Cache,
 	})
 
 	toolapp.Routes(app, toolapp.Config{
@@ -295,6 +295,7 @@ func chatCompletionFields() []field {
 		{Name: "messages", Type: "array", Required: true, Description: "Array of message objects. See Message Formats section below for supported formats."},
 		{Name: "stream", Type: "boolean", Required: false, Description: "Enable streaming responses (default: false)"},
 		{Name: "tools", Type: "array", Required: false, Description: "Array of tool definitions for function calling. See Tool Definitions section below."},
+		{Name: "tool_choice", Type: "string | object", Required: false, Description: "How the model uses tools: auto, none hides the tools, required forces a tool call, or {type: function, function: {name}} forces a call to that function with arguments matching its parameters schema (default: auto)"},
 	}
 
 	paramFields := paramsToFields()
@@ -426,6 +427,35 @@ func chatCompletionExamples() []example {
         }
       }
     ]
+  }'`,
+		},
+		{
+			Description: "Force a call to a function with tool_choice, the arguments are constrained to its parameters schema:",
+			Code: `curl -X POST http://localhost:8080/v1/chat/completions \
+  -H "Authorization: Bearer $KRONK_TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "model": "qwen3-8b-q8_0",
+    "messages": [
+      {"role": "user", "content": "Tokyo please"}
+    ],
+    "tools": [
+      {
+        "type": "function",
+        "function": {
+          "name": "get_weather",
+          "description": "Get the current weather for a location",
+          "parameters": {
+            "type": "object",
+            "properties": {
+              "location": {"type": "string"}
+            },
+            "required": ["location"]
+          }
+        }
+      }
+    ],
+    "tool_choice": {"type": "function", "function": {"name": "get_weather"}}
   }'`,
 		},
 	}
@@ -477,7 +507,7 @@ func chatResponseFormatsGroup() endpointGroup {
 			{
 				Method:      "",
 				Path:        "Non-Streaming Response",
-				Description: "For non-streaming requests (stream=false or omitted), the response uses the 'message' field in each choice. The 'delta' field is empty.",
+				Description: "For non-streaming requests (stream=false or omitted), the response uses the 'message' field in each choice. The 'delta' field is empty. The finish_reason is 'stop', 'tool_calls', or 'length' when max_tokens or the context window cut the response short.",
 				Examples: []example{
 					{
 						Code: `{
@@ -485,6 +515,7 @@ func chatResponseFormatsGroup() endpointGroup {
   "object": "chat.completion",
   "created": 1234567890,
   "model": "qwen3-8b-q8_0",
+  "system_fingerprint": "fp_3f2a9c1b7d4e",
   "choices": [
     {
       "index": 0,
@@ -498,6 +529,7 @@ func chatResponseFormatsGroup() endpointGroup {
   ],
   "usage": {
     "prompt_tokens": 25,
+    "cached_tokens": 0,
     "reasoning_tokens": 0,
     "completion_tokens": 12,
     "output_tokens": 12,
@@ -572,10 +604,11 @@ func responsesFields() []field {
 		{Name: "stream", Type: "boolean", Required: false, Description: "Enable streaming responses (default: false)"},
 		{Name: "instructions", Type: "string", Required: false, Description: "System instructions for the model"},
 		{Name: "tools", Type: "array", Required: false, Description: "List of tools the model can use"},
-		{Name: "tool_choice", Type: "string", Required: false, Description: "How the model should use tools: auto, none, or required"},
+		{Name: "tool_choice", Type: "string | object", Required: false, Description: "How the model uses tools: auto, none hides the tools, required forces a tool call, or {type: function, name} forces a call to that function with arguments matching its parameters schema (default: auto)"},
 		{Name: "parallel_tool_calls", Type: "boolean", Required: false, Description: "Allow parallel tool calls (default: true)"},
 		{Name: "store", Type: "boolean", Required: false, Description: "Whether to store the response (default: true)"},
-		{Name: "truncation", Type: "string", Required: false, Description: "Truncation strategy: auto or disabled (default: disabled)"},
+		{Name: "truncation", Type: "string", Required: false, Description: "Truncation strategy: auto drops the oldest turns when the input doesn't fit the context window, or disabled (default: disabled)"},
+		{Name: "text", Type: "object", Required: false, Description: "Output format: {format: {type: text | json_object | json_schema, name, schema, strict}} (default: text)"},
 	}
 
 	fields = append(fields, paramsToFields()...)
@@ -685,7 +718,7 @@ func responsesFormatsGroup() endpointGroup {
 			{
 				Method:      "",
 				Path:        "Streaming Events",
-				Description: "When stream=true, the API returns Server-Sent Events with different event types.",
+				Description: "When stream=true, the API returns Server-Sent Events with different event types. A response cut short by the output limit ends with a response.incomplete event, a status of 'incomplete' and incomplete_details.reason set to 'max_output_tokens'.",
 				Examples: []example{
 					{
 						Code: `event: response.created
@@ -729,6 +762,26 @@ data: {"type":"response.completed","response":{...}}`,
 					},
 				},
 			},
+			{
+				Method:      "",
+				Path:        "Streaming Function Calls",
+				Description: "When stream=true, a function call is streamed while the model writes it. The function_call item is added with the name first, and the arguments follow in response.function_call_arguments.delta events.",
+				Examples: []example{
+					{
+						Code: `event: response.output_item.added
+data: {"type":"response.output_item.added","item":{"type":"function_call","id":"fc_abc123","call_id":"call_xyz789","name":"get_weather","status":"in_progress"}}
+
+event: response.function_call_arguments.delta
+data: {"type":"response.function_call_arguments.delta","item_id":"fc_abc123","delta":"{\"location\":"}
+
+event: response.function_call_arguments.delta
+data: {"type":"response.function_call_arguments.delta","item_id":"fc_abc123","delta":"\"London\"}"}
+
+event: response.function_call_arguments.done
+data: {"type":"response.function_call_arguments.done","item_id":"fc_abc123","name":"get_weather","arguments":"{\"location\":\"London\"}"}`,
+					},
+				},
+			},
 		},
 	}
 }
@@ -1120,7 +1173,7 @@ func modelsEndpoints() endpointGroup {
 				},
 				Response: &response{
 					ContentType: "application/json",
-					Description: "Returns a list of running models with id, owned_by, model_family, size, expires_at, and active_streams.",
+					Description: "Returns a list of running models with id, owned_by, model_family, size, shared_weights, instances, expires_at, and active_streams.",
 				},
 				Examples: []example{
 					{
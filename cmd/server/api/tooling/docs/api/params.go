@@ -144,5 +144,19 @@ func defaultParamFields() []field {
 		{Name: "max_tokens", Type: "int", Required: false, Description: "Maximum output tokens (default: 1024)"},
 		{Name: "enable_thinking", Type: "boolean", Required: false, Description: "Enable model thinking for non-GPT models (default: true)"},
 		{Name: "reasoning_effort", Type: "string", Required: false, Description: "Reasoning level for GPT models (default: medium)"},
+		{Name: "max_reasoning_tokens", Type: "int", Required: false, Description: "Thinking budget, once the model reasoned for this many tokens it is moved on to the answer. Works with response_format and tool_choice but can't be used with grammar (default: 0, no budget)"},
+		{Name: "seed", Type: "int", Required: false, Description: "Random seed for reproducible sampling (default: random)"},
+		{Name: "presence_penalty", Type: "float32", Required: false, Description: "Penalize tokens that already appear in the output, between -2.0 and 2.0 (default: 0.0)"},
+		{Name: "frequency_penalty", Type: "float32", Required: false, Description: "Penalize tokens by how often they appear in the output, between -2.0 and 2.0 (default: 0.0)"},
+		{Name: "logit_bias", Type: "object", Required: false, Description: "Map of token ids or single-token text to a bias between -100 and 100, where -100 bans the token. Text is matched with and without a leading space, text of several tokens is rejected (default: none)"},
+		{Name: "stop", Type: "string | array", Required: false, Description: "Up to 4 sequences where generation stops, not included in the output (default: none)"},
+		{Name: "logprobs", Type: "boolean", Required: false, Description: "Return the log probability of each output token (default: false)"},
+		{Name: "top_logprobs", Type: "int", Required: false, Description: "Number of most likely tokens (0-20) to return at each position, implies logprobs (default: 0)"},
+		{Name: "response_format", Type: "object", Required: false, Description: "Constrain output to JSON: {type: text | json_object | json_schema, json_schema: {name, schema}} (default: text)"},
+		{Name: "grammar", Type: "string", Required: false, Description: "GBNF grammar with a root rule that constrains the output (default: none)"},
+		{Name: "priority", Type: "string", Required: false, Description: "Scheduling class: low, normal or high. Waiting requests start by priority, then in turns across subjects, and can pause running lower priority requests when all slots are busy (default: normal)"},
+		{Name: "tool_call_repair", Type: "boolean", Required: false, Description: "Ask the model once more when a tool call isn't valid JSON or doesn't match the parameters schema of the tool, the first attempt is held back until its tool calls are checked (default: false)"},
+		{Name: "continue_final_message", Type: "boolean", Required: false, Description: "Continue the final message when it's from the assistant, the response holds only the text that follows it (default: false)"},
+		{Name: "context_overflow", Type: "string", Required: false, Description: "When the request doesn't fit the context window: error, truncate_middle or shift (default: model config, error)"},
 	}
 }
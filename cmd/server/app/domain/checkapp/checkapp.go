@@ -7,6 +7,8 @@ import (
 	"os"
 	"runtime"
 
+	"github.com/ardanlabs/kronk/cmd/server/app/sdk/cache"
+	"github.com/ardanlabs/kronk/cmd/server/app/sdk/errs"
 	"github.com/ardanlabs/kronk/cmd/server/foundation/logger"
 	"github.com/ardanlabs/kronk/cmd/server/foundation/web"
 )
@@ -14,16 +16,27 @@ import (
 type app struct {
 	build string
 	log   *logger.Logger
+	cache *cache.Cache
 }
 
 func newApp(cfg Config) *app {
 	return &app{
 		build: cfg.Build,
 		log:   cfg.Log,
+		cache: cfg.Cache,
 	}
 }
 
+// readiness reports the service isn't ready while an unhealthy model is in the
+// cache. The check doesn't change the cache, the cache evicts and loads those
+// models again on its own.
 func (a *app) readiness(ctx context.Context, r *http.Request) web.Encoder {
+	if a.cache != nil {
+		if err := a.cache.CheckHealth(ctx); err != nil {
+			return errs.New(errs.Unavailable, err)
+		}
+	}
+
 	return nil
 }
 
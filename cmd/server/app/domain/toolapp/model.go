@@ -145,19 +145,21 @@ func toAppPull(status string, mp models.Path) string {
 
 // ModelInfoResponse returns information about a model.
 type ModelInfoResponse struct {
-	ID            string            `json:"id"`
-	Object        string            `json:"object"`
-	Created       int64             `json:"created"`
-	OwnedBy       string            `json:"owned_by"`
-	Desc          string            `json:"desc"`
-	Size          uint64            `json:"size"`
-	HasProjection bool              `json:"has_projection"`
-	HasEncoder    bool              `json:"has_encoder"`
-	HasDecoder    bool              `json:"has_decoder"`
-	IsRecurrent   bool              `json:"is_recurrent"`
-	IsHybrid      bool              `json:"is_hybrid"`
-	IsGPT         bool              `json:"is_gpt"`
-	Metadata      map[string]string `json:"metadata"`
+	ID            string              `json:"id"`
+	Object        string              `json:"object"`
+	Created       int64               `json:"created"`
+	OwnedBy       string              `json:"owned_by"`
+	Desc          string              `json:"desc"`
+	Size          uint64              `json:"size"`
+	Instances     int                 `json:"instances"`
+	HasProjection bool                `json:"has_projection"`
+	HasEncoder    bool                `json:"has_encoder"`
+	HasDecoder    bool                `json:"has_decoder"`
+	IsRecurrent   bool                `json:"is_recurrent"`
+	IsHybrid      bool                `json:"is_hybrid"`
+	IsGPT         bool                `json:"is_gpt"`
+	Capabilities  CatalogCapabilities `json:"capabilities"`
+	Metadata      map[string]string   `json:"metadata"`
 }
 
 // Encode implements the encoder interface.
@@ -174,13 +176,25 @@ func toModelInfo(model models.Info, mi model.ModelInfo) ModelInfoResponse {
 		OwnedBy:       model.OwnedBy,
 		Desc:          mi.Desc,
 		Size:          mi.Size,
+		Instances:     mi.Instances,
 		HasProjection: mi.HasProjection,
 		HasEncoder:    mi.HasEncoder,
 		HasDecoder:    mi.HasDecoder,
 		IsRecurrent:   mi.IsRecurrent,
 		IsHybrid:      mi.IsHybrid,
 		IsGPT:         mi.IsGPTModel,
-		Metadata:      mi.Metadata,
+		Capabilities: CatalogCapabilities{
+			Endpoint:  mi.Capabilities.Endpoint,
+			Images:    mi.Capabilities.Images,
+			Audio:     mi.Capabilities.Audio,
+			Video:     mi.Capabilities.Video,
+			Streaming: mi.Capabilities.Streaming,
+			Reasoning: mi.Capabilities.Reasoning,
+			Tooling:   mi.Capabilities.Tooling,
+			Embedding: mi.Capabilities.Embedding,
+			Rerank:    mi.Capabilities.Rerank,
+		},
+		Metadata: mi.Metadata,
 	}
 }
 
@@ -192,6 +206,8 @@ type ModelDetail struct {
 	OwnedBy       string    `json:"owned_by"`
 	ModelFamily   string    `json:"model_family"`
 	Size          int64     `json:"size"`
+	SharedWeights uint64    `json:"shared_weights"`
+	Instances     int       `json:"instances"`
 	ExpiresAt     time.Time `json:"expires_at"`
 	ActiveStreams int       `json:"active_streams"`
 }
@@ -214,6 +230,8 @@ func toModelDetails(models []cache.ModelDetail) ModelDetailsResponse {
 			OwnedBy:       model.OwnedBy,
 			ModelFamily:   model.ModelFamily,
 			Size:          model.Size,
+			SharedWeights: model.SharedWeights,
+			Instances:     model.Instances,
 			ExpiresAt:     model.ExpiresAt,
 			ActiveStreams: model.ActiveStreams,
 		}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -39,16 +40,23 @@ import (
 //
 // CacheTTL: Defines the time an existing model can live in the cache without
 // being used.
+//
+// HealthInterval: Defines how often the cache looks for unhealthy models to
+// evict and load again. Defaults to 30 seconds if the value is 0.
 type Config struct {
 	Log                  model.Logger
 	BasePath             string
 	Templates            *templates.Templates
 	ModelsInCache        int
 	CacheTTL             time.Duration
+	HealthInterval       time.Duration
 	IgnoreIntegrityCheck bool
 	ModelConfigFile      string
 }
 
+// healthSweepTimeout is the time the health sweep gives a model to load.
+const healthSweepTimeout = 5 * time.Minute
+
 func validateConfig(cfg Config) (Config, error) {
 	if cfg.Templates == nil {
 		templates, err := templates.New()
@@ -67,6 +75,10 @@ func validateConfig(cfg Config) (Config, error) {
 		cfg.CacheTTL = 5 * time.Minute
 	}
 
+	if cfg.HealthInterval <= 0 {
+		cfg.HealthInterval = 30 * time.Second
+	}
+
 	return cfg, nil
 }
 
@@ -89,6 +101,15 @@ type modelConfig struct {
 	OpOffload            *bool                    `yaml:"op-offload"`
 	NGpuLayers           *int32                   `yaml:"ngpu-layers"`
 	SplitMode            model.SplitMode          `yaml:"split-mode"`
+	DraftModel           string                   `yaml:"draft-model"`
+	DraftMax             int                      `yaml:"draft-max"`
+	DraftMin             int                      `yaml:"draft-min"`
+	ContextOverflow      model.ContextOverflow    `yaml:"context-overflow"`
+	PrefillBudget        int                      `yaml:"prefill-budget"`
+	StreamBacklog        int                      `yaml:"stream-backlog"`
+	MaxDecodeFailures    int                      `yaml:"max-decode-failures"`
+	ModelType            model.ModelType          `yaml:"model-type"`
+	ToolCallFormat       string                   `yaml:"tool-call-format"`
 }
 
 // Cache manages a set of Kronk APIs for use. It maintains a cache of these
@@ -101,10 +122,20 @@ type Cache struct {
 	models               *models.Models
 	ignoreIntegrityCheck bool
 	modelConfig          map[string]modelConfig
+	healthInterval       time.Duration
+	healthy              func(krn *kronk.Kronk) bool
+	shutdown             chan struct{}
+	wg                   sync.WaitGroup
 }
 
 // New constructs the manager for use.
 func New(cfg Config) (*Cache, error) {
+	return newCache(cfg, (*kronk.Kronk).Healthy)
+}
+
+// newCache constructs the manager with the function that reports if a model
+// in the cache is healthy.
+func newCache(cfg Config, healthy func(krn *kronk.Kronk) bool) (*Cache, error) {
 	cfg, err := validateConfig(cfg)
 	if err != nil {
 		return nil, err
@@ -129,6 +160,9 @@ func New(cfg Config) (*Cache, error) {
 		models:               models,
 		ignoreIntegrityCheck: cfg.IgnoreIntegrityCheck,
 		modelConfig:          mc,
+		healthInterval:       cfg.HealthInterval,
+		healthy:              healthy,
+		shutdown:             make(chan struct{}),
 	}
 
 	opt := otter.Options[string, *kronk.Kronk]{
@@ -144,6 +178,9 @@ func New(cfg Config) (*Cache, error) {
 
 	c.cache = cache
 
+	c.wg.Add(1)
+	go c.healthSweep()
+
 	return &c, nil
 }
 
@@ -155,6 +192,9 @@ func (c *Cache) Shutdown(ctx context.Context) error {
 		defer cancel()
 	}
 
+	close(c.shutdown)
+	c.wg.Wait()
+
 	c.cache.InvalidateAll()
 
 	for c.itemsInCache.Load() > 0 {
@@ -193,11 +233,15 @@ ids:
 			id := strings.ToLower(mi.ID)
 
 			if id == model.Key {
+				info := model.Value.ModelInfo()
+
 				ps = append(ps, ModelDetail{
 					ID:            mi.ID,
 					OwnedBy:       mi.OwnedBy,
 					ModelFamily:   mi.ModelFamily,
 					Size:          mi.Size,
+					SharedWeights: info.Size,
+					Instances:     info.Instances,
 					ExpiresAt:     model.ExpiresAt(),
 					ActiveStreams: model.Value.ActiveStreams(),
 				})
@@ -209,6 +253,57 @@ ids:
 	return ps, nil
 }
 
+// CheckHealth returns an error naming the models in the cache that are no
+// longer healthy. It doesn't change the cache, unhealthy models are evicted and
+// loaded again by AquireModel or by the health sweep of the cache.
+func (c *Cache) CheckHealth(ctx context.Context) error {
+	unhealthy := c.unhealthyModels()
+	if len(unhealthy) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("check-health: unhealthy models: %s", strings.Join(unhealthy, ", "))
+}
+
+func (c *Cache) unhealthyModels() []string {
+	var unhealthy []string
+	for key, krn := range c.cache.All() {
+		if !c.healthy(krn) {
+			unhealthy = append(unhealthy, key)
+		}
+	}
+
+	return unhealthy
+}
+
+// healthSweep reloads the unhealthy models in the cache on an interval, so a
+// model recovers even when no request for it comes in.
+func (c *Cache) healthSweep() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.healthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.shutdown:
+			return
+
+		case <-ticker.C:
+			for _, key := range c.unhealthyModels() {
+				ctx, cancel := context.WithTimeout(context.Background(), healthSweepTimeout)
+
+				c.log(ctx, "health-sweep", "status", "reload unhealthy model", "model-name", key)
+				if _, err := c.AquireModel(ctx, key); err != nil {
+					c.log(ctx, "health-sweep", "model-name", key, "ERROR", err)
+				}
+
+				cancel()
+			}
+		}
+	}
+}
+
 // AquireModel will provide a kronk API for the specified model. If the model
 // is not in the cache, an API for the model will be created.
 func (c *Cache) AquireModel(ctx context.Context, modelID string) (*kronk.Kronk, error) {
@@ -216,7 +311,13 @@ func (c *Cache) AquireModel(ctx context.Context, modelID string) (*kronk.Kronk,
 
 	krn, exists := c.cache.GetIfPresent(modelID)
 	if exists {
-		return krn, nil
+		if c.healthy(krn) {
+			return krn, nil
+		}
+
+		// An unhealthy model is evicted and loaded again.
+		c.log(ctx, "acquire-model", "status", "evict unhealthy model", "model-name", modelID)
+		c.cache.Invalidate(modelID)
 	}
 
 	fi, err := c.models.RetrievePath(modelID)
@@ -259,6 +360,23 @@ func (c *Cache) AquireModel(ctx context.Context, modelID string) (*kronk.Kronk,
 		OpOffload:            mc.OpOffload,
 		NGpuLayers:           mc.NGpuLayers,
 		SplitMode:            mc.SplitMode,
+		DraftMax:             mc.DraftMax,
+		DraftMin:             mc.DraftMin,
+		ContextOverflow:      mc.ContextOverflow,
+		PrefillBudget:        mc.PrefillBudget,
+		StreamBacklog:        mc.StreamBacklog,
+		MaxDecodeFailures:    mc.MaxDecodeFailures,
+		ModelType:            mc.ModelType,
+		ToolCallFormat:       mc.ToolCallFormat,
+	}
+
+	if mc.DraftModel != "" {
+		dfi, err := c.models.RetrievePath(strings.ToLower(mc.DraftModel))
+		if err != nil {
+			return nil, fmt.Errorf("acquire-model: unable to retrieve draft model path: %w", err)
+		}
+
+		cfg.DraftModelFiles = dfi.ModelFiles
 	}
 
 	krn, err = kronk.New(cfg,
@@ -26,6 +26,7 @@ func Test_Cache(t *testing.T) {
 	t.Run("acquire-model", acquireModel)
 	t.Run("shutdown", shutdown)
 	t.Run("eviction", eviction)
+	t.Run("health", health)
 }
 
 func newManager(t *testing.T) {
@@ -101,6 +102,31 @@ func acquireModel(t *testing.T) {
 		}
 	})
 
+	t.Run("model status", func(t *testing.T) {
+		ctx := context.Background()
+		k, err := mgr.AquireModel(ctx, modelID)
+		if err != nil {
+			t.Fatalf("expected no error acquiring model, got: %v", err)
+		}
+
+		ps, err := mgr.ModelStatus()
+		if err != nil {
+			t.Fatalf("expected no error retrieving model status, got: %v", err)
+		}
+
+		if len(ps) != 1 {
+			t.Fatalf("expected 1 model in the cache, got: %d", len(ps))
+		}
+
+		if got, want := ps[0].Instances, k.ModelInfo().Instances; got != want || got < 1 {
+			t.Errorf("expected %d instances, got: %d", want, got)
+		}
+
+		if ps[0].SharedWeights == 0 {
+			t.Error("expected the size of the shared weights")
+		}
+	})
+
 	t.Run("acquire non-existent model", func(t *testing.T) {
 		ctx := context.Background()
 		_, err := mgr.AquireModel(ctx, "non-existent-model-xyz")
@@ -329,6 +355,145 @@ func eviction(t *testing.T) {
 	})
 }
 
+func health(t *testing.T) {
+	modelID := findAvailableModel(t, "")
+
+	// The models stored here are reported as unhealthy.
+	var unhealthy sync.Map
+	healthy := func(krn *kronk.Kronk) bool {
+		_, exists := unhealthy.Load(krn)
+		return !exists
+	}
+
+	t.Run("evict and reload on acquire", func(t *testing.T) {
+		cfg := cache.Config{
+			Log:           log,
+			ModelsInCache: 1,
+			CacheTTL:      5 * time.Minute,
+		}
+
+		mgr, err := cache.NewWithHealth(cfg, healthy)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		defer mgr.Shutdown(context.Background())
+
+		ctx := context.Background()
+
+		k1, err := mgr.AquireModel(ctx, modelID)
+		if err != nil {
+			t.Fatalf("expected no error acquiring model, got: %v", err)
+		}
+
+		unhealthy.Store(k1, true)
+
+		k2, err := mgr.AquireModel(ctx, modelID)
+		if err != nil {
+			t.Fatalf("expected no error re-acquiring model, got: %v", err)
+		}
+
+		if k1 == k2 {
+			t.Fatal("same instance returned, unhealthy model should be loaded again")
+		}
+
+		if err := mgr.CheckHealth(ctx); err != nil {
+			t.Fatalf("expected healthy cache after reload, got: %v", err)
+		}
+	})
+
+	t.Run("check health keeps the cache", func(t *testing.T) {
+		cfg := cache.Config{
+			Log:           log,
+			ModelsInCache: 1,
+			CacheTTL:      5 * time.Minute,
+		}
+
+		mgr, err := cache.NewWithHealth(cfg, healthy)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		defer mgr.Shutdown(context.Background())
+
+		ctx := context.Background()
+
+		k1, err := mgr.AquireModel(ctx, modelID)
+		if err != nil {
+			t.Fatalf("expected no error acquiring model, got: %v", err)
+		}
+
+		if err := mgr.CheckHealth(ctx); err != nil {
+			t.Fatalf("expected healthy cache, got: %v", err)
+		}
+
+		unhealthy.Store(k1, true)
+
+		if err := mgr.CheckHealth(ctx); err == nil {
+			t.Fatal("expected an error for the unhealthy model")
+		}
+
+		ps, err := mgr.ModelStatus()
+		if err != nil {
+			t.Fatalf("expected no error retrieving model status, got: %v", err)
+		}
+
+		if len(ps) != 1 {
+			t.Fatalf("expected the unhealthy model to stay in the cache, got: %#v", ps)
+		}
+	})
+
+	t.Run("evict and reload on health sweep", func(t *testing.T) {
+		cfg := cache.Config{
+			Log:            log,
+			ModelsInCache:  1,
+			CacheTTL:       5 * time.Minute,
+			HealthInterval: 100 * time.Millisecond,
+		}
+
+		mgr, err := cache.NewWithHealth(cfg, healthy)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		defer mgr.Shutdown(context.Background())
+
+		ctx := context.Background()
+
+		k1, err := mgr.AquireModel(ctx, modelID)
+		if err != nil {
+			t.Fatalf("expected no error acquiring model, got: %v", err)
+		}
+
+		unhealthy.Store(k1, true)
+
+		// The cache is empty while the sweep loads the model again.
+		reloaded := func() bool {
+			ps, err := mgr.ModelStatus()
+			if err != nil {
+				t.Fatalf("expected no error retrieving model status, got: %v", err)
+			}
+
+			return len(ps) == 1 && mgr.CheckHealth(ctx) == nil
+		}
+
+		t.Log("waiting for the health sweep to reload the model...")
+		deadline := time.Now().Add(time.Minute)
+		for !reloaded() {
+			if time.Now().After(deadline) {
+				t.Fatal("health sweep didn't reload the unhealthy model")
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		k2, err := mgr.AquireModel(ctx, modelID)
+		if err != nil {
+			t.Fatalf("expected no error acquiring model, got: %v", err)
+		}
+
+		if k1 == k2 {
+			t.Fatal("same instance returned, health sweep should have loaded the model again")
+		}
+	})
+}
+
 // =============================================================================
 
 func initKronk(t *testing.T) model.Logger {
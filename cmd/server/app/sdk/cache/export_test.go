@@ -0,0 +1,5 @@
+package cache
+
+// NewWithHealth constructs the manager with a function that reports if a
+// model is healthy, so tests can fail a model.
+var NewWithHealth = newCache
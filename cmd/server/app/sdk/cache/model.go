@@ -8,6 +8,8 @@ type ModelDetail struct {
 	OwnedBy       string
 	ModelFamily   string
 	Size          int64
+	SharedWeights uint64
+	Instances     int
 	ExpiresAt     time.Time
 	ActiveStreams int
 }
@@ -5,6 +5,7 @@ import (
 	"context"
 
 	"github.com/ardanlabs/kronk/cmd/server/foundation/web"
+	"github.com/ardanlabs/kronk/sdk/kronk/model"
 )
 
 func checkIsError(e web.Encoder) error {
@@ -24,7 +25,10 @@ const (
 	subjectKey ctxKey = iota + 1
 )
 
+// setSubject also stores the subject for the model so the batch engine can
+// schedule requests fairly across subjects.
 func setSubject(ctx context.Context, subject string) context.Context {
+	ctx = model.SetSubject(ctx, subject)
 	return context.WithValue(ctx, subjectKey, subject)
 }
 
@@ -11,9 +11,8 @@ import (
 )
 
 // Chat provides support to interact with an inference model.
-// For text models, NSeqMax controls parallel sequence processing within a single
-// model instance. For vision/audio models, NSeqMax creates multiple model
-// instances in a pool for concurrent request handling.
+// NSeqMax controls parallel sequence processing within a single model instance,
+// for text requests as well as requests with vision or audio content.
 func (krn *Kronk) Chat(ctx context.Context, d model.D) (model.ChatResponse, error) {
 	if _, exists := ctx.Deadline(); !exists {
 		return model.ChatResponse{}, fmt.Errorf("chat: context has no deadline, provide a reasonable timeout")
@@ -27,9 +26,8 @@ func (krn *Kronk) Chat(ctx context.Context, d model.D) (model.ChatResponse, erro
 }
 
 // ChatStreaming provides support to interact with an inference model.
-// For text models, NSeqMax controls parallel sequence processing within a single
-// model instance. For vision/audio models, NSeqMax creates multiple model
-// instances in a pool for concurrent request handling.
+// NSeqMax controls parallel sequence processing within a single model instance,
+// for text requests as well as requests with vision or audio content.
 func (krn *Kronk) ChatStreaming(ctx context.Context, d model.D) (<-chan model.ChatResponse, error) {
 	if _, exists := ctx.Deadline(); !exists {
 		return nil, fmt.Errorf("chat-streaming: context has no deadline, provide a reasonable timeout")
@@ -47,9 +45,8 @@ func (krn *Kronk) ChatStreaming(ctx context.Context, d model.D) (<-chan model.Ch
 }
 
 // ChatStreamingHTTP provides http handler support for a chat/completions call.
-// For text models, NSeqMax controls parallel sequence processing within a single
-// model instance. For vision/audio models, NSeqMax creates multiple model
-// instances in a pool for concurrent request handling.
+// NSeqMax controls parallel sequence processing within a single model instance,
+// for text requests as well as requests with vision or audio content.
 func (krn *Kronk) ChatStreamingHTTP(ctx context.Context, w http.ResponseWriter, d model.D) (model.ChatResponse, error) {
 	if _, exists := ctx.Deadline(); !exists {
 		return model.ChatResponse{}, fmt.Errorf("chat-streaming-http: context has no deadline, provide a reasonable timeout")
@@ -109,8 +106,16 @@ func (krn *Kronk) ChatStreamingHTTP(ctx context.Context, w http.ResponseWriter,
 
 		// OpenAI does not expect the final chunk to have a message field.
 		// The delta should be empty {} per OpenAI spec.
-		if resp.Choice[0].FinishReason() == model.FinishReasonStop {
+		switch resp.Choice[0].FinishReason() {
+		case model.FinishReasonStop, model.FinishReasonLength:
 			resp.Choice[0].Message = nil
+
+		// The tool calls were already streamed as deltas, clients that add
+		// up the deltas would get the arguments twice.
+		case model.FinishReasonTool:
+			delta := *resp.Choice[0].Delta
+			delta.ToolCalls = nil
+			resp.Choice[0].Delta = &delta
 		}
 
 		d, err := json.Marshal(resp)
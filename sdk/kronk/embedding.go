@@ -17,10 +17,10 @@ import (
 //   - truncate_direction (string): "right" (default) or "left"
 //   - dimensions (int): reduce output to first N dimensions (for Matryoshka models)
 //
-// Each model instance processes calls sequentially (llama.cpp only supports
-// sequence 0 for embedding extraction). Use NSeqMax > 1 to create multiple
-// model instances for concurrent request handling. Batch multiple texts in the
-// input parameter for better performance within a single request.
+// Each model instance processes calls sequentially and packs the texts of a
+// call into batches across sequences. Use NSeqMax > 1 to create multiple model
+// instances for concurrent request handling. Batch multiple texts in a single
+// request for better performance.
 func (krn *Kronk) Embeddings(ctx context.Context, d model.D) (model.EmbedReponse, error) {
 	if !krn.ModelInfo().IsEmbedModel {
 		return model.EmbedReponse{}, fmt.Errorf("embeddings: model doesn't support embedding")
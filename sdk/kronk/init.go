@@ -7,6 +7,7 @@ import (
 	"runtime"
 	"strings"
 
+	"github.com/ardanlabs/kronk/sdk/kronk/model"
 	"github.com/ardanlabs/kronk/sdk/tools/libs"
 	"github.com/hybridgroup/yzma/pkg/llama"
 	"github.com/hybridgroup/yzma/pkg/mtmd"
@@ -71,6 +72,12 @@ func Init(opts ...InitOption) error {
 		libraryLocation = libPath
 		llama.Init()
 
+		// The version is only used to identify the library in responses, so
+		// a missing version file isn't an error.
+		if tag, err := libs.ReadVersion(libPath); err == nil {
+			model.SetLibraryVersion(tag.Version)
+		}
+
 		// ---------------------------------------------------------------------
 
 		if o.logLevel < 1 || o.logLevel > 2 {
@@ -100,7 +100,7 @@ func New(cfg model.Config, opts ...Option) (*Kronk, error) {
 	}
 
 	// -------------------------------------------------------------------------
-	// Determine if this is a sequential model (embed/rerank/vision) that
+	// Determine if this is a sequential model (embed/rerank) that
 	// benefits from instance pooling rather than batch parallelism.
 
 	// We need to check model info, so create the first instance.
@@ -109,16 +109,13 @@ func New(cfg model.Config, opts ...Option) (*Kronk, error) {
 		return nil, err
 	}
 
-	isSingleFlight := cfg.ProjFile != ""
-
 	mi := firstModel.ModelInfo()
-	if mi.IsEmbedModel || mi.IsRerankModel {
-		isSingleFlight = true
-	}
+	isSingleFlight := mi.IsEmbedModel || mi.IsRerankModel
 
 	// -------------------------------------------------------------------------
 	// For sequential models with NSeqMax > 1, create a pool of model instances.
-	// For text models, NSeqMax controls batch parallelism within a single instance.
+	// For text, vision and audio models, NSeqMax controls batch parallelism
+	// within a single instance.
 
 	var (
 		models      = []*model.Model{firstModel}
@@ -132,11 +129,13 @@ func New(cfg model.Config, opts ...Option) (*Kronk, error) {
 		semCapacity = numInstances
 
 		if numInstances > 1 {
+			// The instances share the weights of the first model and
+			// only add their own context.
 			pool = make(chan *model.Model, numInstances)
 			pool <- firstModel
 
 			for range numInstances - 1 {
-				m, err := model.NewModel(ctx, o.tr, cfg)
+				m, err := firstModel.NewInstance(ctx)
 				if err != nil {
 					for _, mdl := range models {
 						mdl.Unload(ctx)
@@ -159,7 +158,7 @@ func New(cfg model.Config, opts ...Option) (*Kronk, error) {
 		models:    models,
 		pool:      pool,
 		sem:       make(chan struct{}, semCapacity),
-		modelInfo: mi,
+		modelInfo: firstModel.ModelInfo(),
 	}
 
 	return &krn, nil
@@ -206,6 +205,18 @@ func (krn *Kronk) ModelInfo() model.ModelInfo {
 	return krn.modelInfo
 }
 
+// Healthy returns false when a model instance failed to decode too many
+// batches in a row. An unhealthy Kronk should be unloaded and created again.
+func (krn *Kronk) Healthy() bool {
+	for _, m := range krn.models {
+		if !m.Healthy() {
+			return false
+		}
+	}
+
+	return true
+}
+
 // ActiveStreams returns the number of active streams.
 func (krn *Kronk) ActiveStreams() int {
 	return int(krn.activeStreams.Load())
@@ -1,8 +1,11 @@
 package model
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -17,6 +20,10 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
+// ErrSlowClient is returned when a streaming client falls too far behind
+// reading the responses of a request.
+var ErrSlowClient = errors.New("client is not reading the stream fast enough")
+
 // chatJob represents a validated chat request ready for batch processing.
 type chatJob struct {
 	id      string
@@ -28,6 +35,27 @@ type chatJob struct {
 	params  params
 	mtmdCtx mtmd.Context
 	ch      chan<- ChatResponse
+
+	// overflow is set when the prompt was truncated to fit the context
+	// window before the job was submitted.
+	overflow ContextOverflow
+
+	// subject identifies who made the request so pending jobs can be
+	// scheduled fairly, queued is when the job was submitted.
+	subject string
+	queued  time.Time
+
+	// parked holds the state of the request when its slot was preempted
+	// by a higher priority request.
+	parked *slot
+}
+
+// freeMedia releases the projection context loaded for the job.
+func (job *chatJob) freeMedia() {
+	if job.mtmdCtx != 0 {
+		mtmd.Free(job.mtmdCtx)
+		job.mtmdCtx = 0
+	}
 }
 
 // slot represents a processing slot for parallel inference.
@@ -55,6 +83,16 @@ type slot struct {
 	finalReasoning strings.Builder
 	finalTooling   strings.Builder
 	respToolCalls  []ResponseToolCall
+	logprobs       []ContentLogprob
+
+	// toolStream streams the tool calls while the model writes them.
+	toolStream toolCallStream
+
+	// stop holds back completion content that could be the start of a
+	// stop sequence, heldLogprobs are the log probabilities of the tokens
+	// behind that content.
+	stop         *stopMatcher
+	heldLogprobs []ContentLogprob
 
 	startTime   time.Time
 	span        trace.Span
@@ -65,6 +103,60 @@ type slot struct {
 
 	prefillTokens []llama.Token
 	nPrefilled    int
+
+	// cachedTokens mirrors the tokens currently held in this slot's KV
+	// sequence. It survives reset so the next request with a matching
+	// prompt prefix only needs to decode the new suffix.
+	cachedTokens []llama.Token
+	nCached      int
+	lastUsed     time.Time
+
+	// nKeep is the number of system prompt tokens a context shift keeps,
+	// it's -1 until the first shift. overflow is the strategy applied when
+	// the request didn't fit the context window.
+	nKeep    int
+	overflow ContextOverflow
+
+	// length is set when max_tokens or the context window ends the
+	// generation.
+	length bool
+
+	// drafts are the tokens proposed by the draft model that follow the
+	// sampled token in the current batch.
+	drafts []llama.Token
+
+	// resumeTokens are the prompt and generated tokens of a preempted
+	// request that are decoded again when it resumes. resuming is set
+	// during that prefill so no token is sampled at the end of it.
+	resumeTokens []llama.Token
+	resuming     bool
+
+	// inBatch is set when the slot has tokens in the batch being built.
+	inBatch bool
+
+	// media is set when the prompt had media encoded into the slot's KV
+	// sequence. These slots can't be drafted, preempted or shifted since
+	// their prompt can't be decoded again from tokens. mediaSteps are the
+	// parts of the prompt that aren't prefilled yet.
+	media      bool
+	mediaSteps []mediaStep
+}
+
+// mediaStep is a part of the prompt of a media request. It's either text or
+// the chunks of a media item, nTokens is the number of tokens of either.
+type mediaStep struct {
+	tokens  []llama.Token
+	chunks  mtmd.InputChunks
+	nTokens int
+}
+
+// freeMediaSteps releases the chunks of the media that wasn't evaluated.
+func freeMediaSteps(steps []mediaStep) {
+	for _, step := range steps {
+		if step.chunks != 0 {
+			mtmd.InputChunksFree(step.chunks)
+		}
+	}
 }
 
 func (s *slot) reset() {
@@ -83,6 +175,10 @@ func (s *slot) reset() {
 	s.finalReasoning.Reset()
 	s.finalTooling.Reset()
 	s.respToolCalls = nil
+	s.toolStream = toolCallStream{}
+	s.logprobs = nil
+	s.stop = nil
+	s.heldLogprobs = nil
 	s.span = nil
 	s.iBatch = -1
 	s.sampled = 0
@@ -90,6 +186,17 @@ func (s *slot) reset() {
 	s.prefillDone = false
 	s.prefillTokens = nil
 	s.nPrefilled = 0
+	s.nCached = 0
+	s.nKeep = -1
+	s.overflow = ""
+	s.length = false
+	s.drafts = nil
+	s.resumeTokens = nil
+	s.resuming = false
+	s.inBatch = false
+	s.media = false
+	freeMediaSteps(s.mediaSteps)
+	s.mediaSteps = nil
 
 	if s.proc != nil {
 		s.proc.resetState()
@@ -103,9 +210,14 @@ type batchEngine struct {
 	slots      []*slot
 	batch      llama.Batch
 	requestQ   chan *chatJob
+	pending    jobQueue
+	maxPending int
 	shutdownCh chan struct{}
 	wg         sync.WaitGroup
 	stopped    atomic.Bool
+
+	// decodeFailures counts the batches that failed to decode in a row.
+	decodeFailures int
 }
 
 // newBatchEngine creates a new batch engine for parallel inference.
@@ -131,6 +243,7 @@ func newBatchEngine(m *Model, nSlots int) *batchEngine {
 		slots:      slots,
 		batch:      batch,
 		requestQ:   make(chan *chatJob, nSlots*2),
+		maxPending: nSlots * 2,
 		shutdownCh: make(chan struct{}),
 	}
 }
@@ -169,6 +282,8 @@ func (e *batchEngine) freeBatch() {
 
 // submit adds a job to the processing queue.
 func (e *batchEngine) submit(job *chatJob) error {
+	job.queued = time.Now()
+
 	select {
 	case e.requestQ <- job:
 		return nil
@@ -200,22 +315,29 @@ func (e *batchEngine) processLoop(ctx context.Context) {
 	defer timer.Stop()
 
 	for {
+		// Jobs stay on requestQ while the pending queue is full so submit
+		// blocks once both are full.
+		var requestQ chan *chatJob
+		if e.pending.len() < e.maxPending {
+			requestQ = e.requestQ
+		}
+
 		select {
 		case <-e.shutdownCh:
 			e.drainSlots()
 			return
 
-		case job := <-e.requestQ:
-			// Requeue job for fillSlots to handle in correct order
+		case job := <-requestQ:
+			// Queue the job for fillSlots to handle in correct order
 			// (after batchClear but before decode).
 			// Wake up the goroutine instantly.
-			e.requestQ <- job
+			e.pending.push(job)
 
 			// This will immediately trigger the timer.
 			timer.Reset(0)
 
 		case <-timer.C:
-			switch e.hasActiveSlots() || len(e.requestQ) > 0 {
+			switch e.hasActiveSlots() || e.hasPendingJobs() {
 			case true:
 				e.processBatch(ctx, buf)
 				timer.Reset(activeInterval)
@@ -227,6 +349,11 @@ func (e *batchEngine) processLoop(ctx context.Context) {
 	}
 }
 
+// hasPendingJobs returns true if any job is waiting for a slot.
+func (e *batchEngine) hasPendingJobs() bool {
+	return e.pending.len() > 0 || len(e.requestQ) > 0
+}
+
 // hasActiveSlots returns true if any slot is currently processing.
 func (e *batchEngine) hasActiveSlots() bool {
 	for _, s := range e.slots {
@@ -239,12 +366,22 @@ func (e *batchEngine) hasActiveSlots() bool {
 
 // processBatch handles one iteration of the batch processing loop.
 func (e *batchEngine) processBatch(ctx context.Context, buf []byte) {
+	// Drop the pending jobs the client gave up on.
+	e.dropCancelled()
+
+	// Make room for higher priority work when all slots are busy.
+	e.preemptSlot()
+
 	// Clear the batch.
 	batchClear(&e.batch)
+	for _, s := range e.slots {
+		s.inBatch = false
+	}
 
-	// Continue prefill for slots that are still prefilling.
+	// Add tokens from active slots that have completed prefill. Decode
+	// tokens go first so generating slots keep a steady pace.
 	for _, s := range e.slots {
-		if !s.active || s.prefillTokens == nil {
+		if !s.active || !s.prefillDone {
 			continue
 		}
 
@@ -254,29 +391,50 @@ func (e *batchEngine) processBatch(ctx context.Context, buf []byte) {
 			continue
 		}
 
-		e.addPrefillChunk(s)
-	}
-
-	// Add tokens from active slots that have completed prefill.
-	for _, s := range e.slots {
-		if !s.active || !s.prefillDone {
+		// Check if client fell too far behind.
+		if n := len(s.job.ch); n > e.model.cfg.StreamBacklog {
+			e.finishSlot(s, fmt.Errorf("process-batch: %w: responses[%d] backlog[%d]", ErrSlowClient, n, e.model.cfg.StreamBacklog))
 			continue
 		}
 
+		s.drafts = e.draftTokens(s)
+
+		s.inBatch = true
+		s.iBatch = e.batch.NTokens
+		batchAdd(&e.batch, s.sampled, s.nPast, []llama.SeqId{s.seqID}, true)
+		s.cachedTokens = append(s.cachedTokens, s.sampled)
+		s.nPast++
+		s.nDecoded++
+
+		for _, token := range s.drafts {
+			batchAdd(&e.batch, token, s.nPast, []llama.SeqId{s.seqID}, true)
+			s.cachedTokens = append(s.cachedTokens, token)
+			s.nPast++
+		}
+	}
+
+	// Share what is left of the batch between the slots that are still
+	// prefilling, then start new requests with the rest.
+	budget := e.prefillBudget()
+
+	for _, s := range e.prefillingSlots() {
 		// Check if client cancelled.
 		if s.job.ctx.Err() != nil {
 			e.finishSlot(s, s.job.ctx.Err())
 			continue
 		}
 
-		s.iBatch = e.batch.NTokens
-		batchAdd(&e.batch, s.sampled, s.nPast, []llama.SeqId{s.seqID}, true)
-		s.nPast++
-		s.nDecoded++
+		switch {
+		case s.media:
+			budget -= e.addMediaPrefill(s, budget, buf)
+
+		default:
+			budget -= e.addPrefillChunk(s, budget)
+		}
 	}
 
 	// Fill empty slots from queue.
-	e.fillSlots()
+	e.fillSlots(budget, buf)
 
 	// Nothing to process.
 	if e.batch.NTokens == 0 {
@@ -286,32 +444,184 @@ func (e *batchEngine) processBatch(ctx context.Context, buf []byte) {
 	// Decode the batch.
 	ret, err := llama.Decode(e.model.lctx, e.batch)
 	if err != nil || ret != 0 {
-		e.model.log(ctx, "batch-engine", "status", "decode-error", "ret", ret, "err", err)
+		e.recoverDecode(ctx, ret, err)
 		return
 	}
 
+	e.decodeFailures = 0
+
 	// Sample tokens for each active slot.
 	for _, s := range e.slots {
 		if s.iBatch < 0 || !s.active {
 			continue
 		}
 
+		if len(s.drafts) > 0 {
+			e.verifySlotDrafts(s, buf)
+			continue
+		}
+
 		e.processSlotToken(s, buf)
 	}
 }
 
-// fillSlots assigns pending requests to available slots.
-func (e *batchEngine) fillSlots() {
+// prefillBudget returns the number of prompt tokens that can be added to the
+// batch after the decode tokens. While slots are generating, the budget is
+// capped by the PrefillBudget setting so a long prompt doesn't stall them.
+func (e *batchEngine) prefillBudget() int {
+	budget := e.model.cfg.NBatch - int(e.batch.NTokens)
+
+	if e.batch.NTokens > 0 && e.model.cfg.PrefillBudget > 0 {
+		budget = min(budget, e.model.cfg.PrefillBudget)
+	}
+
+	return max(budget, 0)
+}
+
+// prefillingSlots returns the slots that are still prefilling, ordered by
+// priority and then by start time so the oldest request gets its first token
+// first.
+func (e *batchEngine) prefillingSlots() []*slot {
+	var slots []*slot
 	for _, s := range e.slots {
-		if s.active {
+		if s.active && (s.prefillTokens != nil || len(s.mediaSteps) > 0) {
+			slots = append(slots, s)
+		}
+	}
+
+	slices.SortFunc(slots, func(a, b *slot) int {
+		if a.job.params.Priority != b.job.params.Priority {
+			return cmp.Compare(b.job.params.Priority, a.job.params.Priority)
+		}
+		return a.startTime.Compare(b.startTime)
+	})
+
+	return slots
+}
+
+// draftTokens asks the draft model for tokens that follow the slot's sampled
+// token. The number of drafts is limited by the tokens the request has left
+// and the room left in the batch. The drafts never fill the context window so
+// a context shift can't happen while they are verified.
+func (e *batchEngine) draftTokens(s *slot) []llama.Token {
+	d := e.model.draft
+	if d == nil || s.media {
+		return nil
+	}
+
+	room := min(
+		s.job.params.MaxTokens-s.nDecoded-1,
+		e.model.cfg.ContextWindow-int(s.nPast)-2,
+		e.model.cfg.NBatch-int(e.batch.NTokens)-1,
+	)
+
+	n := d.maxDraft(room)
+	if n == 0 {
+		return nil
+	}
+
+	drafts := d.draft(s.seqID, s.cachedTokens, s.sampled, n)
+	if len(drafts) < d.nMin {
+		return nil
+	}
+
+	return drafts
+}
+
+// verifySlotDrafts processes the tokens the model sampled at the positions of
+// the slot's drafts until a sampled token doesn't match the draft. The
+// rejected drafts are then removed from the slot's KV sequence.
+func (e *batchEngine) verifySlotDrafts(s *slot, buf []byte) {
+	drafts := s.drafts
+	s.drafts = nil
+
+	iBatch := s.iBatch
+	nPast := s.nPast
+
+	var accepted int
+	for i := 0; ; i++ {
+		s.iBatch = iBatch + int32(i)
+		token := e.processSlotToken(s, buf)
+
+		if !s.active || i == len(drafts) || token != drafts[i] {
+			break
+		}
+
+		// The draft was decoded as the input that follows this token.
+		accepted++
+		s.nDecoded++
+	}
+
+	metrics.AddDraftAcceptance(len(drafts), accepted)
+
+	keep, ok := s.dropRejectedDrafts(nPast, len(drafts)-accepted)
+	if !ok {
+		return
+	}
+
+	ok, err := llama.MemorySeqRm(e.model.mem, s.seqID, keep, -1)
+	if err != nil || !ok {
+		llama.MemorySeqRm(e.model.mem, s.seqID, -1, -1)
+		s.cachedTokens = s.cachedTokens[:0]
+		e.finishSlot(s, fmt.Errorf("verify-slot-drafts: unable to remove rejected draft tokens: %w", err))
+		return
+	}
+
+	s.nPast = keep
+}
+
+// dropRejectedDrafts removes the rejected drafts from the slot's cached tokens
+// and returns the position the slot's KV sequence is trimmed from. A slot that
+// finished while its drafts were verified is left alone, finishSlot already
+// trimmed its cache to what the next request can reuse.
+func (s *slot) dropRejectedDrafts(nPast llama.Pos, rejected int) (llama.Pos, bool) {
+	if rejected == 0 || !s.active {
+		return 0, false
+	}
+
+	s.cachedTokens = s.cachedTokens[:len(s.cachedTokens)-rejected]
+
+	return nPast - llama.Pos(rejected), true
+}
+
+// fillSlots assigns pending requests to available slots while there is room
+// in the batch for their prompt tokens. The next job is chosen by priority and
+// then round-robin across subjects.
+func (e *batchEngine) fillSlots(budget int, buf []byte) {
+	e.queueSubmitted()
+
+	for budget > 0 && e.hasIdleSlots() {
+		job := e.pending.pop()
+		if job == nil {
+			return
+		}
+
+		metrics.AddQueueWaitTime(job.params.Priority.String(), time.Since(job.queued))
+
+		if job.parked != nil {
+			parked := job.parked
+			budget -= e.resumeSlot(e.selectSlot(parked.resumeTokens), parked, budget)
 			continue
 		}
 
-		// Try to get a request from the queue.
+		if len(job.media) > 0 {
+			budget -= e.startMediaSlot(e.selectSlot(nil), job, budget, buf)
+			continue
+		}
+
+		tokens := llama.Tokenize(e.model.vocab, job.prompt, true, true)
+		s := e.selectSlot(tokens)
+		budget -= e.startSlot(s, job, tokens, budget)
+	}
+}
+
+// queueSubmitted moves the jobs waiting on requestQ into the pending queue
+// while there is room for them.
+func (e *batchEngine) queueSubmitted() {
+	for e.pending.len() < e.maxPending {
 		select {
 		case job := <-e.requestQ:
-			e.startSlot(s, job)
-			return // Only prefill one slot per iteration to avoid exceeding NBatch
+			e.pending.push(job)
 
 		default:
 			return
@@ -319,12 +629,246 @@ func (e *batchEngine) fillSlots() {
 	}
 }
 
-// startSlot initializes a slot with a new request.
-func (e *batchEngine) startSlot(s *slot, job *chatJob) {
+// hasIdleSlots returns true if any slot is available for a new request.
+func (e *batchEngine) hasIdleSlots() bool {
+	for _, s := range e.slots {
+		if !s.active {
+			return true
+		}
+	}
+	return false
+}
+
+// selectSlot picks the idle slot whose cached KV state shares the longest
+// token prefix with the specified prompt. When no slot has a usable prefix,
+// the least recently used idle slot is chosen so hot caches are preserved.
+func (e *batchEngine) selectSlot(tokens []llama.Token) *slot {
+	var best *slot
+	bestPrefix := 0
+
+	for _, s := range e.slots {
+		if s.active {
+			continue
+		}
+
+		n := commonPrefix(s.cachedTokens, tokens)
+
+		switch {
+		case best == nil:
+			best = s
+			bestPrefix = n
+
+		case n > bestPrefix:
+			best = s
+			bestPrefix = n
+
+		case n == bestPrefix && bestPrefix == 0 && s.lastUsed.Before(best.lastUsed):
+			best = s
+		}
+	}
+
+	return best
+}
+
+// startSlot initializes a slot with a new request and adds the first chunk of
+// the prompt to the batch. Returns the number of prompt tokens added.
+func (e *batchEngine) startSlot(s *slot, job *chatJob, tokens []llama.Token, budget int) int {
+	e.initSlot(s, job)
+
+	s.nPrompt = len(tokens)
+
+	// Check context window.
+	if s.nPrompt > e.model.cfg.ContextWindow {
+		err := fmt.Errorf("start-slot: input tokens [%d] exceed context window [%d]", s.nPrompt, e.model.cfg.ContextWindow)
+		e.finishSlot(s, err)
+		return 0
+	}
+
+	// Reuse whatever part of the prompt is already in this slot's KV cache.
+	nCached := e.reuseCachedPrefix(s, tokens)
+
+	// Store tokens for chunked prefill, skipping the cached prefix.
+	s.prefillTokens = tokens
+	s.nPrefilled = nCached
+	s.nPast = llama.Pos(nCached)
+	s.nCached = nCached
+
+	s.span.SetAttributes(attribute.Int("cached_tokens", nCached))
+
+	// Add first chunk of prompt tokens to batch.
+	n := e.addPrefillChunk(s, budget)
+
+	e.model.log(job.ctx, "batch-engine", "status", "slot-started", "slot", s.id, "id", job.id, "prompt_tokens", s.nPrompt, "cached_tokens", nCached)
+
+	return n
+}
+
+// startMediaSlot initializes a slot with a request that has media. The prompt
+// is split at the media markers. The text goes through the batch like any
+// other prompt, while each media item is encoded and decoded into the slot's
+// sequence by the mtmd helper in an iteration of its own, so the other slots
+// keep generating in between. Returns the part of the budget used.
+func (e *batchEngine) startMediaSlot(s *slot, job *chatJob, budget int, buf []byte) int {
+	e.initSlot(s, job)
+
+	steps, err := e.mediaSteps(job)
+	if err != nil {
+		e.finishSlot(s, err)
+		return 0
+	}
+
+	s.mediaSteps = steps
+	for _, step := range steps {
+		s.nPrompt += step.nTokens
+	}
+
+	// Check context window.
+	if s.nPrompt > e.model.cfg.ContextWindow {
+		err := fmt.Errorf("start-media-slot: input tokens [%d] exceed context window [%d]", s.nPrompt, e.model.cfg.ContextWindow)
+		e.finishSlot(s, err)
+		return 0
+	}
+
+	// The media embeddings can't be matched by token, so nothing in the
+	// slot's KV sequence is reused.
+	llama.MemorySeqRm(e.model.mem, s.seqID, -1, -1)
+	s.cachedTokens = s.cachedTokens[:0]
+	s.media = true
+
+	e.model.log(job.ctx, "batch-engine", "status", "slot-started", "slot", s.id, "id", job.id, "prompt_tokens", s.nPrompt, "media", len(job.media))
+
+	return e.addMediaPrefill(s, budget, buf)
+}
+
+// mediaSteps splits the prompt of a media request into the text before each
+// media item, the chunks of the media item and the text that follows the last
+// one. The media is tokenized on its own with the marker, which gives the same
+// tokens as tokenizing the whole prompt since mtmd splits the text at the
+// markers too.
+func (e *batchEngine) mediaSteps(job *chatJob) ([]mediaStep, error) {
+	marker := mtmd.DefaultMarker()
+
+	parts := strings.Split(job.prompt, marker)
+	if len(parts) != len(job.media)+1 {
+		return nil, fmt.Errorf("media-steps: prompt has %d media markers for %d media", len(parts)-1, len(job.media))
+	}
+
+	var steps []mediaStep
+
+	for i, part := range parts {
+		if tokens := llama.Tokenize(e.model.vocab, part, i == 0, true); len(tokens) > 0 {
+			steps = append(steps, mediaStep{tokens: tokens, nTokens: len(tokens)})
+		}
+
+		if i == len(job.media) {
+			break
+		}
+
+		step, err := e.mediaStep(job, job.media[i])
+		if err != nil {
+			freeMediaSteps(steps)
+			return nil, err
+		}
+
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
+
+// mediaStep tokenizes a media item. The chunks hold the preprocessed media, so
+// the bitmap isn't needed once they exist.
+func (e *batchEngine) mediaStep(job *chatJob, med []byte) (mediaStep, error) {
+	if len(med) == 0 {
+		return mediaStep{}, fmt.Errorf("media-step: media is empty")
+	}
+
+	bitmap := mtmd.BitmapInitFromBuf(job.mtmdCtx, &med[0], uint64(len(med)))
+	if bitmap == 0 {
+		return mediaStep{}, fmt.Errorf("media-step: unable to load media")
+	}
+	defer mtmd.BitmapFree(bitmap)
+
+	chunks := mtmd.InputChunksInit()
+
+	input := mtmd.NewInputText(mtmd.DefaultMarker(), false, true)
+	if ret := mtmd.Tokenize(job.mtmdCtx, chunks, input, []mtmd.Bitmap{bitmap}); ret != 0 {
+		mtmd.InputChunksFree(chunks)
+		return mediaStep{}, fmt.Errorf("media-step: unable to tokenize media: ret[%d]", ret)
+	}
+
+	var n int
+	for i := range mtmd.InputChunksSize(chunks) {
+		n += int(mtmd.InputChunkGetNTokens(mtmd.InputChunksGet(chunks, i)))
+	}
+
+	return mediaStep{chunks: chunks, nTokens: n}, nil
+}
+
+// addMediaPrefill continues the prefill of a media request within the budget.
+// Text is added to the batch and has to be decoded before the media that
+// follows it is evaluated, so the slot waits for the next iteration. A media
+// item is evaluated when there is budget left and costs the budget its tokens.
+// Returns the part of the budget used.
+func (e *batchEngine) addMediaPrefill(s *slot, budget int, buf []byte) int {
+	var used int
+
+	for used < budget {
+		if s.prefillTokens != nil {
+			return used + e.addPrefillChunk(s, budget-used)
+		}
+
+		if len(s.mediaSteps) == 0 {
+			return used
+		}
+
+		step := s.mediaSteps[0]
+		s.mediaSteps = s.mediaSteps[1:]
+
+		if step.chunks == 0 {
+			s.prefillTokens = step.tokens
+			s.nPrefilled = 0
+			continue
+		}
+
+		last := len(s.mediaSteps) == 0
+		start := time.Now()
+
+		var nPast llama.Pos
+		ret := mtmd.HelperEvalChunks(s.job.mtmdCtx, e.model.lctx, step.chunks, s.nPast, s.seqID, int32(e.model.cfg.NBatch), last, &nPast)
+		mtmd.InputChunksFree(step.chunks)
+
+		if ret != 0 {
+			e.finishSlot(s, fmt.Errorf("add-media-prefill: unable to evaluate media: ret[%d]", ret))
+			return 0
+		}
+
+		since := time.Since(start)
+		metrics.AddPrefillMediaTime(since)
+		s.span.SetAttributes(attribute.String("prefill-media", since.String()))
+
+		s.nPast = nPast
+		used += step.nTokens
+
+		// A prompt that ends with media has its first token sampled from
+		// the logits of the helper's last decode.
+		if last {
+			s.iBatch = -1
+			e.processSlotToken(s, buf)
+			return used
+		}
+	}
+
+	return used
+}
+
+// initSlot resets the slot and sets it up to process the job.
+func (e *batchEngine) initSlot(s *slot, job *chatJob) {
 	s.reset()
 	s.active = true
 	s.job = job
 	s.startTime = time.Now()
+	s.lastUsed = s.startTime
 	s.seqID = llama.SeqId(s.id + 1)
 
 	// Start span for this chat request.
@@ -336,46 +880,103 @@ func (e *batchEngine) startSlot(s *slot, job *chatJob) {
 	// Create sampler for this request.
 	s.sampler = e.model.toSampler(job.params)
 
-	// Tokenize the prompt.
-	tokens := llama.Tokenize(e.model.vocab, job.prompt, true, true)
-	s.nPrompt = len(tokens)
+	if job.params.Logprobs {
+		s.logprobs = []ContentLogprob{}
+	}
 
-	// Check context window.
-	if s.nPrompt > e.model.cfg.ContextWindow {
-		err := fmt.Errorf("start-slot: input tokens [%d] exceed context window [%d]", s.nPrompt, e.model.cfg.ContextWindow)
-		e.sendSlotError(s, err)
-		s.reset()
-		return
+	s.stop = newStopMatcher(job.params.Stop)
+	s.toolStream = newToolCallStream(e.model.modelInfo.IsGPTModel)
+	s.overflow = job.overflow
+
+	// A continued message is already under way, so its first token doesn't
+	// start a new section of the response.
+	if job.params.Prefill != "" {
+		s.proc.continueMessage(job.params.Prefill)
+
+		switch s.proc.status {
+		case statusReasoning:
+			s.reasonFlag = 1
+		default:
+			s.completionFlag = 1
+		}
 	}
+}
 
-	// Store tokens for chunked prefill.
-	s.prefillTokens = tokens
-	s.nPrefilled = 0
+// reuseCachedPrefix trims the slot's KV sequence down to the longest prefix
+// it shares with the new prompt and returns the number of tokens that don't
+// need to be decoded again. At least one prompt token is always left for
+// decode so logits are produced for the first sampled token.
+func (e *batchEngine) reuseCachedPrefix(s *slot, tokens []llama.Token) int {
+	n := min(commonPrefix(s.cachedTokens, tokens), len(tokens)-1)
 
-	// Add first chunk of prompt tokens to batch.
-	e.addPrefillChunk(s)
+	if n > 0 && !e.canReuseKV(s, n) {
+		n = 0
+	}
+
+	if n <= 0 {
+		llama.MemorySeqRm(e.model.mem, s.seqID, -1, -1)
+		s.cachedTokens = s.cachedTokens[:0]
+		return 0
+	}
 
-	e.model.log(job.ctx, "batch-engine", "status", "slot-started", "slot", s.id, "id", job.id, "prompt_tokens", s.nPrompt)
+	// Recurrent and hybrid memory can't remove a partial sequence, in that
+	// case the whole sequence has to be processed again.
+	ok, err := llama.MemorySeqRm(e.model.mem, s.seqID, llama.Pos(n), -1)
+	if err != nil || !ok {
+		llama.MemorySeqRm(e.model.mem, s.seqID, -1, -1)
+		s.cachedTokens = s.cachedTokens[:0]
+		return 0
+	}
+
+	s.cachedTokens = s.cachedTokens[:n]
+
+	return n
 }
 
-// addPrefillChunk adds the next chunk of prefill tokens to the batch.
-// Returns true if prefill is complete after this chunk.
-func (e *batchEngine) addPrefillChunk(s *slot) {
-	if s.prefillTokens == nil || s.nPrefilled >= len(s.prefillTokens) {
-		return
+// canReuseKV checks the KV sequence still holds the data needed to continue
+// decoding at position n. Sliding window attention models prune old cells, so
+// the earliest retained position must still be inside the window.
+func (e *batchEngine) canReuseKV(s *slot, n int) bool {
+	posMin, err := llama.MemorySeqPosMin(e.model.mem, s.seqID)
+	if err != nil || posMin < 0 {
+		return false
+	}
+
+	if posMin == 0 {
+		return true
+	}
+
+	nSWA := int(llama.ModelNSWA(e.model.model))
+	if nSWA == 0 {
+		return false
+	}
+
+	return int(posMin) <= n-nSWA
+}
+
+// addPrefillChunk adds the next chunk of prefill tokens to the batch, up to
+// the specified budget. Returns the number of tokens added.
+func (e *batchEngine) addPrefillChunk(s *slot, budget int) int {
+	if s.prefillTokens == nil || s.nPrefilled >= len(s.prefillTokens) || budget <= 0 {
+		return 0
 	}
 
 	prefillStart := time.Now()
 
-	nBatch := e.model.cfg.NBatch
 	remaining := len(s.prefillTokens) - s.nPrefilled
-	chunkSize := min(remaining, nBatch)
+	chunkSize := min(remaining, budget)
+	s.inBatch = true
+
+	// Only the last token of the prompt needs logits. The text before the
+	// media of a prompt is followed by more of the prompt.
+	final := !s.resuming && len(s.mediaSteps) == 0
 
 	// Add chunk of tokens to batch.
 	for i := 0; i < chunkSize; i++ {
 		tok := s.prefillTokens[s.nPrefilled+i]
 		isLast := s.nPrefilled+i == len(s.prefillTokens)-1
-		batchAdd(&e.batch, tok, s.nPast, []llama.SeqId{s.seqID}, isLast)
+		batchAdd(&e.batch, tok, s.nPast, []llama.SeqId{s.seqID}, isLast && final)
+		s.cachedTokens = append(s.cachedTokens, tok)
 		s.nPast++
 	}
 	s.nPrefilled += chunkSize
@@ -383,26 +984,63 @@ func (e *batchEngine) addPrefillChunk(s *slot) {
 	prefillDuration := time.Since(prefillStart)
 	metrics.AddPrefillNonMediaTime(prefillDuration)
 
-	// Check if prefill is complete.
-	if s.nPrefilled >= len(s.prefillTokens) {
+	// Check if prefill is complete. A resumed request already has its
+	// next token sampled, so it goes straight back to generating.
+	switch {
+	case s.nPrefilled < len(s.prefillTokens):
+		s.iBatch = -1
+
+	case s.resuming:
+		s.iBatch = -1
+		s.prefillTokens = nil
+		s.prefillDone = true
+		s.resuming = false
+
+	case len(s.mediaSteps) > 0:
+		s.iBatch = -1
+		s.prefillTokens = nil
+
+	default:
 		s.iBatch = e.batch.NTokens - 1
 		s.prefillTokens = nil
 		s.span.SetAttributes(attribute.String("prefill-nonmedia", prefillDuration.String()))
-	} else {
-		s.iBatch = -1
 	}
+
+	return chunkSize
 }
 
-// processSlotToken handles a sampled token for a slot.
-func (e *batchEngine) processSlotToken(s *slot, buf []byte) {
-	// Sample the next token.
-	token := llama.SamplerSample(s.sampler, e.model.lctx, s.iBatch)
-	llama.SamplerAccept(s.sampler, token)
+// processSlotToken handles a sampled token for a slot and returns the token.
+// The slot is no longer active when the token finished the request.
+func (e *batchEngine) processSlotToken(s *slot, buf []byte) llama.Token {
+	// Sample the next token. SamplerSample accepts the token into the
+	// sampler chain, so accepting it again would advance stateful samplers
+	// like the grammar twice. A token that ends the reasoning is used in
+	// place of the sampled one once the reasoning budget is spent.
+	token, forced := s.proc.nextForced()
+	switch forced {
+	case true:
+		llama.SamplerAccept(s.sampler, token)
+
+	default:
+		token = llama.SamplerSample(s.sampler, e.model.lctx, s.iBatch)
+	}
 
 	// Check for end of generation.
 	if llama.VocabIsEOG(e.model.vocab, token) {
 		e.finishSlot(s, nil)
-		return
+		return token
+	}
+
+	// The logits for this slot are only valid until the next decode so the
+	// log probability has to be captured now.
+	var logprob *ContentLogprob
+	if s.job.params.Logprobs {
+		clp, err := e.model.tokenLogprob(e.model.lctx, s.iBatch, token, s.job.params.TopLogprobs)
+		if err != nil {
+			e.finishSlot(s, err)
+			return token
+		}
+		logprob = &clp
 	}
 
 	// Convert token to text.
@@ -414,7 +1052,7 @@ func (e *batchEngine) processSlotToken(s *slot, buf []byte) {
 
 	if content == "" {
 		e.finishSlot(s, nil)
-		return
+		return token
 	}
 
 	s.sampled = token
@@ -436,7 +1074,7 @@ func (e *batchEngine) processSlotToken(s *slot, buf []byte) {
 
 	if eog {
 		e.finishSlot(s, nil)
-		return
+		return token
 	}
 
 	// Update flags based on response status.
@@ -457,9 +1095,17 @@ func (e *batchEngine) processSlotToken(s *slot, buf []byte) {
 		s.completionFlag = 0
 
 	default:
-		// No content to process.
+		// No content to process. A tool call being collected is streamed
+		// as it's written.
+		if s.proc.inToolCall {
+			if err := e.sendSlotToolCalls(s); err != nil {
+				e.finishSlot(s, err)
+				return token
+			}
+		}
+
 		s.iBatch = -1
-		return
+		return token
 	}
 
 	// Calculate tokens per second.
@@ -468,57 +1114,126 @@ func (e *batchEngine) processSlotToken(s *slot, buf []byte) {
 	tokensPerSecond := float64(outputTokens) / elapsedSeconds
 
 	// Stream response if not tooling.
+	content = resp.content
+	var stopped bool
+
 	if s.toolFlag == 0 {
 		// Skip unnecessary CRLF at mode transitions.
-		if e.model.isUnncessaryCRLF(s.reasonFlag, s.completionFlag, resp.content) {
+		if e.model.isUnncessaryCRLF(s.reasonFlag, s.completionFlag, content) {
 			s.iBatch = -1
-			return
+			return token
 		}
 
-		usage := Usage{
-			PromptTokens:     s.nPrompt,
-			ReasoningTokens:  s.reasonTokens,
-			CompletionTokens: s.completionTokens,
-			OutputTokens:     outputTokens,
-			TotalTokens:      s.nPrompt + outputTokens,
-			TokensPerSecond:  tokensPerSecond,
+		// Log probabilities are only reported for completion content and
+		// are held back along with the content behind them.
+		if s.completionFlag > 0 {
+			if logprob != nil {
+				s.heldLogprobs = append(s.heldLogprobs, *logprob)
+			}
+
+			content, stopped = s.stop.process(content)
 		}
 
-		err := e.model.sendDeltaResponse(s.job.ctx, s.job.ch, s.job.id, s.job.object, 0, "", resp.content, s.reasonFlag, usage)
-		if err != nil {
-			e.finishSlot(s, err)
-			return
+		if content != "" {
+			usage := Usage{
+				PromptTokens:     s.nPrompt,
+				CachedTokens:     s.nCached,
+				ReasoningTokens:  s.reasonTokens,
+				CompletionTokens: s.completionTokens,
+				OutputTokens:     outputTokens,
+				TotalTokens:      s.nPrompt + outputTokens,
+				TokensPerSecond:  tokensPerSecond,
+			}
+
+			if err := e.sendSlotDelta(s, content, usage); err != nil {
+				e.finishSlot(s, err)
+				return token
+			}
 		}
 	}
 
 	// Store content for final response.
 	switch {
 	case s.reasonFlag > 0:
-		s.finalReasoning.WriteString(resp.content)
+		s.finalReasoning.WriteString(content)
 
 	case s.toolFlag > 0:
-		s.finalTooling.WriteString(resp.content)
+		s.finalTooling.WriteString(content)
 
 	default:
-		s.finalContent.WriteString(resp.content)
+		s.finalContent.WriteString(content)
 	}
 
-	// Update token counts.
-	switch {
-	case s.reasonFlag > 0:
-		s.reasonTokens++
+	if s.toolFlag > 0 {
+		if err := e.sendSlotToolCalls(s); err != nil {
+			e.finishSlot(s, err)
+			return token
+		}
+	}
 
-	default:
-		s.completionTokens++
+	// Update token counts. The model is moved on to the answer once the
+	// reasoning budget is spent.
+	e.countOutputToken(s, forced)
+
+	// Check for a stop sequence.
+	if stopped {
+		e.finishSlot(s, nil)
+		return token
 	}
 
-	// Check max tokens.
+	// Check for max tokens or a full context window, the sampled token
+	// can't be decoded once the sequence fills the context window unless
+	// the request allows shifting the context.
 	if s.nDecoded >= s.job.params.MaxTokens {
+		s.length = true
 		e.finishSlot(s, nil)
-		return
+		return token
+	}
+
+	if int(s.nPast) >= e.model.cfg.ContextWindow {
+		if s.job.params.ContextOverflow != ContextOverflowShift || s.media || !e.shiftContext(s) {
+			s.length = true
+			e.finishSlot(s, nil)
+			return token
+		}
 	}
 
 	s.iBatch = -1
+
+	return token
+}
+
+// sendSlotDelta streams content for the slot along with the log probabilities
+// being held for it.
+func (e *batchEngine) sendSlotDelta(s *slot, content string, usage Usage) error {
+	var logprobs []ContentLogprob
+	if s.reasonFlag == 0 && len(s.heldLogprobs) > 0 {
+		logprobs = s.heldLogprobs
+		s.logprobs = append(s.logprobs, logprobs...)
+		s.heldLogprobs = nil
+	}
+
+	return e.model.sendDeltaResponse(s.job.ctx, s.job.ch, s.job.id, s.job.object, 0, "", content, s.reasonFlag, logprobs, usage)
+}
+
+// sendSlotToolCalls streams the part of the tool calls the model wrote since
+// the last call.
+func (e *batchEngine) sendSlotToolCalls(s *slot) error {
+	deltas := s.toolStream.update(s.finalTooling.String() + s.proc.toolCallBuf.String())
+	if len(deltas) == 0 {
+		return nil
+	}
+
+	usage := Usage{
+		PromptTokens:     s.nPrompt,
+		CachedTokens:     s.nCached,
+		ReasoningTokens:  s.reasonTokens,
+		CompletionTokens: s.completionTokens,
+		OutputTokens:     s.reasonTokens + s.completionTokens,
+		TotalTokens:      s.nPrompt + s.reasonTokens + s.completionTokens,
+	}
+
+	return e.model.sendToolCallDeltaResponse(s.job.ctx, s.job.ch, s.job.id, s.job.object, 0, deltas, usage)
 }
 
 // finishSlot completes a slot and sends the final response.
@@ -530,6 +1245,7 @@ func (e *batchEngine) finishSlot(s *slot, err error) {
 	defer func() {
 		close(s.job.ch)
 		s.span.End()
+		s.job.freeMedia()
 		s.reset()
 		e.freeSlotResources(s)
 		e.model.activeStreams.Add(-1)
@@ -538,13 +1254,28 @@ func (e *batchEngine) finishSlot(s *slot, err error) {
 	ctx := s.job.ctx
 	elapsed := time.Since(s.startTime)
 
-	// Clear KV cache for this slot's sequence.
-	llama.MemorySeqRm(e.model.mem, s.seqID, -1, -1)
+	// The KV cache for this slot's sequence is kept so the next request
+	// sharing the same prompt prefix can skip that part of the prefill.
+	s.lastUsed = time.Now()
+
+	// After a context shift the tokens past the system prompt were computed
+	// with context that is gone, so they can't be reused by another request.
+	if s.overflow == ContextOverflowShift {
+		llama.MemorySeqRm(e.model.mem, s.seqID, llama.Pos(s.nKeep), -1)
+		s.cachedTokens = s.cachedTokens[:s.nKeep]
+	}
+
+	// The KV sequence of a media request doesn't match its tokens, so the
+	// next request on this slot starts from scratch.
+	if s.media {
+		s.cachedTokens = s.cachedTokens[:0]
+	}
 
 	// Handle error case.
 	if err != nil {
 		usage := Usage{
 			PromptTokens:     s.nPrompt,
+			CachedTokens:     s.nCached,
 			ReasoningTokens:  s.reasonTokens,
 			CompletionTokens: s.completionTokens,
 			OutputTokens:     s.reasonTokens + s.completionTokens,
@@ -556,6 +1287,15 @@ func (e *batchEngine) finishSlot(s *slot, err error) {
 		return
 	}
 
+	// Tool calls of a format without an end marker run to the end of the
+	// generation and are still being collected.
+	if s.proc.inToolCall {
+		if content := s.proc.flushToolCall(); content != "" {
+			s.finalTooling.WriteString(content)
+			s.toolFlag++
+		}
+	}
+
 	// Process tool calls if any. Token counts are already tracked
 	// per-token in processSlotToken, so no re-tokenization needed.
 	if s.toolFlag > 0 {
@@ -566,8 +1306,10 @@ func (e *batchEngine) finishSlot(s *slot, err error) {
 				s.respToolCalls = parseGPTToolCall(content)
 
 			default:
-				s.respToolCalls = parseToolCall(content)
+				s.respToolCalls = e.model.toolFormat.Parse(content)
 			}
+
+			validateToolCalls(s.respToolCalls, s.job.params.Tools)
 		}
 	}
 
@@ -578,6 +1320,7 @@ func (e *batchEngine) finishSlot(s *slot, err error) {
 
 	usage := Usage{
 		PromptTokens:     s.nPrompt,
+		CachedTokens:     s.nCached,
 		ReasoningTokens:  s.reasonTokens,
 		CompletionTokens: s.completionTokens,
 		OutputTokens:     outputTokens,
@@ -585,9 +1328,33 @@ func (e *batchEngine) finishSlot(s *slot, err error) {
 		TokensPerSecond:  tokensPerSecond,
 	}
 
+	// Generation ended without completing a stop sequence so the content
+	// being held back belongs to the response.
+	if held := s.stop.flush(); held != "" {
+		s.reasonFlag = 0
+		if err := e.sendSlotDelta(s, held, usage); err != nil {
+			e.model.sendErrorResponse(ctx, s.job.ch, s.job.id, s.job.object, 0, "", err, usage)
+			return
+		}
+		s.finalContent.WriteString(held)
+	}
+
+	// The part of the tool calls that wasn't streamed yet is sent before
+	// the final response.
+	if len(s.respToolCalls) > 0 {
+		deltas := s.toolStream.finish(s.finalTooling.String(), s.respToolCalls)
+		if len(deltas) > 0 {
+			if err := e.model.sendToolCallDeltaResponse(ctx, s.job.ch, s.job.id, s.job.object, 0, deltas, usage); err != nil {
+				e.model.sendErrorResponse(ctx, s.job.ch, s.job.id, s.job.object, 0, "", err, usage)
+				return
+			}
+		}
+	}
+
 	// Add span attributes and end span.
 	s.span.SetAttributes(
 		attribute.Int("prompt_tokens", s.nPrompt),
+		attribute.Int("cached_tokens", s.nCached),
 		attribute.Int("reasoning_tokens", s.reasonTokens),
 		attribute.Int("completion_tokens", s.completionTokens),
 		attribute.Int("output_tokens", outputTokens),
@@ -605,10 +1372,10 @@ func (e *batchEngine) finishSlot(s *slot, err error) {
 	}
 
 	e.model.sendFinalResponse(ctx, s.job.ch, s.job.id, s.job.object, 0, returnPrompt,
-		&s.finalContent, &s.finalReasoning, s.respToolCalls, usage)
+		&s.finalContent, &s.finalReasoning, s.respToolCalls, s.logprobs, s.length, s.overflow, usage)
 
 	e.model.log(ctx, "batch-engine", "status", "slot-finished", "slot", s.id, "id", s.job.id,
-		"prompt", s.nPrompt, "output", outputTokens, "time", elapsed.String())
+		"prompt", s.nPrompt, "cached", s.nCached, "output", outputTokens, "time", elapsed.String())
 }
 
 func (e *batchEngine) freeSlotResources(s *slot) {
@@ -618,19 +1385,64 @@ func (e *batchEngine) freeSlotResources(s *slot) {
 	}
 }
 
-func (e *batchEngine) sendSlotError(s *slot, err error) {
-	usage := Usage{PromptTokens: s.nPrompt}
-	e.model.sendErrorResponse(s.job.ctx, s.job.ch, s.job.id, s.job.object, 0, "", err, usage)
-	close(s.job.ch)
-}
-
-// drainSlots finishes all active slots during shutdown.
+// drainSlots finishes all active slots and fails the pending jobs during
+// shutdown.
 func (e *batchEngine) drainSlots() {
 	for _, s := range e.slots {
 		if s.active {
 			e.finishSlot(s, fmt.Errorf("darin-slots: engine shutting down"))
 		}
 	}
+
+	e.queueSubmitted()
+
+	for job := e.pending.pop(); job != nil; job = e.pending.pop() {
+		e.failPending(job, fmt.Errorf("drain-slots: engine shutting down"))
+	}
+}
+
+// dropCancelled fails the pending jobs whose context is done, so they don't
+// wait for a slot only to be finished once they get it. Submitted jobs take
+// the room they leave in the pending queue.
+func (e *batchEngine) dropCancelled() {
+	for _, job := range e.pending.remove(func(job *chatJob) bool { return job.ctx.Err() != nil }) {
+		e.failPending(job, fmt.Errorf("drop-cancelled: %w", job.ctx.Err()))
+	}
+
+	e.queueSubmitted()
+}
+
+// failPending sends the error to a job that never got a slot back and ends
+// its stream.
+func (e *batchEngine) failPending(job *chatJob, err error) {
+	if job.parked != nil {
+		e.releaseParked(job.parked)
+	}
+
+	job.freeMedia()
+
+	e.model.log(job.ctx, "batch-engine", "status", "ERROR", "msg", err, "id", job.id)
+
+	// The error is sent even when the context is done, the channel has room
+	// for it since the job never got a slot.
+	select {
+	case job.ch <- ChatResponseErr(job.id, job.object, e.model.modelInfo.ID, 0, "", err, Usage{}):
+	default:
+	}
+
+	close(job.ch)
+	e.model.activeStreams.Add(-1)
+}
+
+// commonPrefix returns the number of leading tokens a and b have in common.
+func commonPrefix(a []llama.Token, b []llama.Token) int {
+	n := min(len(a), len(b))
+	for i := range n {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
 }
 
 // =============================================================================
@@ -0,0 +1,387 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hybridgroup/yzma/pkg/llama"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func Test_CommonPrefix(t *testing.T) {
+	tests := []struct {
+		name string
+		a    []llama.Token
+		b    []llama.Token
+		want int
+	}{
+		{"empty", nil, nil, 0},
+		{"empty-cache", nil, []llama.Token{1, 2, 3}, 0},
+		{"no-match", []llama.Token{4, 5}, []llama.Token{1, 2, 3}, 0},
+		{"partial", []llama.Token{1, 2, 9, 9}, []llama.Token{1, 2, 3}, 2},
+		{"cache-is-prefix", []llama.Token{1, 2}, []llama.Token{1, 2, 3}, 2},
+		{"prompt-is-prefix", []llama.Token{1, 2, 3, 4}, []llama.Token{1, 2, 3}, 3},
+		{"identical", []llama.Token{1, 2, 3}, []llama.Token{1, 2, 3}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := commonPrefix(tt.a, tt.b); got != tt.want {
+				t.Errorf("commonPrefix() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_SelectSlot(t *testing.T) {
+	idle := func(id int, cached ...llama.Token) *slot {
+		return &slot{id: id, cachedTokens: cached}
+	}
+
+	t.Run("longest-prefix", func(t *testing.T) {
+		e := batchEngine{
+			slots: []*slot{
+				idle(0, 1, 2),
+				idle(1, 1, 2, 3, 4),
+				idle(2, 7, 8),
+			},
+		}
+
+		got := e.selectSlot([]llama.Token{1, 2, 3, 4, 5})
+		if got.id != 1 {
+			t.Fatalf("expected slot 1, got %d", got.id)
+		}
+	})
+
+	t.Run("skip-active", func(t *testing.T) {
+		busy := idle(1, 1, 2, 3, 4)
+		busy.active = true
+
+		e := batchEngine{
+			slots: []*slot{
+				idle(0, 1, 2),
+				busy,
+			},
+		}
+
+		got := e.selectSlot([]llama.Token{1, 2, 3, 4, 5})
+		if got.id != 0 {
+			t.Fatalf("expected slot 0, got %d", got.id)
+		}
+	})
+}
+
+func Test_PrefillBudget(t *testing.T) {
+	tests := []struct {
+		name    string
+		budget  int
+		nTokens int32
+		want    int
+	}{
+		{"idle-uses-nbatch", 128, 0, 512},
+		{"generating-capped", 128, 4, 128},
+		{"generating-no-cap", 0, 4, 508},
+		{"batch-full", 128, 512, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := batchEngine{
+				model: &Model{cfg: Config{NBatch: 512, PrefillBudget: tt.budget}},
+			}
+			e.batch.NTokens = tt.nTokens
+
+			if got := e.prefillBudget(); got != tt.want {
+				t.Errorf("prefillBudget() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_PrefillingSlots(t *testing.T) {
+	now := time.Now()
+
+	prefilling := func(id int, priority Priority, started time.Duration) *slot {
+		return &slot{
+			id:            id,
+			active:        true,
+			prefillTokens: []llama.Token{1},
+			startTime:     now.Add(started),
+			job:           &chatJob{params: params{Priority: priority}},
+		}
+	}
+
+	generating := prefilling(4, PriorityHigh, 0)
+	generating.prefillTokens = nil
+
+	e := batchEngine{
+		slots: []*slot{
+			prefilling(0, PriorityNormal, 2*time.Second),
+			prefilling(1, PriorityNormal, time.Second),
+			generating,
+			prefilling(2, PriorityLow, 0),
+			prefilling(3, PriorityHigh, 3*time.Second),
+		},
+	}
+
+	var got []int
+	for _, s := range e.prefillingSlots() {
+		got = append(got, s.id)
+	}
+
+	want := []int{3, 1, 0, 2}
+	if !slices.Equal(got, want) {
+		t.Errorf("order = %v, want %v", got, want)
+	}
+}
+
+func Test_DropRejectedDrafts(t *testing.T) {
+	// The prompt is 1-3, the sampled token is 4 and the drafts are 5-7.
+	cached := func() []llama.Token {
+		return []llama.Token{1, 2, 3, 4, 5, 6, 7}
+	}
+
+	tests := []struct {
+		name     string
+		active   bool
+		cached   []llama.Token
+		rejected int
+		wantKeep llama.Pos
+		wantOK   bool
+		want     []llama.Token
+	}{
+		{"none-rejected", true, cached(), 0, 0, false, []llama.Token{1, 2, 3, 4, 5, 6, 7}},
+		{"rejected", true, cached(), 2, 5, true, []llama.Token{1, 2, 3, 4, 5}},
+		{"finished-on-eog", false, cached(), 3, 0, false, []llama.Token{1, 2, 3, 4, 5, 6, 7}},
+
+		// finishSlot cut the cache back to the system prompt after a
+		// context shift, which is shorter than the rejected drafts.
+		{"eog-after-shift", false, cached()[:1], 3, 0, false, []llama.Token{1}},
+		{"eog-after-shift-no-system-prompt", false, cached()[:0], 3, 0, false, []llama.Token{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := slot{active: tt.active, cachedTokens: tt.cached}
+
+			keep, ok := s.dropRejectedDrafts(7, tt.rejected)
+			if keep != tt.wantKeep || ok != tt.wantOK {
+				t.Fatalf("dropRejectedDrafts() = %d, %t, want %d, %t", keep, ok, tt.wantKeep, tt.wantOK)
+			}
+
+			if !slices.Equal(s.cachedTokens, tt.want) {
+				t.Errorf("cached tokens = %v, want %v", s.cachedTokens, tt.want)
+			}
+		})
+	}
+}
+
+func Test_PendingJobs(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	ch := make(chan ChatResponse, 1)
+
+	jobs := []*chatJob{
+		{id: "j1", ctx: context.Background(), ch: make(chan ChatResponse, 1)},
+		{id: "j2", ctx: cancelled, ch: ch},
+		{id: "j3", ctx: context.Background(), ch: make(chan ChatResponse, 1)},
+	}
+
+	e := batchEngine{
+		model:      &Model{log: func(context.Context, string, ...any) {}},
+		requestQ:   make(chan *chatJob, len(jobs)),
+		maxPending: 2,
+	}
+	e.model.activeStreams.Store(int32(len(jobs)))
+
+	for _, job := range jobs {
+		e.requestQ <- job
+	}
+
+	t.Run("bounded", func(t *testing.T) {
+		e.queueSubmitted()
+
+		if e.pending.len() != 2 || len(e.requestQ) != 1 {
+			t.Fatalf("pending = %d, requestQ = %d, want 2, 1", e.pending.len(), len(e.requestQ))
+		}
+	})
+
+	t.Run("drop-cancelled", func(t *testing.T) {
+		e.dropCancelled()
+
+		if e.pending.len() != 2 || len(e.requestQ) != 0 {
+			t.Fatalf("pending = %d, requestQ = %d, want 2, 0", e.pending.len(), len(e.requestQ))
+		}
+
+		resp, ok := <-ch
+		if !ok || resp.Choice[0].FinishReason() != FinishReasonError {
+			t.Fatalf("expected an error response, got %+v", resp)
+		}
+
+		if _, ok := <-ch; ok {
+			t.Fatal("expected the channel to be closed")
+		}
+
+		if n := e.model.activeStreams.Load(); n != 2 {
+			t.Errorf("active streams = %d, want 2", n)
+		}
+
+		var got []string
+		for job := e.pending.pop(); job != nil; job = e.pending.pop() {
+			got = append(got, job.id)
+		}
+
+		if want := []string{"j1", "j3"}; !slices.Equal(got, want) {
+			t.Errorf("pending = %v, want %v", got, want)
+		}
+	})
+}
+
+func Test_StreamBufferSize(t *testing.T) {
+	m := Model{
+		log:   func(context.Context, string, ...any) {},
+		cfg:   Config{StreamBacklog: 4, DraftMax: 3},
+		batch: &batchEngine{},
+	}
+	m.toolFormat, _ = LookupToolCallFormat(ToolCallFormatHermes)
+	m.activeStreams.Store(1)
+
+	e := batchEngine{model: &m}
+
+	// The client never reads from the channel.
+	ch := make(chan ChatResponse, m.streamBufferSize())
+
+	s := slot{proc: newProcessor(&m)}
+	s.reset()
+	s.active = true
+	s.job = &chatJob{id: "chatcmpl-1", ctx: context.Background(), ch: ch}
+	s.span = trace.SpanFromContext(context.Background())
+	s.startTime = time.Now()
+	s.stop = newStopMatcher([]string{"</end>"})
+	s.toolStream = newToolCallStream(false)
+
+	// The engine lets a slot run while the backlog isn't exceeded.
+	for range m.cfg.StreamBacklog {
+		ch <- ChatResponse{}
+	}
+
+	// The sampled token and the verified drafts of one iteration.
+	for i := range m.cfg.DraftMax + 1 {
+		if err := e.sendSlotDelta(&s, "token", Usage{}); err != nil {
+			t.Fatalf("delta %d: %s", i, err)
+		}
+	}
+
+	// Content held back for a stop sequence and a tool call that is only
+	// parsed when the generation ends.
+	s.stop.process("</")
+	s.finalTooling.WriteString(`{"name":"get_weather","arguments":{"location":"NYC"}}`)
+	s.toolFlag++
+
+	e.finishSlot(&s, nil)
+
+	if len(ch) != cap(ch) {
+		t.Fatalf("responses = %d, want %d", len(ch), cap(ch))
+	}
+
+	var last ChatResponse
+	for resp := range ch {
+		last = resp
+	}
+
+	if got := last.Choice[0].FinishReason(); got != FinishReasonTool {
+		t.Fatalf("finish reason = %q, want %q", got, FinishReasonTool)
+	}
+
+	if n := m.activeStreams.Load(); n != 0 {
+		t.Errorf("active streams = %d, want 0", n)
+	}
+
+	t.Run("full", func(t *testing.T) {
+		full := make(chan ChatResponse, 1)
+		full <- ChatResponse{}
+
+		err := m.sendDeltaResponse(context.Background(), full, "chatcmpl-2", ObjectChatText, 0, "", "token", 0, nil, Usage{})
+		if !errors.Is(err, ErrSlowClient) {
+			t.Fatalf("expected ErrSlowClient, got %v", err)
+		}
+	})
+}
+
+func Test_TerminalResponse(t *testing.T) {
+	m := Model{
+		log:   func(context.Context, string, ...any) {},
+		cfg:   Config{StreamBacklog: 4, DraftMax: 3},
+		batch: &batchEngine{},
+	}
+	m.toolFormat, _ = LookupToolCallFormat(ToolCallFormatHermes)
+
+	e := batchEngine{model: &m}
+
+	tests := []struct {
+		name    string
+		held    string
+		tooling string
+		err     error
+		want    error
+	}{
+		{"held-content", "</", "", nil, ErrSlowClient},
+		{"tool-calls", "", `{"name":"get_weather","arguments":{"location":"NYC"}}`, nil, ErrSlowClient},
+		{"error", "", "", context.DeadlineExceeded, context.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m.activeStreams.Store(1)
+
+			// The client stopped reading with one place left.
+			ch := make(chan ChatResponse, m.streamBufferSize())
+			for range cap(ch) - 1 {
+				ch <- ChatResponse{}
+			}
+
+			s := slot{proc: newProcessor(&m)}
+			s.reset()
+			s.active = true
+			s.job = &chatJob{id: "chatcmpl-1", ctx: context.Background(), ch: ch}
+			s.span = trace.SpanFromContext(context.Background())
+			s.startTime = time.Now()
+			s.stop = newStopMatcher([]string{"</end>"})
+			s.toolStream = newToolCallStream(false)
+
+			s.stop.process(tt.held)
+			if tt.tooling != "" {
+				s.finalTooling.WriteString(tt.tooling)
+				s.toolFlag++
+			}
+
+			if err := e.sendSlotDelta(&s, "token", Usage{}); !errors.Is(err, ErrSlowClient) {
+				t.Fatalf("expected ErrSlowClient, got %v", err)
+			}
+
+			e.finishSlot(&s, tt.err)
+
+			if len(ch) != cap(ch) {
+				t.Fatalf("responses = %d, want %d", len(ch), cap(ch))
+			}
+
+			var last ChatResponse
+			for resp := range ch {
+				last = resp
+			}
+
+			if got := last.Choice[0].FinishReason(); got != FinishReasonError {
+				t.Fatalf("finish reason = %q, want %q", got, FinishReasonError)
+			}
+
+			if got := last.Choice[0].Delta.Content; !strings.Contains(got, tt.want.Error()) {
+				t.Errorf("error = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
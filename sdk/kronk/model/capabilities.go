@@ -0,0 +1,190 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hybridgroup/yzma/pkg/llama"
+	"github.com/hybridgroup/yzma/pkg/mtmd"
+)
+
+// ModelType is the kind of model, which decides the APIs it supports.
+type ModelType string
+
+// Model types. When the type isn't set in the config it's detected from the
+// model metadata.
+const (
+	// ModelTypeAuto detects the type from the model metadata.
+	ModelTypeAuto ModelType = ""
+
+	// ModelTypeChat is a model for chat and responses.
+	ModelTypeChat ModelType = "chat"
+
+	// ModelTypeGPT is a chat model using the harmony format of gpt-oss.
+	ModelTypeGPT ModelType = "gpt"
+
+	// ModelTypeEmbed is a model for embeddings.
+	ModelTypeEmbed ModelType = "embed"
+
+	// ModelTypeRerank is a model for reranking.
+	ModelTypeRerank ModelType = "rerank"
+)
+
+// ParseModelType parses a string into a ModelType.
+// Supported values: "", "auto", "chat", "gpt", "embed", "rerank".
+func ParseModelType(s string) (ModelType, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "auto":
+		return ModelTypeAuto, nil
+
+	case "chat":
+		return ModelTypeChat, nil
+
+	case "gpt":
+		return ModelTypeGPT, nil
+
+	case "embed":
+		return ModelTypeEmbed, nil
+
+	case "rerank":
+		return ModelTypeRerank, nil
+
+	default:
+		return ModelTypeAuto, fmt.Errorf("parse-model-type: unknown model type: %s (valid: auto, chat, gpt, embed, rerank)", s)
+	}
+}
+
+// =============================================================================
+
+// Endpoints a model can be used with.
+const (
+	EndpointChatCompletion = "chat_completion"
+	EndpointEmbeddings     = "embeddings"
+	EndpointRerank         = "rerank"
+)
+
+// Capabilities represents the capabilities of a model. It matches the
+// capabilities of a model in the catalog.
+type Capabilities struct {
+	Endpoint  string
+	Images    bool
+	Audio     bool
+	Video     bool
+	Streaming bool
+	Reasoning bool
+	Tooling   bool
+	Embedding bool
+	Rerank    bool
+}
+
+// poolingTypeRank is the value of the pooling_type metadata for rerankers.
+const poolingTypeRank = 4
+
+// detectModelType classifies the model from its GGUF metadata. A pooling
+// type marks an embedding model, while the rank pooling type or a classifier
+// head marks a reranker. The harmony format is recognized by the gpt-oss
+// architecture or by its special tokens in the vocab or the chat template.
+func detectModelType(metadata map[string]string, vocab llama.Vocab, template string) ModelType {
+	arch := metadata["general.architecture"]
+
+	for key := range metadata {
+		if strings.HasPrefix(key, arch+".classifier.") {
+			return ModelTypeRerank
+		}
+	}
+
+	if v, ok := metadata[arch+".pooling_type"]; ok {
+		pooling, err := strconv.Atoi(v)
+		switch {
+		case err == nil && pooling == poolingTypeRank:
+			return ModelTypeRerank
+
+		case err == nil && pooling > 0:
+			return ModelTypeEmbed
+		}
+	}
+
+	switch {
+	case arch == "gpt-oss":
+		return ModelTypeGPT
+
+	case strings.Contains(template, "<|channel|>"):
+		return ModelTypeGPT
+
+	case hasSpecialToken(vocab, "<|channel|>"):
+		return ModelTypeGPT
+	}
+
+	return ModelTypeChat
+}
+
+// hasSpecialToken checks the vocab has the text as a single special token.
+func hasSpecialToken(vocab llama.Vocab, text string) bool {
+	return len(llama.Tokenize(vocab, text, false, true)) == 1
+}
+
+// applyModelType sets the model type flags and the capabilities of the model.
+// The chat template tells if a chat model supports reasoning and tools.
+func applyModelType(mi ModelInfo, modelType ModelType, template string, vision bool, audio bool) ModelInfo {
+	mi.IsGPTModel = modelType == ModelTypeGPT
+	mi.IsEmbedModel = modelType == ModelTypeEmbed
+	mi.IsRerankModel = modelType == ModelTypeRerank
+
+	switch modelType {
+	case ModelTypeEmbed:
+		mi.Capabilities = Capabilities{
+			Endpoint:  EndpointEmbeddings,
+			Embedding: true,
+		}
+
+	case ModelTypeRerank:
+		mi.Capabilities = Capabilities{
+			Endpoint: EndpointRerank,
+			Rerank:   true,
+		}
+
+	default:
+		mi.Capabilities = Capabilities{
+			Endpoint:  EndpointChatCompletion,
+			Images:    vision,
+			Audio:     audio,
+			Streaming: true,
+			Reasoning: mi.IsGPTModel || strings.Contains(template, "<think>") || strings.Contains(template, "reasoning_content"),
+			Tooling:   templateToolCalls(template, mi.IsGPTModel),
+		}
+	}
+
+	return mi
+}
+
+// templateToolCalls reports if the chat template writes tool calls. GPT models
+// end a tool call with the <|call|> token of the harmony format, other models
+// need the start marker of a registered tool call format.
+func templateToolCalls(template string, isGPT bool) bool {
+	if isGPT {
+		return strings.Contains(template, "<|call|>")
+	}
+
+	toolCallFormats.mu.RLock()
+	defer toolCallFormats.mu.RUnlock()
+
+	for _, f := range toolCallFormats.formats {
+		if strings.Contains(template, f.Start) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// projectionSupport loads the projection file to check the media it supports.
+func projectionSupport(projFile string, mdl llama.Model) (vision bool, audio bool, err error) {
+	mtmdCtx, err := mtmd.InitFromFile(projFile, mdl, mtmd.ContextParamsDefault())
+	if err != nil {
+		return false, false, fmt.Errorf("projection-support: unable to init projection: %w", err)
+	}
+	defer mtmd.Free(mtmdCtx)
+
+	return mtmd.SupportVision(mtmdCtx), mtmd.SupportAudio(mtmdCtx), nil
+}
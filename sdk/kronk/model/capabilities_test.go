@@ -0,0 +1,151 @@
+package model
+
+import (
+	"testing"
+)
+
+func Test_DetectModelType(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		template string
+		want     ModelType
+	}{
+		{
+			name:     "chat",
+			metadata: map[string]string{"general.architecture": "qwen3"},
+			template: "{% for message in messages %}{{ message.content }}{% endfor %}",
+			want:     ModelTypeChat,
+		},
+		{
+			name:     "embed-mean-pooling",
+			metadata: map[string]string{"general.architecture": "bert", "bert.pooling_type": "1"},
+			want:     ModelTypeEmbed,
+		},
+		{
+			name:     "embed-last-pooling",
+			metadata: map[string]string{"general.architecture": "qwen3", "qwen3.pooling_type": "3"},
+			want:     ModelTypeEmbed,
+		},
+		{
+			name:     "rerank-pooling",
+			metadata: map[string]string{"general.architecture": "bert", "bert.pooling_type": "4"},
+			want:     ModelTypeRerank,
+		},
+		{
+			name:     "rerank-classifier",
+			metadata: map[string]string{"general.architecture": "qwen3", "qwen3.classifier.output_labels": "yes"},
+			want:     ModelTypeRerank,
+		},
+		{
+			name:     "gpt-architecture",
+			metadata: map[string]string{"general.architecture": "gpt-oss"},
+			want:     ModelTypeGPT,
+		},
+		{
+			name:     "gpt-template",
+			metadata: map[string]string{"general.architecture": "llama"},
+			template: "<|start|>assistant<|channel|>final<|message|>",
+			want:     ModelTypeGPT,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectModelType(tt.metadata, 0, tt.template); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ParseModelType(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    ModelType
+		wantErr bool
+	}{
+		{"", ModelTypeAuto, false},
+		{"auto", ModelTypeAuto, false},
+		{"Embed", ModelTypeEmbed, false},
+		{"rerank", ModelTypeRerank, false},
+		{"vision", ModelTypeAuto, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseModelType(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ApplyModelType(t *testing.T) {
+	const (
+		hermes  = `{%- if tools %}<tools>{{ tools | tojson }}</tools>{%- endif %}{%- for tc in message.tool_calls %}<tool_call>{{ tc | tojson }}</tool_call>{%- endfor %}<think>`
+		harmony = `<|start|>assistant<|channel|>commentary to=functions.{{ name }}<|message|>{{ args }}<|call|>`
+		prose   = `{#- The model was never trained with tools. -#}{% for message in messages %}{{ message.content }}{% endfor %}`
+	)
+
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		template string
+		want     Capabilities
+	}{
+		{
+			name:     "classifier",
+			metadata: map[string]string{"general.architecture": "qwen3", "qwen3.classifier.output_labels": "yes"},
+			template: hermes,
+			want:     Capabilities{Endpoint: EndpointRerank, Rerank: true},
+		},
+		{
+			name:     "rank-pooling",
+			metadata: map[string]string{"general.architecture": "bert", "bert.pooling_type": "4"},
+			want:     Capabilities{Endpoint: EndpointRerank, Rerank: true},
+		},
+		{
+			name:     "mean-pooling",
+			metadata: map[string]string{"general.architecture": "bert", "bert.pooling_type": "1"},
+			want:     Capabilities{Endpoint: EndpointEmbeddings, Embedding: true},
+		},
+		{
+			name:     "harmony",
+			metadata: map[string]string{"general.architecture": "gpt-oss"},
+			template: harmony,
+			want:     Capabilities{Endpoint: EndpointChatCompletion, Streaming: true, Reasoning: true, Tooling: true},
+		},
+		{
+			name:     "hermes",
+			metadata: map[string]string{"general.architecture": "qwen3"},
+			template: hermes,
+			want:     Capabilities{Endpoint: EndpointChatCompletion, Streaming: true, Reasoning: true, Tooling: true},
+		},
+		{
+			name:     "tools-in-prose",
+			metadata: map[string]string{"general.architecture": "llama"},
+			template: prose,
+			want:     Capabilities{Endpoint: EndpointChatCompletion, Streaming: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modelType := detectModelType(tt.metadata, 0, tt.template)
+
+			mi := applyModelType(ModelInfo{}, modelType, tt.template, false, false)
+			if mi.Capabilities != tt.want {
+				t.Errorf("capabilities = %+v, want %+v", mi.Capabilities, tt.want)
+			}
+
+			if mi.IsGPTModel != (modelType == ModelTypeGPT) || mi.IsEmbedModel != (modelType == ModelTypeEmbed) || mi.IsRerankModel != (modelType == ModelTypeRerank) {
+				t.Errorf("flags = gpt[%v] embed[%v] rerank[%v], model type %q", mi.IsGPTModel, mi.IsEmbedModel, mi.IsRerankModel, modelType)
+			}
+		})
+	}
+}
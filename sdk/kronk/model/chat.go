@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"path"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/ardanlabs/kronk/sdk/kronk/observ/metrics"
@@ -15,10 +17,10 @@ import (
 )
 
 // Chat performs a chat request and returns the final response.
-// Text inference requests can run concurrently based on the NSeqMax config
-// value, which controls parallel sequence processing. However, requests that
-// include vision or audio content are processed sequentially due to media
-// pipeline constraints.
+// Requests can run concurrently based on the NSeqMax config value, which
+// controls parallel sequence processing. Requests that include vision or audio
+// content share the batch engine with text requests, each media item is
+// encoded into the sequence of the request's slot in an iteration of its own.
 func (m *Model) Chat(ctx context.Context, d D) (ChatResponse, error) {
 	ch := m.ChatStreaming(ctx, d)
 
@@ -40,12 +42,16 @@ func (m *Model) Chat(ctx context.Context, d D) (ChatResponse, error) {
 }
 
 // ChatStreaming performs a chat request and streams the response.
-// Text inference requests can run concurrently based on the NSeqMax config
-// value, which controls parallel sequence processing. However, requests that
-// include vision or audio content are processed sequentially due to media
-// pipeline constraints.
+// Requests can run concurrently based on the NSeqMax config value, which
+// controls parallel sequence processing. Requests that include vision or audio
+// content share the batch engine with text requests, each media item is
+// encoded into the sequence of the request's slot in an iteration of its own.
 func (m *Model) ChatStreaming(ctx context.Context, d D) <-chan ChatResponse {
-	ch := make(chan ChatResponse, 1)
+	if toolCallRepair(d) {
+		return m.chatStreamingRepair(ctx, d)
+	}
+
+	ch := make(chan ChatResponse, m.streamBufferSize())
 
 	go func() {
 		m.activeStreams.Add(1)
@@ -65,6 +71,12 @@ func (m *Model) ChatStreaming(ctx context.Context, d D) <-chan ChatResponse {
 			}
 		}()
 
+		// Embedding and rerank models have no batch engine.
+		if m.batch == nil {
+			m.sendChatError(ctx, ch, id, fmt.Errorf("chat-streaming: model doesn't support chat"))
+			return
+		}
+
 		params, err := m.validateDocument(d)
 		if err != nil {
 			m.sendChatError(ctx, ch, id, err)
@@ -78,12 +90,8 @@ func (m *Model) ChatStreaming(ctx context.Context, d D) <-chan ChatResponse {
 		}
 
 		defer func() {
-			if !batching {
-				if mtmdCtx != 0 {
-					mtmd.Free(mtmdCtx)
-				}
-
-				m.resetContext()
+			if !batching && mtmdCtx != 0 {
+				mtmd.Free(mtmdCtx)
 			}
 		}()
 
@@ -93,45 +101,206 @@ func (m *Model) ChatStreaming(ctx context.Context, d D) <-chan ChatResponse {
 			return
 		}
 
-		// ---------------------------------------------------------------------
-
-		// Use batch engine for text-only requests when available.
-		if m.batch != nil && object == ObjectChatText {
-			job := chatJob{
-				id:      id,
-				ctx:     ctx,
-				d:       d,
-				object:  object,
-				prompt:  prompt,
-				media:   media,
-				params:  params,
-				mtmdCtx: mtmdCtx,
-				ch:      ch,
-			}
-
-			// Engine manages activeStreams for submitted jobs.
-			if err := m.batch.submit(&job); err != nil {
+		// Drop the oldest turns of the conversation when the prompt doesn't
+		// leave room for the response and the request allows it.
+		var overflow ContextOverflow
+		if params.ContextOverflow == ContextOverflowTruncateMiddle {
+			var truncated bool
+			d, prompt, media, truncated, err = m.truncateMiddle(ctx, d, prompt, media, params.MaxTokens)
+			if err != nil {
 				m.sendChatError(ctx, ch, id, err)
 				return
 			}
 
-			batching = true
+			if truncated {
+				overflow = ContextOverflowTruncateMiddle
+			}
+		}
+
+		// ---------------------------------------------------------------------
 
-			// Channel closed and activeStreams decremented by
-			// engine when job completes.
+		job := chatJob{
+			id:       id,
+			ctx:      ctx,
+			d:        d,
+			object:   object,
+			prompt:   prompt,
+			media:    media,
+			params:   params,
+			mtmdCtx:  mtmdCtx,
+			ch:       ch,
+			overflow: overflow,
+			subject:  GetSubject(ctx),
+		}
+
+		// Engine manages activeStreams for submitted jobs.
+		if err := m.batch.submit(&job); err != nil {
+			m.sendChatError(ctx, ch, id, err)
 			return
 		}
 
-		// ---------------------------------------------------------------------
+		// Channel closed and activeStreams decremented by engine when job
+		// completes.
+		batching = true
+	}()
+
+	return ch
+}
+
+// toolCallRepair reports if the request asks for tool call repair. A value
+// that isn't valid is reported when the request is validated.
+func toolCallRepair(d D) bool {
+	val, exists := d["tool_call_repair"]
+	if !exists {
+		return false
+	}
+
+	repair, err := parseBool("tool_call_repair", val)
+	if err != nil {
+		return false
+	}
+
+	return repair
+}
+
+// chatStreamingRepair runs a request that asks for tool call repair. The
+// responses of the first attempt are held back until its tool calls are
+// checked. When a tool call isn't valid, the model is asked once more with
+// its tool calls and their errors added to the conversation and that attempt
+// is streamed instead.
+func (m *Model) chatStreamingRepair(ctx context.Context, d D) <-chan ChatResponse {
+	ch := make(chan ChatResponse, m.streamBufferSize())
+
+	go func() {
+		defer close(ch)
+
+		d = d.Clone()
+		delete(d, "tool_call_repair")
+
+		var held []ChatResponse
+		for resp := range m.ChatStreaming(ctx, d) {
+			held = append(held, resp)
+		}
+
+		var invalid []ResponseToolCall
+		var message *ResponseMessage
+		var usage Usage
+		if n := len(held); n > 0 {
+			last := held[n-1]
+			usage = last.Usage
 
-		// Sequential path for media requests or when engine is not available.
+			if len(last.Choice) > 0 && last.Choice[0].Message != nil {
+				message = last.Choice[0].Message
+				invalid = invalidToolCalls(message.ToolCalls)
+			}
+		}
+
+		if len(invalid) == 0 {
+			for _, resp := range held {
+				if !sendRepairResponse(ctx, ch, resp) {
+					return
+				}
+			}
+			return
+		}
 
-		m.sequentialChatRequest(ctx, id, m.lctx, mtmdCtx, object, prompt, media, params, ch)
+		m.log(ctx, "chat-completion", "status", "tool-call-repair", "id", held[0].ID, "invalid", len(invalid))
+
+		// The model sees the turn with the tool calls it made before the
+		// errors, so the roles keep alternating.
+		messages, _ := d["messages"].([]D)
+		d["messages"] = append(slices.Clone(messages),
+			toolCallMessage(message),
+			D{
+				"role":    RoleUser,
+				"content": toolCallRepairPrompt(invalid),
+			},
+		)
+
+		for resp := range m.ChatStreaming(ctx, d) {
+			// The final response reports the tokens of both attempts.
+			if len(resp.Choice) > 0 && resp.Choice[0].FinishReason() != "" {
+				resp.Usage = addUsage(usage, resp.Usage)
+			}
+
+			if !sendRepairResponse(ctx, ch, resp) {
+				return
+			}
+		}
 	}()
 
 	return ch
 }
 
+// toolCallMessage returns the assistant message of a response with tool calls
+// in the form of a request message. Arguments that couldn't be parsed are
+// passed as the text the model wrote.
+func toolCallMessage(message *ResponseMessage) D {
+	toolCalls := make([]D, len(message.ToolCalls))
+	for i, tc := range message.ToolCalls {
+		var args any = map[string]any(tc.Function.Arguments)
+		if tc.Function.Arguments == nil {
+			args = tc.Raw
+		}
+
+		toolCalls[i] = D{
+			"id":   tc.ID,
+			"type": "function",
+			"function": D{
+				"name":      tc.Function.Name,
+				"arguments": args,
+			},
+		}
+	}
+
+	return D{
+		"role":       RoleAssistant,
+		"content":    message.Content,
+		"tool_calls": toolCalls,
+	}
+}
+
+// toolCallRepairPrompt tells the model what was wrong with its tool calls.
+func toolCallRepairPrompt(invalid []ResponseToolCall) string {
+	var b strings.Builder
+	b.WriteString("The tool calls below are not valid and were not run:\n")
+
+	for _, tc := range invalid {
+		fmt.Fprintf(&b, "\n- %s\n", tc.Error)
+		if tc.Raw != "" {
+			fmt.Fprintf(&b, "  call: %s\n", tc.Raw)
+		}
+	}
+
+	b.WriteString("\nCall the tools again with valid JSON arguments that match the parameters schema of the tool.")
+
+	return b.String()
+}
+
+func sendRepairResponse(ctx context.Context, ch chan<- ChatResponse, resp ChatResponse) bool {
+	select {
+	case <-ctx.Done():
+		return false
+
+	case ch <- resp:
+		return true
+	}
+}
+
+// addUsage adds up the token counts of two attempts of a request. The token
+// rate is the one of the last attempt.
+func addUsage(a Usage, b Usage) Usage {
+	return Usage{
+		PromptTokens:     a.PromptTokens + b.PromptTokens,
+		CachedTokens:     a.CachedTokens + b.CachedTokens,
+		ReasoningTokens:  a.ReasoningTokens + b.ReasoningTokens,
+		CompletionTokens: a.CompletionTokens + b.CompletionTokens,
+		OutputTokens:     a.OutputTokens + b.OutputTokens,
+		TotalTokens:      a.TotalTokens + b.TotalTokens,
+		TokensPerSecond:  b.TokensPerSecond,
+	}
+}
+
 func (m *Model) prepareMediaContext(ctx context.Context, d D) (D, string, mtmd.Context, error) {
 	mediaType, isOpenAIFormat, msgs, err := detectMediaContent(d)
 	if err != nil {
@@ -241,6 +410,21 @@ func (m *Model) validateDocument(d D) (params, error) {
 	return p, nil
 }
 
+// streamBufferSize returns the buffer size of the response channel. The batch
+// engine never blocks on the channel, a slot whose client falls behind the
+// backlog is finished with ErrSlowClient. Beyond the backlog there is room
+// for what a slot sends in one iteration: a delta for the sampled token and
+// each verified draft, then the held back stop sequence content, the rest of
+// the tool calls and the final response. The deltas never take the last
+// place, so the stream always ends with the final or an error response.
+func (m *Model) streamBufferSize() int {
+	if m.batch == nil {
+		return 1
+	}
+
+	return m.cfg.StreamBacklog + (m.cfg.DraftMax + 1) + 3
+}
+
 func (m *Model) sendChatError(ctx context.Context, ch chan<- ChatResponse, id string, err error) {
 	// I want to try and send this message before we check the context.
 	select {
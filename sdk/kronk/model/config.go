@@ -29,6 +29,7 @@ const (
 	defNBatch        = 2 * 1024
 	defNUBatch       = 512
 	defNUBatchVision = 2 * 1024
+	defStreamBacklog = 256
 )
 
 // Logger provides a function for logging messages from different APIs.
@@ -106,10 +107,10 @@ type Logger func(ctx context.Context, msg string, args ...any)
 // a model integrity check before trying to use it.
 //
 // NSeqMax controls concurrency behavior based on model type. For text inference
-// models, it sets the maximum number of sequences processed in parallel within
-// a single model instance (batched inference). For sequential models (embeddings,
-// reranking, vision, audio), it creates that many model instances in a pool for
-// concurrent request handling. When set to 0, a default of 1 is used.
+// models, including vision and audio models, it sets the maximum number of
+// sequences processed in parallel within a single model instance (batched
+// inference). For sequential models (embeddings, reranking), it creates that
+// many model instances in a pool for concurrent request handling. When set to 0, a default of 1 is used.
 //
 // OffloadKQV controls whether the KV cache is offloaded to the GPU. When nil or
 // true, the KV cache is stored on the GPU (default behavior). Set to false to
@@ -130,6 +131,50 @@ type Logger func(ctx context.Context, msg string, args ...any)
 //     (recommended for MoE models like Qwen3-MoE, Mixtral, DeepSeek)
 //
 // When not set, defaults to SplitModeRow for optimal MoE performance.
+//
+// DraftModelFiles is the path to the files of a smaller model from the same
+// family that is used for speculative decoding. The draft model proposes
+// tokens that the model verifies in a single forward pass, which increases
+// tokens per second when the proposals are often accepted. Both models must
+// share the same vocab. The draft weights are loaded once and shared by the
+// instances of the model. When not set, speculative decoding is not used.
+//
+// DraftMax is the maximum number of tokens the draft model proposes at a
+// time. When set to 0, the default value is 16.
+//
+// DraftMin is the minimum number of tokens the draft model needs to propose
+// for them to be verified. When set to 0, any number of tokens is verified.
+//
+// ContextOverflow is the default strategy used when a request doesn't fit in
+// the context window. Requests can override it with the context_overflow
+// parameter. When not set, the request fails with ContextOverflowError.
+//
+// PrefillBudget is the maximum number of prompt tokens the batch engine adds
+// to a batch while other slots are generating. The tokens of generating slots
+// always go first, and the rest of NBatch is shared by the prompts being
+// processed. A lower value keeps the time between tokens steady for requests
+// that are generating, at the cost of a longer time to first token for new
+// requests. When set to 0, all of NBatch can be used for prompts.
+//
+// StreamBacklog is the number of responses the batch engine queues for a
+// streaming client that isn't reading them fast enough. A request whose client
+// falls further behind is cancelled with ErrSlowClient so the other requests
+// keep generating at full speed. When set to 0, the default value is 256.
+//
+// MaxDecodeFailures is the number of batches in a row the batch engine can
+// fail to decode before the model is marked unhealthy. The requests in a
+// failed batch get an error and the engine continues with a cleared KV cache.
+// When set to 0, the default value is 3.
+//
+// ModelType overrides the type of the model, which is detected from the GGUF
+// metadata by default. Use it when a model is classified incorrectly, for
+// example an embedding model without a pooling type in its metadata. When not
+// set, ModelTypeAuto is used.
+//
+// ToolCallFormat is the name of the format the model uses for tool calls,
+// which is detected from the chat template and the special tokens of the
+// model by default. The built-in formats are hermes, mistral, llama3 and
+// deepseek, and more can be added with RegisterToolCallFormat.
 type Config struct {
 	Log                  Logger
 	ModelFiles           []string
@@ -151,6 +196,15 @@ type Config struct {
 	OpOffload            *bool
 	NGpuLayers           *int32
 	SplitMode            SplitMode
+	DraftModelFiles      []string
+	DraftMax             int
+	DraftMin             int
+	ContextOverflow      ContextOverflow
+	PrefillBudget        int
+	StreamBacklog        int
+	MaxDecodeFailures    int
+	ModelType            ModelType
+	ToolCallFormat       string
 }
 
 func validateConfig(ctx context.Context, cfg Config, log Logger) error {
@@ -158,6 +212,16 @@ func validateConfig(ctx context.Context, cfg Config, log Logger) error {
 		return fmt.Errorf("validate-config: model file is required")
 	}
 
+	if _, err := ParseModelType(string(cfg.ModelType)); err != nil {
+		return fmt.Errorf("validate-config: %w", err)
+	}
+
+	if cfg.ToolCallFormat != "" {
+		if _, ok := LookupToolCallFormat(cfg.ToolCallFormat); !ok {
+			return fmt.Errorf("validate-config: unknown tool call format: %s", cfg.ToolCallFormat)
+		}
+	}
+
 	if !cfg.IgnoreIntegrityCheck {
 		for _, modelFile := range cfg.ModelFiles {
 			log(ctx, "validate-config", "model-file", modelFile)
@@ -174,6 +238,14 @@ func validateConfig(ctx context.Context, cfg Config, log Logger) error {
 				return fmt.Errorf("validate-config: prog-file[%s]: %w", cfg.ProjFile, err)
 			}
 		}
+
+		for _, draftFile := range cfg.DraftModelFiles {
+			log(ctx, "validate-config", "draft-model-file", draftFile)
+
+			if err := CheckModel(draftFile, true); err != nil {
+				return fmt.Errorf("validate-config: draft-model-file[%s]: %w", draftFile, err)
+			}
+		}
 	}
 
 	return nil
@@ -210,6 +282,34 @@ func adjustConfig(cfg Config, model llama.Model) Config {
 		cfg.NUBatch = cfg.NBatch
 	}
 
+	if cfg.DraftMax <= 0 {
+		cfg.DraftMax = defDraftMax
+	}
+
+	if cfg.DraftMin < 0 {
+		cfg.DraftMin = 0
+	}
+
+	if cfg.DraftMin > cfg.DraftMax {
+		cfg.DraftMin = cfg.DraftMax
+	}
+
+	if cfg.PrefillBudget < 0 || cfg.PrefillBudget > cfg.NBatch {
+		cfg.PrefillBudget = 0
+	}
+
+	if cfg.StreamBacklog <= 0 {
+		cfg.StreamBacklog = defStreamBacklog
+	}
+
+	if cfg.MaxDecodeFailures <= 0 {
+		cfg.MaxDecodeFailures = defMaxDecodeFailures
+	}
+
+	if cfg.ContextOverflow == "" {
+		cfg.ContextOverflow = ContextOverflowError
+	}
+
 	// This value must be 1 to properly configure the batch engine.
 	if cfg.NSeqMax <= 0 {
 		cfg.NSeqMax = 1
@@ -238,8 +338,11 @@ func adjustContextWindow(cfg Config, model llama.Model) Config {
 func modelCtxParams(cfg Config, mi ModelInfo) llama.ContextParams {
 	ctxParams := llama.ContextDefaultParams()
 
+	// Embedding and rerank inputs are packed across sequences, so all of
+	// the context is shared by the sequences of a batch.
 	if mi.IsEmbedModel || mi.IsRerankModel {
 		ctxParams.Embeddings = 1
+		ctxParams.KVUnified = 1
 	}
 
 	if mi.IsRerankModel {
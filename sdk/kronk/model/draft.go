@@ -0,0 +1,268 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/hybridgroup/yzma/pkg/llama"
+)
+
+const (
+	// defDraftMax is the default number of tokens the draft model proposes
+	// for each verification decode of the target model.
+	defDraftMax = 16
+
+	// draftPMin is the minimum probability a draft token needs to be
+	// proposed. Drafting stops at the first token the draft model is less
+	// confident about since it's unlikely to be accepted.
+	draftPMin = 0.75
+
+	// draftVocabMaxDiff is the largest difference in vocab size allowed
+	// between the target and draft models.
+	draftVocabMaxDiff = 128
+
+	// draftVocabCheckStart is the first token whose text is compared
+	// between the vocabs. The tokens before it are special tokens that
+	// often differ between models of the same family.
+	draftVocabCheckStart = 5
+)
+
+// draftModel is a small model from the same family as the target model that
+// proposes tokens for speculative decoding. The target model verifies all the
+// proposed tokens in a single decode and keeps the ones it agrees with.
+type draftModel struct {
+	vocab   llama.Vocab
+	lctx    llama.Context
+	mem     llama.Memory
+	batch   llama.Batch
+	nBatch  int
+	nMax    int
+	nMin    int
+	nTarget int
+
+	// cached mirrors the tokens held in the draft KV cache for each
+	// sequence so only new tokens need to be decoded on the next draft.
+	cached map[llama.SeqId][]llama.Token
+}
+
+// loadDraftModel loads the weights of the draft model and makes sure they can
+// draft for the target model. They are shared by the instances of the target
+// model, which each create their own draft context.
+func loadDraftModel(ctx context.Context, log Logger, cfg Config, target llama.Model, mParams llama.ModelParams) (llama.Model, error) {
+	mdl, err := loadModelFromFiles(ctx, log, cfg.DraftModelFiles, mParams)
+	if err != nil {
+		return 0, fmt.Errorf("load-draft-model: unable to load draft model: %w", err)
+	}
+
+	if err := checkDraftCompatible(llama.ModelGetVocab(target), llama.ModelGetVocab(mdl)); err != nil {
+		llama.ModelFree(mdl)
+		return 0, fmt.Errorf("load-draft-model: %w", err)
+	}
+
+	return mdl, nil
+}
+
+func newDraftModel(cfg Config, w *weights) (*draftModel, error) {
+	ctxParams := modelCtxParams(cfg, ModelInfo{})
+
+	lctx, err := llama.InitFromModel(w.draft, ctxParams)
+	if err != nil {
+		return nil, fmt.Errorf("new-draft-model: unable to init context: %w", err)
+	}
+
+	mem, err := llama.GetMemory(lctx)
+	if err != nil {
+		llama.Free(lctx)
+		return nil, fmt.Errorf("new-draft-model: unable to get memory: %w", err)
+	}
+
+	dm := draftModel{
+		vocab:   llama.ModelGetVocab(w.draft),
+		lctx:    lctx,
+		mem:     mem,
+		batch:   llama.BatchInit(int32(cfg.NBatch), 0, 1),
+		nBatch:  cfg.NBatch,
+		nMax:    cfg.DraftMax,
+		nMin:    cfg.DraftMin,
+		nTarget: int(llama.VocabNTokens(llama.ModelGetVocab(w.model))),
+		cached:  make(map[llama.SeqId][]llama.Token),
+	}
+
+	return &dm, nil
+}
+
+// vocabInfo is what the compatibility check needs to know about a vocab.
+type vocabInfo struct {
+	typ     llama.VocabType
+	nTokens int
+	bos     llama.Token
+	eos     llama.Token
+	addBOS  bool
+	addEOS  bool
+	text    func(token llama.Token) string
+}
+
+func newVocabInfo(vocab llama.Vocab) vocabInfo {
+	return vocabInfo{
+		typ:     llama.GetVocabType(vocab),
+		nTokens: int(llama.VocabNTokens(vocab)),
+		bos:     llama.VocabBOS(vocab),
+		eos:     llama.VocabEOS(vocab),
+		addBOS:  llama.VocabGetAddBOS(vocab),
+		addEOS:  llama.VocabGetAddEOS(vocab),
+		text: func(token llama.Token) string {
+			return llama.VocabGetText(vocab, token)
+		},
+	}
+}
+
+// checkDraftCompatible makes sure the draft model tokenizes text the same
+// way as the target model, otherwise none of the drafts would be accepted.
+func checkDraftCompatible(target llama.Vocab, draft llama.Vocab) error {
+	return compareVocabs(newVocabInfo(target), newVocabInfo(draft))
+}
+
+// compareVocabs follows the checks llama.cpp makes before speculative
+// decoding. The vocabs must be of the same type with the same special tokens,
+// close in size and have the same text for the tokens they share.
+func compareVocabs(target vocabInfo, draft vocabInfo) error {
+	if target.typ != draft.typ {
+		return fmt.Errorf("check-draft-compatible: vocab type target[%d] draft[%d] don't match", target.typ, draft.typ)
+	}
+
+	if target.bos != draft.bos || target.eos != draft.eos || target.addBOS != draft.addBOS || target.addEOS != draft.addEOS {
+		return fmt.Errorf("check-draft-compatible: special tokens of the target and draft models don't match")
+	}
+
+	if diff := max(target.nTokens, draft.nTokens) - min(target.nTokens, draft.nTokens); diff > draftVocabMaxDiff {
+		return fmt.Errorf("check-draft-compatible: vocab size target[%d] draft[%d] differ by more than %d", target.nTokens, draft.nTokens, draftVocabMaxDiff)
+	}
+
+	for i := draftVocabCheckStart; i < min(target.nTokens, draft.nTokens); i++ {
+		token := llama.Token(i)
+		if tt, dt := target.text(token), draft.text(token); tt != dt {
+			return fmt.Errorf("check-draft-compatible: token[%d] target[%q] draft[%q] don't match", i, tt, dt)
+		}
+	}
+
+	return nil
+}
+
+// free releases the draft context. The draft weights are freed along with the
+// weights of the target model.
+func (d *draftModel) free() {
+	llama.BatchFree(d.batch)
+	llama.Synchronize(d.lctx)
+	llama.Free(d.lctx)
+}
+
+// maxDraft returns the number of tokens that can be drafted given the room
+// left for the request. Zero means the drafts are not worth verifying.
+func (d *draftModel) maxDraft(room int) int {
+	n := min(d.nMax, room)
+	if n <= 0 || n < d.nMin {
+		return 0
+	}
+
+	return n
+}
+
+// draft proposes up to n tokens that follow last for the sequence. The
+// history is the set of tokens that come before last.
+func (d *draftModel) draft(seqID llama.SeqId, history []llama.Token, last llama.Token, n int) []llama.Token {
+	if n <= 0 {
+		return nil
+	}
+
+	want := make([]llama.Token, 0, len(history)+1)
+	want = append(want, history...)
+	want = append(want, last)
+
+	if err := d.sync(seqID, want); err != nil {
+		return nil
+	}
+
+	nVocab := int(llama.VocabNTokens(d.vocab))
+	pos := llama.Pos(len(want))
+
+	drafts := make([]llama.Token, 0, n)
+	for {
+		logits, err := llama.GetLogitsIth(d.lctx, -1, nVocab)
+		if err != nil || logits == nil {
+			break
+		}
+
+		_, top := logprobsFromLogits(logits, -1, 1)
+		if len(top) == 0 || math.Exp(float64(top[0].logprob)) < draftPMin {
+			break
+		}
+
+		// A token past the vocab of the target model would fail the
+		// verification decode.
+		token := llama.Token(top[0].token)
+		if int(token) >= d.nTarget || llama.VocabIsEOG(d.vocab, token) {
+			break
+		}
+
+		drafts = append(drafts, token)
+		if len(drafts) == n {
+			break
+		}
+
+		batchClear(&d.batch)
+		batchAdd(&d.batch, token, pos, []llama.SeqId{seqID}, true)
+		if ret, err := llama.Decode(d.lctx, d.batch); err != nil || ret != 0 {
+			d.forget(seqID)
+			break
+		}
+
+		d.cached[seqID] = append(d.cached[seqID], token)
+		pos++
+	}
+
+	return drafts
+}
+
+// sync brings the draft KV cache for the sequence in line with the specified
+// tokens. Only the tokens after the prefix already in the cache are decoded
+// and the last token is always decoded so its logits are available.
+func (d *draftModel) sync(seqID llama.SeqId, tokens []llama.Token) error {
+	cached := d.cached[seqID]
+
+	n := min(commonPrefix(cached, tokens), len(tokens)-1)
+	if n < len(cached) {
+		ok, err := llama.MemorySeqRm(d.mem, seqID, llama.Pos(n), -1)
+		if err != nil || !ok {
+			llama.MemorySeqRm(d.mem, seqID, -1, -1)
+			n = 0
+		}
+		cached = cached[:n]
+	}
+
+	for i := n; i < len(tokens); i += d.nBatch {
+		end := min(i+d.nBatch, len(tokens))
+
+		batchClear(&d.batch)
+		for j := i; j < end; j++ {
+			batchAdd(&d.batch, tokens[j], llama.Pos(j), []llama.SeqId{seqID}, j == len(tokens)-1)
+		}
+
+		if ret, err := llama.Decode(d.lctx, d.batch); err != nil || ret != 0 {
+			d.forget(seqID)
+			return fmt.Errorf("sync: unable to decode draft tokens: ret[%d]: %w", ret, err)
+		}
+
+		cached = append(cached, tokens[i:end]...)
+	}
+
+	d.cached[seqID] = cached
+
+	return nil
+}
+
+// forget drops the draft KV cache for the sequence.
+func (d *draftModel) forget(seqID llama.SeqId) {
+	llama.MemorySeqRm(d.mem, seqID, -1, -1)
+	delete(d.cached, seqID)
+}
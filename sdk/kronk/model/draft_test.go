@@ -0,0 +1,87 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hybridgroup/yzma/pkg/llama"
+)
+
+func Test_MaxDraft(t *testing.T) {
+	d := draftModel{nMax: 16, nMin: 4}
+
+	tests := []struct {
+		name string
+		room int
+		want int
+	}{
+		{"plenty-of-room", 100, 16},
+		{"limited-room", 8, 8},
+		{"below-min", 3, 0},
+		{"no-room", 0, 0},
+		{"negative-room", -5, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := d.maxDraft(tt.room); got != tt.want {
+				t.Errorf("maxDraft(%d) = %d, want %d", tt.room, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_CompareVocabs(t *testing.T) {
+	text := func(token llama.Token) string {
+		return fmt.Sprintf("tok%d", token)
+	}
+
+	vocab := func(nTokens int) vocabInfo {
+		return vocabInfo{typ: llama.VocabTypeBPE, nTokens: nTokens, bos: 1, eos: 2, addBOS: true, text: text}
+	}
+
+	renamed := vocab(1000)
+	renamed.text = func(token llama.Token) string {
+		if token == 500 {
+			return "other"
+		}
+		return text(token)
+	}
+
+	special := vocab(1000)
+	special.text = func(token llama.Token) string {
+		if token < draftVocabCheckStart {
+			return "special"
+		}
+		return text(token)
+	}
+
+	eos := vocab(1000)
+	eos.eos = 3
+
+	spm := vocab(1000)
+	spm.typ = llama.VocabTypeSPM
+
+	tests := []struct {
+		name  string
+		draft vocabInfo
+		ok    bool
+	}{
+		{"same", vocab(1000), true},
+		{"smaller-draft", vocab(1000 - draftVocabMaxDiff), true},
+		{"special-tokens-text", special, true},
+		{"size", vocab(1000 - draftVocabMaxDiff - 1), false},
+		{"token-text", renamed, false},
+		{"eos", eos, false},
+		{"type", spm, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := compareVocabs(vocab(1000), tt.draft)
+			if (err == nil) != tt.ok {
+				t.Errorf("compareVocabs() error = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
@@ -9,8 +9,10 @@ import (
 	"github.com/hybridgroup/yzma/pkg/llama"
 )
 
-// Embeddings performs batch embedding for multiple inputs in a single
-// forward pass. This is more efficient than calling Embeddings multiple times.
+// Embeddings performs batch embedding for multiple inputs. The inputs are
+// packed into batches across sequences, up to NSeqMax+1 inputs and NBatch
+// tokens per forward pass. This is more efficient than calling Embeddings
+// multiple times.
 //
 // Supported options in d:
 //   - input ([]string): the texts to embed (required)
@@ -18,10 +20,10 @@ import (
 //   - truncate_direction (string): "right" (default) or "left"
 //   - dimensions (int): reduce output to first N dimensions (for Matryoshka models)
 //
-// Each model instance processes calls sequentially (llama.cpp only supports
-// sequence 0 for embedding extraction). Use NSeqMax > 1 to create multiple
-// model instances for concurrent request handling. Batch multiple texts in the
-// input parameter for better performance within a single request.
+// Each model instance processes calls sequentially using the context of the
+// instance. Use NSeqMax > 1 to create multiple model instances for concurrent
+// request handling. Batch multiple texts in the input parameter for better
+// performance within a single request.
 func (m *Model) Embeddings(ctx context.Context, d D) (EmbedReponse, error) {
 	if !m.modelInfo.IsEmbedModel {
 		return EmbedReponse{}, fmt.Errorf("embeddings: model doesn't support embedding")
@@ -56,20 +58,8 @@ func (m *Model) Embeddings(ctx context.Context, d D) (EmbedReponse, error) {
 
 	// -------------------------------------------------------------------------
 
-	lctx, err := llama.InitFromModel(m.model, m.ctxParams)
-	if err != nil {
-		return EmbedReponse{}, fmt.Errorf("embeddings: unable to init from model: %w", err)
-	}
-
-	defer func() {
-		llama.Synchronize(lctx)
-		llama.Free(lctx)
-	}()
-
-	mem, err := llama.GetMemory(lctx)
-	if err != nil {
-		return EmbedReponse{}, fmt.Errorf("embeddings: unable to get memory: %w", err)
-	}
+	m.pooledMu.Lock()
+	defer m.pooledMu.Unlock()
 
 	select {
 	case <-ctx.Done():
@@ -78,11 +68,7 @@ func (m *Model) Embeddings(ctx context.Context, d D) (EmbedReponse, error) {
 	default:
 	}
 
-	maxTokens := int(llama.NUBatch(lctx))
-	ctxTokens := int(llama.NCtx(lctx))
-	if ctxTokens < maxTokens {
-		maxTokens = ctxTokens
-	}
+	maxTokens := maxPooledTokens(m.lctx)
 
 	truncate, _ := d["truncate"].(bool)
 	direction, _ := d["truncate_direction"].(string)
@@ -123,38 +109,17 @@ func (m *Model) Embeddings(ctx context.Context, d D) (EmbedReponse, error) {
 
 	// -------------------------------------------------------------------------
 
-	// Process each input sequentially within the same context.
+	// Process the inputs in batches within the same context.
 
 	embedData := make([]EmbedData, len(inputs))
 	totalPromptTokens := 0
 
-	for i, tokens := range allTokens {
-		select {
-		case <-ctx.Done():
-			return EmbedReponse{}, ctx.Err()
-
-		default:
-		}
-
+	for _, tokens := range allTokens {
 		totalPromptTokens += len(tokens)
+	}
 
-		batch := llama.BatchGetOne(tokens)
-
-		ret, err := llama.Decode(lctx, batch)
-		if err != nil {
-			return EmbedReponse{}, fmt.Errorf("embeddings: decode failed for input[%d]: %w", i, err)
-		}
-
-		if ret != 0 {
-			return EmbedReponse{}, fmt.Errorf("embeddings: decode returned non-zero for input[%d]: %d", i, ret)
-		}
-
-		rawVec, err := llama.GetEmbeddingsSeq(lctx, 0, nativeDim)
-		if err != nil {
-			return EmbedReponse{}, fmt.Errorf("embeddings: unable to get embeddings for input[%d]: %w", i, err)
-		}
-
-		// Copy the vector since llama memory is invalidated by MemoryClear.
+	f := func(i int, rawVec []float32) error {
+		// Copy the vector since llama memory is invalidated by the next decode.
 		vec := make([]float32, len(rawVec))
 		copy(vec, rawVec)
 
@@ -170,8 +135,11 @@ func (m *Model) Embeddings(ctx context.Context, d D) (EmbedReponse, error) {
 			Embedding: vec,
 		}
 
-		// Clear KV cache before next input.
-		llama.MemoryClear(mem, true)
+		return nil
+	}
+
+	if err := m.decodePooled(ctx, allTokens, nativeDim, f); err != nil {
+		return EmbedReponse{}, fmt.Errorf("embeddings: %w", err)
 	}
 
 	// -------------------------------------------------------------------------
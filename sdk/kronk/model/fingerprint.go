@@ -0,0 +1,68 @@
+package model
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// libraryVersion holds the version of the llama.cpp library that is loaded.
+var libraryVersion atomic.Value
+
+// SetLibraryVersion records the version of the llama.cpp library that was
+// loaded so it can be reported as part of the system fingerprint.
+func SetLibraryVersion(version string) {
+	libraryVersion.Store(version)
+}
+
+// LibraryVersion returns the version of the llama.cpp library that is loaded
+// or an empty string if it's not known.
+func LibraryVersion() string {
+	v, _ := libraryVersion.Load().(string)
+	return v
+}
+
+// systemFingerprint identifies the model files and the llama.cpp library
+// version used to generate a response. Two responses with the same
+// fingerprint were produced by the same backend configuration.
+func systemFingerprint(modelFiles []string) string {
+	h := sha256.New()
+
+	for _, modelFile := range modelFiles {
+		fmt.Fprintf(h, "%s\n", modelFileIdentity(modelFile))
+	}
+
+	fmt.Fprintf(h, "llama.cpp:%s\n", LibraryVersion())
+
+	return "fp_" + hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// modelFileIdentity uses the sha256 recorded in the model's sha file when
+// there is one, since hashing the model file itself is too slow. Otherwise
+// the file name and size are used.
+func modelFileIdentity(modelFile string) string {
+	shaFile := filepath.Join(filepath.Dir(modelFile), "sha", filepath.Base(modelFile))
+
+	if f, err := os.Open(shaFile); err == nil {
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if sha, ok := strings.CutPrefix(scanner.Text(), "oid sha256:"); ok {
+				return sha
+			}
+		}
+	}
+
+	var size int64
+	if info, err := os.Stat(modelFile); err == nil {
+		size = info.Size()
+	}
+
+	return fmt.Sprintf("%s:%d", filepath.Base(modelFile), size)
+}
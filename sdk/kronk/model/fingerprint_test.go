@@ -0,0 +1,106 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hybridgroup/yzma/pkg/llama"
+)
+
+func Test_ParseSeed(t *testing.T) {
+	tests := []struct {
+		name string
+		val  any
+		want uint32
+		ok   bool
+	}{
+		{"nil", nil, llama.DefaultSeed, true},
+		{"zero", 0, 0, true},
+		{"float", float64(42), 42, true},
+		{"string", "4294967294", 4294967294, true},
+		{"negative", -1, 0, false},
+		{"default-seed", "4294967295", 0, false},
+		{"not-a-number", "abc", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSeed("seed", tt.val)
+			if (err == nil) != tt.ok {
+				t.Fatalf("parseSeed(%v) error = %v, want ok %v", tt.val, err, tt.ok)
+			}
+
+			if got != tt.want {
+				t.Errorf("parseSeed(%v) = %d, want %d", tt.val, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_SystemFingerprint(t *testing.T) {
+	dir := t.TempDir()
+
+	modelFile := filepath.Join(dir, "model.gguf")
+	if err := os.WriteFile(modelFile, []byte("weights"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer SetLibraryVersion(LibraryVersion())
+	SetLibraryVersion("b1000")
+
+	fp := systemFingerprint([]string{modelFile})
+
+	t.Run("format", func(t *testing.T) {
+		if !strings.HasPrefix(fp, "fp_") || len(fp) != len("fp_")+12 {
+			t.Errorf("fingerprint = %q, want fp_ and 12 hex characters", fp)
+		}
+	})
+
+	t.Run("deterministic", func(t *testing.T) {
+		if got := systemFingerprint([]string{modelFile}); got != fp {
+			t.Errorf("fingerprint = %q, want %q", got, fp)
+		}
+	})
+
+	t.Run("library-version", func(t *testing.T) {
+		SetLibraryVersion("b2000")
+		defer SetLibraryVersion("b1000")
+
+		if got := systemFingerprint([]string{modelFile}); got == fp {
+			t.Errorf("fingerprint didn't change with the library version: %q", got)
+		}
+	})
+
+	t.Run("model-size", func(t *testing.T) {
+		other := filepath.Join(t.TempDir(), "model.gguf")
+		if err := os.WriteFile(other, []byte("other weights"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := systemFingerprint([]string{other}); got == fp {
+			t.Errorf("fingerprint didn't change with the model file: %q", got)
+		}
+	})
+
+	t.Run("sha-file", func(t *testing.T) {
+		if err := os.Mkdir(filepath.Join(dir, "sha"), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		shaFile := filepath.Join(dir, "sha", "model.gguf")
+		sha := "version https://git-lfs.github.com/spec/v1\noid sha256:0123456789abcdef\nsize 7\n"
+		if err := os.WriteFile(shaFile, []byte(sha), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := modelFileIdentity(modelFile); got != "0123456789abcdef" {
+			t.Errorf("model identity = %q, want the sha256 of the sha file", got)
+		}
+
+		if got := systemFingerprint([]string{modelFile}); got == fp {
+			t.Errorf("fingerprint didn't use the sha file: %q", got)
+		}
+	})
+}
@@ -0,0 +1,630 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Response format types supported by the response_format parameter.
+const (
+	ResponseFormatText       = "text"
+	ResponseFormatJSONObject = "json_object"
+	ResponseFormatJSONSchema = "json_schema"
+)
+
+// jsonPrimitives are the GBNF rules for the JSON building blocks. They are
+// only added to a grammar when a rule references them.
+var jsonPrimitives = map[string]string{
+	"space":         `| " " | "\n" [ \t]{0,20}`,
+	"boolean":       `("true" | "false") space`,
+	"null":          `"null" space`,
+	"integral-part": `[0] | [1-9] [0-9]{0,15}`,
+	"decimal-part":  `[0-9]{1,16}`,
+	"integer":       `("-"? integral-part) space`,
+	"number":        `("-"? integral-part) ("." decimal-part)? ([eE] [-+]? integral-part)? space`,
+	"char":          `[^"\\\x7F\x00-\x1F] | [\\] (["\\bfnrt/] | "u" [0-9a-fA-F]{4})`,
+	"string":        `"\"" char* "\"" space`,
+	"value":         `object | array | string | number | boolean | null`,
+	"object":        `"{" space ( string ":" space value ("," space string ":" space value)* )? "}" space`,
+	"array":         `"[" space ( value ("," space value)* )? "]" space`,
+}
+
+// jsonPrimitiveDeps lists the primitives each primitive references.
+var jsonPrimitiveDeps = map[string][]string{
+	"boolean": {"space"},
+	"null":    {"space"},
+	"integer": {"integral-part", "space"},
+	"number":  {"integral-part", "decimal-part", "space"},
+	"string":  {"char", "space"},
+	"value":   {"object", "array", "string", "number", "boolean", "null"},
+	"object":  {"string", "value", "space"},
+	"array":   {"value", "space"},
+}
+
+var invalidRuleChars = regexp.MustCompile(`[^a-zA-Z0-9-]+`)
+
+// schemaConverter turns a JSON Schema into a set of GBNF rules. It supports
+// the subset of JSON Schema used for structured outputs: object, array,
+// string, number, integer, boolean and null types along with properties,
+// required, items, prefixItems, minItems, maxItems, minLength, maxLength,
+// enum, const, anyOf, oneOf, allOf and local $ref references.
+type schemaConverter struct {
+	root  any
+	rules map[string]string
+	order []string
+	refs  map[string]string
+}
+
+// jsonSchemaRules converts the schema into GBNF rules. It returns the rules
+// and the name of the rule that matches a document for the schema. The
+// caller is responsible for adding the root rule.
+//
+// Object properties are generated in alphabetical order, with the required
+// properties first, since the order of a decoded JSON object is not known.
+func jsonSchemaRules(schema any) (string, string, error) {
+	root, err := normalizeSchema(schema)
+	if err != nil {
+		return "", "", err
+	}
+
+	c := schemaConverter{
+		root:  root,
+		rules: make(map[string]string),
+		refs:  make(map[string]string),
+	}
+
+	name, err := c.visit(root, "json")
+	if err != nil {
+		return "", "", err
+	}
+
+	return c.String(), name, nil
+}
+
+// jsonObjectRules returns the GBNF rules for any JSON object along with the
+// name of the rule to start from.
+func jsonObjectRules() (string, string) {
+	var c schemaConverter
+	c.rules = make(map[string]string)
+	name := c.primitive("object")
+
+	return c.String(), name
+}
+
+// String returns the rules in the order they were added.
+func (c *schemaConverter) String() string {
+	var b strings.Builder
+	for _, name := range c.order {
+		fmt.Fprintf(&b, "%s ::= %s\n", name, c.rules[name])
+	}
+
+	return b.String()
+}
+
+func (c *schemaConverter) addRule(name string, body string) string {
+	name = invalidRuleChars.ReplaceAllString(name, "-")
+
+	key := name
+	for i := 0; ; i++ {
+		if i > 0 {
+			key = name + strconv.Itoa(i)
+		}
+
+		existing, exists := c.rules[key]
+		if !exists {
+			break
+		}
+
+		if existing == body {
+			return key
+		}
+	}
+
+	c.rules[key] = body
+	c.order = append(c.order, key)
+
+	return key
+}
+
+func (c *schemaConverter) primitive(name string) string {
+	if _, exists := c.rules[name]; exists {
+		return name
+	}
+
+	// The rule is added before its dependencies since value and object
+	// reference each other.
+	c.rules[name] = jsonPrimitives[name]
+	c.order = append(c.order, name)
+
+	for _, dep := range jsonPrimitiveDeps[name] {
+		c.primitive(dep)
+	}
+
+	return name
+}
+
+func (c *schemaConverter) visit(schema any, name string) (string, error) {
+	switch s := schema.(type) {
+	case bool:
+		if !s {
+			return "", fmt.Errorf("visit: schema[%s]: false schema can't match any value", name)
+		}
+		return c.primitive("value"), nil
+
+	case map[string]any:
+		body, err := c.visitBody(s, name)
+		if err != nil {
+			return "", err
+		}
+
+		if _, exists := jsonPrimitives[body]; exists {
+			return body, nil
+		}
+
+		return c.addRule(name, body), nil
+
+	default:
+		return "", fmt.Errorf("visit: schema[%s]: expected an object, got %T", name, schema)
+	}
+}
+
+func (c *schemaConverter) visitBody(s map[string]any, name string) (string, error) {
+	if ref, ok := s["$ref"].(string); ok {
+		return c.visitRef(ref)
+	}
+
+	if v, exists := s["const"]; exists {
+		return jsonLiteral(v) + " " + c.primitive("space"), nil
+	}
+
+	if v, exists := s["enum"]; exists {
+		values, ok := v.([]any)
+		if !ok || len(values) == 0 {
+			return "", fmt.Errorf("visit: schema[%s]: enum must be a non-empty array", name)
+		}
+
+		alts := make([]string, len(values))
+		for i, v := range values {
+			alts[i] = jsonLiteral(v)
+		}
+
+		return "(" + strings.Join(alts, " | ") + ") " + c.primitive("space"), nil
+	}
+
+	for _, key := range []string{"anyOf", "oneOf"} {
+		if v, exists := s[key]; exists {
+			subs, ok := v.([]any)
+			if !ok || len(subs) == 0 {
+				return "", fmt.Errorf("visit: schema[%s]: %s must be a non-empty array", name, key)
+			}
+
+			alts := make([]string, len(subs))
+			for i, sub := range subs {
+				rule, err := c.visit(sub, fmt.Sprintf("%s-%d", name, i))
+				if err != nil {
+					return "", err
+				}
+				alts[i] = rule
+			}
+
+			return strings.Join(alts, " | "), nil
+		}
+	}
+
+	if v, exists := s["allOf"]; exists {
+		merged, err := c.mergeAllOf(s, v, name)
+		if err != nil {
+			return "", err
+		}
+
+		return c.visitBody(merged, name)
+	}
+
+	switch t := s["type"].(type) {
+	case string:
+		return c.visitType(s, t, name)
+
+	case []any:
+		alts := make([]string, len(t))
+		for i, v := range t {
+			typ, ok := v.(string)
+			if !ok {
+				return "", fmt.Errorf("visit: schema[%s]: type must be a string or array of strings", name)
+			}
+
+			body, err := c.visitType(s, typ, name+"-"+typ)
+			if err != nil {
+				return "", err
+			}
+			alts[i] = c.addRule(name+"-"+typ, body)
+		}
+
+		return strings.Join(alts, " | "), nil
+
+	case nil:
+		switch {
+		case s["properties"] != nil || s["additionalProperties"] != nil:
+			return c.visitType(s, "object", name)
+
+		case s["items"] != nil || s["prefixItems"] != nil:
+			return c.visitType(s, "array", name)
+		}
+
+		return c.primitive("value"), nil
+
+	default:
+		return "", fmt.Errorf("visit: schema[%s]: type must be a string or array of strings", name)
+	}
+}
+
+func (c *schemaConverter) visitType(s map[string]any, typ string, name string) (string, error) {
+	switch typ {
+	case "object":
+		return c.visitObject(s, name)
+
+	case "array":
+		return c.visitArray(s, name)
+
+	case "string":
+		minLen, maxLen := schemaInt(s, "minLength"), schemaInt(s, "maxLength")
+		if minLen < 0 && maxLen < 0 {
+			return c.primitive("string"), nil
+		}
+
+		return `"\"" ` + repetition(c.primitive("char"), max(minLen, 0), maxLen, "") + ` "\"" ` + c.primitive("space"), nil
+
+	case "number", "integer", "boolean", "null":
+		return c.primitive(typ), nil
+
+	default:
+		return "", fmt.Errorf("visit-type: schema[%s]: unsupported type %q", name, typ)
+	}
+}
+
+func (c *schemaConverter) visitObject(s map[string]any, name string) (string, error) {
+	props, _ := s["properties"].(map[string]any)
+
+	if len(props) == 0 {
+		switch ap := s["additionalProperties"].(type) {
+		case map[string]any:
+			valueRule, err := c.visit(ap, name+"-additional-value")
+			if err != nil {
+				return "", err
+			}
+
+			kv := c.addRule(name+"-additional-kv", c.primitive("string")+` ":" space `+valueRule)
+			return `"{" space ( ` + kv + ` ( "," space ` + kv + ` )* )? "}" space`, nil
+
+		case bool:
+			if !ap {
+				return `"{" ` + c.primitive("space") + ` "}" space`, nil
+			}
+		}
+
+		return c.primitive("object"), nil
+	}
+
+	required := make(map[string]bool)
+	if reqs, ok := s["required"].([]any); ok {
+		for _, r := range reqs {
+			if key, ok := r.(string); ok {
+				required[key] = true
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(props))
+	for key := range props {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	kvRules := make(map[string]string, len(keys))
+	var requiredKeys, optionalKeys []string
+
+	for _, key := range keys {
+		valueRule, err := c.visit(props[key], name+"-"+key)
+		if err != nil {
+			return "", err
+		}
+
+		kvRules[key] = c.addRule(name+"-"+key+"-kv", jsonLiteral(key)+` space ":" space `+valueRule)
+
+		switch required[key] {
+		case true:
+			requiredKeys = append(requiredKeys, key)
+		default:
+			optionalKeys = append(optionalKeys, key)
+		}
+	}
+
+	c.primitive("space")
+
+	// Each optional property can appear or not, so the rule lists every
+	// property that can come first and chains the remaining ones behind it.
+	var optionalChain func(keys []string, first bool) string
+	optionalChain = func(keys []string, first bool) string {
+		res := kvRules[keys[0]]
+		if !first {
+			res = `( "," space ` + res + ` )?`
+		}
+
+		if len(keys) > 1 {
+			res += " " + c.addRule(name+"-"+keys[0]+"-rest", optionalChain(keys[1:], false))
+		}
+
+		return res
+	}
+
+	var b strings.Builder
+	b.WriteString(`"{" space `)
+
+	for i, key := range requiredKeys {
+		if i > 0 {
+			b.WriteString(` "," space `)
+		}
+		b.WriteString(kvRules[key])
+	}
+
+	if len(optionalKeys) > 0 {
+		alts := make([]string, len(optionalKeys))
+		for i := range optionalKeys {
+			alts[i] = optionalChain(optionalKeys[i:], true)
+		}
+
+		b.WriteString(" ( ")
+		if len(requiredKeys) > 0 {
+			b.WriteString(`"," space `)
+		}
+		b.WriteString("( " + strings.Join(alts, " | ") + " )")
+		b.WriteString(" )?")
+	}
+
+	b.WriteString(` "}" space`)
+
+	return b.String(), nil
+}
+
+func (c *schemaConverter) visitArray(s map[string]any, name string) (string, error) {
+	if prefix, ok := s["prefixItems"].([]any); ok {
+		items := make([]string, len(prefix))
+		for i, item := range prefix {
+			rule, err := c.visit(item, fmt.Sprintf("%s-tuple-%d", name, i))
+			if err != nil {
+				return "", err
+			}
+			items[i] = rule
+		}
+
+		c.primitive("space")
+
+		return `"[" space ` + strings.Join(items, ` "," space `) + ` "]" space`, nil
+	}
+
+	itemRule := c.primitive("value")
+	if items, exists := s["items"]; exists {
+		var err error
+		itemRule, err = c.visit(items, name+"-item")
+		if err != nil {
+			return "", err
+		}
+	}
+
+	c.primitive("space")
+
+	minItems, maxItems := max(schemaInt(s, "minItems"), 0), schemaInt(s, "maxItems")
+
+	return `"[" space ` + repetition(itemRule, minItems, maxItems, `"," space`) + ` "]" space`, nil
+}
+
+func (c *schemaConverter) visitRef(ref string) (string, error) {
+	if name, exists := c.refs[ref]; exists {
+		return name, nil
+	}
+
+	target, err := c.resolve(ref)
+	if err != nil {
+		return "", err
+	}
+
+	// Reserve the rule name before visiting so recursive schemas can
+	// reference themselves.
+	name := "ref-root"
+	if ref != "#" {
+		name = invalidRuleChars.ReplaceAllString("ref-"+ref[strings.LastIndex(ref, "/")+1:], "-")
+	}
+	for i := 1; ; i++ {
+		if _, exists := c.rules[name]; !exists {
+			break
+		}
+		name = fmt.Sprintf("%s%d", name, i)
+	}
+	c.refs[ref] = name
+	c.rules[name] = ""
+	c.order = append(c.order, name)
+
+	body, err := c.visitBody(target, name)
+	if err != nil {
+		return "", err
+	}
+	c.rules[name] = body
+
+	return name, nil
+}
+
+func (c *schemaConverter) mergeAllOf(s map[string]any, v any, name string) (map[string]any, error) {
+	subs, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("merge-all-of: schema[%s]: allOf must be an array", name)
+	}
+
+	merged := make(map[string]any)
+	props := make(map[string]any)
+	var required []any
+
+	add := func(sub map[string]any) {
+		for k, v := range sub {
+			switch k {
+			case "allOf":
+			case "properties":
+				if p, ok := v.(map[string]any); ok {
+					for pk, pv := range p {
+						props[pk] = pv
+					}
+				}
+			case "required":
+				if r, ok := v.([]any); ok {
+					required = append(required, r...)
+				}
+			default:
+				merged[k] = v
+			}
+		}
+	}
+
+	add(s)
+
+	for _, sub := range subs {
+		m, ok := sub.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("merge-all-of: schema[%s]: allOf entries must be objects", name)
+		}
+
+		if ref, ok := m["$ref"].(string); ok {
+			resolved, err := c.resolve(ref)
+			if err != nil {
+				return nil, err
+			}
+			m = resolved
+		}
+
+		add(m)
+	}
+
+	if len(props) > 0 {
+		merged["properties"] = props
+	}
+
+	if len(required) > 0 {
+		merged["required"] = required
+	}
+
+	return merged, nil
+}
+
+// resolve returns the schema a local reference like #/$defs/name points to.
+func (c *schemaConverter) resolve(ref string) (map[string]any, error) {
+	if ref != "#" && !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("resolve: ref[%s]: only local references are supported", ref)
+	}
+
+	target := c.root
+	if ref != "#" {
+		for part := range strings.SplitSeq(strings.TrimPrefix(ref, "#/"), "/") {
+			part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+
+			m, ok := target.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("resolve: ref[%s]: can't be resolved", ref)
+			}
+
+			if target, ok = m[part]; !ok {
+				return nil, fmt.Errorf("resolve: ref[%s]: can't be resolved", ref)
+			}
+		}
+	}
+
+	m, ok := target.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("resolve: ref[%s]: target is not a schema", ref)
+	}
+
+	return m, nil
+}
+
+// =============================================================================
+
+// normalizeSchema round trips the schema through JSON so nested values are
+// plain maps and slices no matter if they came from D, []D or a decoder.
+func normalizeSchema(schema any) (any, error) {
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return nil, fmt.Errorf("normalize-schema: marshal: %w", err)
+	}
+
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("normalize-schema: unmarshal: %w", err)
+	}
+
+	return v, nil
+}
+
+// jsonLiteral returns a GBNF string literal matching v encoded as JSON. HTML
+// characters are left as they are since the model writes them that way.
+func jsonLiteral(v any) string {
+	var b strings.Builder
+
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+
+	return gbnfLiteral(strings.TrimSuffix(b.String(), "\n"))
+}
+
+// gbnfLiteral quotes s as a GBNF string literal.
+func gbnfLiteral(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// repetition builds an expression matching item between min and max times
+// separated by sep. A max below zero means there is no upper bound.
+func repetition(item string, minN int, maxN int, sep string) string {
+	if maxN == 0 {
+		return ""
+	}
+
+	if sep == "" {
+		switch {
+		case maxN < 0:
+			return fmt.Sprintf("%s{%d,}", item, minN)
+		case minN == maxN:
+			return fmt.Sprintf("%s{%d}", item, minN)
+		default:
+			return fmt.Sprintf("%s{%d,%d}", item, minN, maxN)
+		}
+	}
+
+	next := "( " + sep + " " + item + " )"
+
+	var rest string
+	switch {
+	case maxN < 0:
+		rest = fmt.Sprintf("%s{%d,}", next, max(minN-1, 0))
+	case minN == maxN:
+		rest = fmt.Sprintf("%s{%d}", next, minN-1)
+	default:
+		rest = fmt.Sprintf("%s{%d,%d}", next, max(minN-1, 0), maxN-1)
+	}
+
+	if minN == 0 {
+		return "( " + item + " " + rest + " )?"
+	}
+
+	return item + " " + rest
+}
+
+// schemaInt returns the integer value of key or -1 if it isn't set.
+func schemaInt(s map[string]any, key string) int {
+	v, ok := s[key].(float64)
+	if !ok {
+		return -1
+	}
+
+	return int(v)
+}
@@ -0,0 +1,149 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_JSONSchemaRules(t *testing.T) {
+	schema := D{
+		"type": "object",
+		"properties": D{
+			"name": D{"type": "string"},
+			"age":  D{"type": "integer"},
+			"kind": D{"enum": []any{"a", "b"}},
+			"tags": D{"type": "array", "items": D{"type": "string"}, "maxItems": 3},
+		},
+		"required": []any{"name"},
+	}
+
+	rules, start, err := jsonSchemaRules(schema)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if start != "json" {
+		t.Errorf("expected start rule json, got %s", start)
+	}
+
+	want := []string{
+		`json ::= "{" space json-name-kv ( "," space ( json-age-kv json-age-rest | json-kind-kv json-kind-rest | json-tags-kv ) )? "}" space`,
+		`json-name-kv ::= "\"name\"" space ":" space string`,
+		`json-kind ::= ("\"a\"" | "\"b\"") space`,
+		`json-tags ::= "[" space ( string ( "," space string ){0,2} )? "]" space`,
+		`integer ::= ("-"? integral-part) space`,
+	}
+
+	for _, w := range want {
+		if !strings.Contains(rules, w+"\n") {
+			t.Errorf("expected rule %q in grammar:\n%s", w, rules)
+		}
+	}
+}
+
+func Test_JSONSchemaRulesRefs(t *testing.T) {
+	schema := D{
+		"$defs": D{
+			"node": D{
+				"type": "object",
+				"properties": D{
+					"children": D{"type": "array", "items": D{"$ref": "#/$defs/node"}},
+				},
+				"required": []any{"children"},
+			},
+		},
+		"$ref": "#/$defs/node",
+	}
+
+	rules, start, err := jsonSchemaRules(schema)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if !strings.Contains(rules, "ref-node ::= ") {
+		t.Errorf("expected ref-node rule in grammar:\n%s", rules)
+	}
+
+	if !strings.Contains(rules, start+" ::= ref-node\n") {
+		t.Errorf("expected %s to start from ref-node in grammar:\n%s", start, rules)
+	}
+}
+
+func Test_JSONSchemaRulesErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema any
+	}{
+		{"remote-ref", D{"$ref": "https://example.com/schema.json"}},
+		{"missing-ref", D{"$ref": "#/$defs/missing"}},
+		{"bad-type", D{"type": "date"}},
+		{"empty-enum", D{"enum": []any{}}},
+		{"false-schema", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := jsonSchemaRules(tt.schema); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func Test_Repetition(t *testing.T) {
+	tests := []struct {
+		name string
+		min  int
+		max  int
+		sep  string
+		want string
+	}{
+		{"unbounded", 0, -1, `","`, `( x ( "," x ){0,} )?`},
+		{"at-least-two", 2, -1, `","`, `x ( "," x ){1,}`},
+		{"exact", 3, 3, `","`, `x ( "," x ){2}`},
+		{"range", 1, 4, `","`, `x ( "," x ){0,3}`},
+		{"no-sep", 2, 5, "", `x{2,5}`},
+		{"none", 0, 0, `","`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := repetition("x", tt.min, tt.max, tt.sep); got != tt.want {
+				t.Errorf("repetition() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_JSONLiteral(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+		want string
+	}{
+		{"html", "a<b>&c", `"\"a<b>&c\""`},
+		{"quote", `say "hi"`, `"\"say \\\"hi\\\"\""`},
+		{"number", 42, `"42"`},
+		{"null", nil, `"null"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jsonLiteral(tt.v); got != tt.want {
+				t.Errorf("jsonLiteral(%v) = %s, want %s", tt.v, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("enum", func(t *testing.T) {
+		rules, _, err := jsonSchemaRules(D{"enum": []any{"a<b", "c&d"}})
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		want := `("\"a<b\"" | "\"c&d\"")`
+		if !strings.Contains(rules, want) {
+			t.Errorf("expected %s in grammar:\n%s", want, rules)
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package model
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ardanlabs/kronk/sdk/kronk/observ/metrics"
+	"github.com/hybridgroup/yzma/pkg/llama"
+)
+
+// defMaxDecodeFailures is the default number of consecutive decode failures
+// before a model is marked unhealthy.
+const defMaxDecodeFailures = 3
+
+// Healthy returns false once the batch engine failed to decode too many
+// batches in a row. An unhealthy model should be unloaded and loaded again.
+func (m *Model) Healthy() bool {
+	return !m.unhealthy.Load()
+}
+
+func (m *Model) markUnhealthy(ctx context.Context, failures int) {
+	if !m.unhealthy.CompareAndSwap(false, true) {
+		return
+	}
+
+	metrics.AddUnhealthyModels(1)
+
+	m.log(ctx, "model", "status", "unhealthy", "model", m.modelInfo.ID, "decode-failures", failures)
+}
+
+// =============================================================================
+
+// recoverDecode handles a batch that failed to decode. The state of the slots
+// with tokens in the batch is unknown, so they are failed along with the
+// slots holding media that can't be decoded again. The KV memory is cleared
+// so the engine continues from a clean state, and the slots that had nothing
+// in the batch start their prefill again. The draft model forgets the
+// sequences too since its cache no longer matches the slots. The model is
+// marked unhealthy after MaxDecodeFailures failures in a row.
+func (e *batchEngine) recoverDecode(ctx context.Context, ret int32, err error) {
+	e.model.log(ctx, "batch-engine", "status", "decode-error", "ret", ret, "err", err)
+
+	metrics.AddDecodeErrors()
+
+	if err == nil {
+		err = fmt.Errorf("decode returned %d", ret)
+	}
+	err = fmt.Errorf("recover-decode: unable to decode batch: %w", err)
+
+	for _, s := range e.slots {
+		if s.active && (s.inBatch || s.media) {
+			e.finishSlot(s, err)
+		}
+	}
+
+	llama.MemoryClear(e.model.mem, true)
+
+	for _, s := range e.slots {
+		s.cachedTokens = s.cachedTokens[:0]
+
+		if d := e.model.draft; d != nil {
+			d.forget(llama.SeqId(s.id + 1))
+		}
+
+		if s.active {
+			s.nPrefilled = 0
+			s.nPast = 0
+			if !s.resuming {
+				s.nCached = 0
+			}
+		}
+	}
+
+	e.countDecodeFailure(ctx)
+}
+
+// countDecodeFailure counts a batch that failed to decode and marks the model
+// unhealthy after MaxDecodeFailures failures in a row.
+func (e *batchEngine) countDecodeFailure(ctx context.Context) {
+	e.decodeFailures++
+	if e.decodeFailures >= e.model.cfg.MaxDecodeFailures {
+		e.model.markUnhealthy(ctx, e.decodeFailures)
+	}
+}
@@ -0,0 +1,45 @@
+package model
+
+import (
+	"context"
+	"testing"
+)
+
+func Test_CountDecodeFailure(t *testing.T) {
+	ctx := context.Background()
+
+	m := Model{
+		cfg: Config{MaxDecodeFailures: 3},
+		log: func(context.Context, string, ...any) {},
+	}
+
+	e := batchEngine{model: &m}
+
+	for i := range 2 {
+		e.countDecodeFailure(ctx)
+		if !m.Healthy() {
+			t.Fatalf("model unhealthy after %d decode failures, want healthy", i+1)
+		}
+	}
+
+	// A successful decode starts the count again.
+	e.decodeFailures = 0
+
+	for range 2 {
+		e.countDecodeFailure(ctx)
+	}
+	if !m.Healthy() {
+		t.Fatal("model unhealthy after the count started again, want healthy")
+	}
+
+	e.countDecodeFailure(ctx)
+	if m.Healthy() {
+		t.Fatal("model healthy after 3 decode failures in a row, want unhealthy")
+	}
+
+	// More failures keep the model unhealthy.
+	e.countDecodeFailure(ctx)
+	if m.Healthy() {
+		t.Fatal("model healthy after 4 decode failures in a row, want unhealthy")
+	}
+}
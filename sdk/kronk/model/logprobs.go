@@ -0,0 +1,150 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/hybridgroup/yzma/pkg/llama"
+)
+
+// maxTopLogprobs is the largest value accepted for top_logprobs.
+const maxTopLogprobs = 20
+
+// TopLogprob represents one of the most likely tokens at a position in the
+// output.
+type TopLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float32 `json:"logprob"`
+	Bytes   []int   `json:"bytes"`
+}
+
+// ContentLogprob represents the log probability of a generated token along
+// with the most likely tokens at that position when top_logprobs is set.
+type ContentLogprob struct {
+	Token       string       `json:"token"`
+	Logprob     float32      `json:"logprob"`
+	Bytes       []int        `json:"bytes"`
+	TopLogprobs []TopLogprob `json:"top_logprobs"`
+}
+
+// Logprobs provides the log probability information for a choice.
+type Logprobs struct {
+	Content []ContentLogprob `json:"content"`
+}
+
+// tokenLogprob computes the log probability of the sampled token from the
+// raw logits at idx in the last decoded batch. An idx of -1 selects the
+// last output.
+func (m *Model) tokenLogprob(lctx llama.Context, idx int32, token llama.Token, topN int) (ContentLogprob, error) {
+	nVocab := int(llama.VocabNTokens(m.vocab))
+
+	logits, err := llama.GetLogitsIth(lctx, idx, nVocab)
+	if err != nil {
+		return ContentLogprob{}, fmt.Errorf("token-logprob: get-logits: %w", err)
+	}
+
+	if logits == nil {
+		return ContentLogprob{}, fmt.Errorf("token-logprob: no logits for batch index %d", idx)
+	}
+
+	logprob, top := logprobsFromLogits(logits, int(token), topN)
+
+	piece := m.tokenPiece(token)
+	clp := ContentLogprob{
+		Token:       piece,
+		Logprob:     logprob,
+		Bytes:       pieceBytes(piece),
+		TopLogprobs: make([]TopLogprob, len(top)),
+	}
+
+	for i, t := range top {
+		piece := m.tokenPiece(llama.Token(t.token))
+		clp.TopLogprobs[i] = TopLogprob{
+			Token:   piece,
+			Logprob: t.logprob,
+			Bytes:   pieceBytes(piece),
+		}
+	}
+
+	return clp, nil
+}
+
+func (m *Model) tokenPiece(token llama.Token) string {
+	buf := make([]byte, 256)
+	l := llama.TokenToPiece(m.vocab, token, buf, 0, true)
+
+	return string(buf[:l])
+}
+
+// =============================================================================
+
+type tokenProb struct {
+	token   int
+	logprob float32
+}
+
+// logprobsFromLogits applies a log softmax over the logits and returns the
+// log probability of token along with the topN most likely tokens in
+// descending order.
+func logprobsFromLogits(logits []float32, token int, topN int) (float32, []tokenProb) {
+	maxLogit := float32(math.Inf(-1))
+	for _, l := range logits {
+		maxLogit = max(maxLogit, l)
+	}
+
+	var sum float64
+	for _, l := range logits {
+		sum += math.Exp(float64(l - maxLogit))
+	}
+
+	logSumExp := float64(maxLogit) + math.Log(sum)
+
+	var logprob float32
+	if token >= 0 && token < len(logits) {
+		logprob = float32(float64(logits[token]) - logSumExp)
+	}
+
+	if topN <= 0 {
+		return logprob, nil
+	}
+
+	// Keep the topN candidates sorted in descending order while scanning the
+	// vocab once, since topN is small compared to the size of the vocab.
+	top := make([]tokenProb, 0, topN+1)
+	for i, l := range logits {
+		if len(top) == topN && l <= top[len(top)-1].logprob {
+			continue
+		}
+
+		at, _ := slices.BinarySearchFunc(top, l, func(tp tokenProb, l float32) int {
+			switch {
+			case tp.logprob > l:
+				return -1
+			case tp.logprob < l:
+				return 1
+			}
+			return 0
+		})
+
+		top = slices.Insert(top, at, tokenProb{token: i, logprob: l})
+		if len(top) > topN {
+			top = top[:topN]
+		}
+	}
+
+	for i := range top {
+		top[i].logprob = float32(float64(top[i].logprob) - logSumExp)
+	}
+
+	return logprob, top
+}
+
+func pieceBytes(piece string) []int {
+	b := make([]int, len(piece))
+	for i := range len(piece) {
+		b[i] = int(piece[i])
+	}
+
+	return b
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"math"
+	"testing"
+)
+
+func Test_LogprobsFromLogits(t *testing.T) {
+	logits := []float32{1, 3, 2, 0}
+
+	logprob, top := logprobsFromLogits(logits, 2, 3)
+
+	var sum float64
+	for _, l := range logits {
+		sum += math.Exp(float64(l))
+	}
+
+	want := float32(2 - math.Log(sum))
+	if math.Abs(float64(logprob-want)) > 1e-5 {
+		t.Errorf("expected logprob %f, got %f", want, logprob)
+	}
+
+	wantTokens := []int{1, 2, 0}
+	if len(top) != len(wantTokens) {
+		t.Fatalf("expected %d top tokens, got %d", len(wantTokens), len(top))
+	}
+
+	for i, tp := range top {
+		if tp.token != wantTokens[i] {
+			t.Errorf("top[%d]: expected token %d, got %d", i, wantTokens[i], tp.token)
+		}
+	}
+
+	if top[1].logprob != logprob {
+		t.Errorf("expected top logprob %f to match sampled token logprob %f", top[1].logprob, logprob)
+	}
+
+	if _, top := logprobsFromLogits(logits, 1, 0); top != nil {
+		t.Errorf("expected no top tokens, got %d", len(top))
+	}
+}
@@ -3,18 +3,16 @@ package model
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"io"
 	"path"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/ardanlabs/kronk/sdk/kronk/observ/metrics"
 	"github.com/ardanlabs/kronk/sdk/kronk/observ/otel"
 	"github.com/hybridgroup/yzma/pkg/llama"
-	"github.com/hybridgroup/yzma/pkg/mtmd"
 	"go.opentelemetry.io/otel/attribute"
 )
 
@@ -28,16 +26,23 @@ type Model struct {
 	cfg           Config
 	log           Logger
 	model         llama.Model
+	weights       *weights
 	vocab         llama.Vocab
 	ctxParams     llama.ContextParams
 	lctx          llama.Context
 	mem           llama.Memory
 	batch         *batchEngine
+	draft         *draftModel
 	template      Template
+	toolFormat    ToolCallFormat
 	projFile      string
 	modelInfo     ModelInfo
+	fingerprint   string
+	reasoningEnd  reasoningEnd
+	pooledMu      sync.Mutex
 	activeStreams atomic.Int32
 	unloaded      atomic.Bool
+	unhealthy     atomic.Bool
 }
 
 func NewModel(ctx context.Context, tmplRetriever TemplateRetriever, cfg Config) (*Model, error) {
@@ -108,39 +113,133 @@ func NewModel(ctx context.Context, tmplRetriever TemplateRetriever, cfg Config)
 
 	// -------------------------------------------------------------------------
 
+	modelType := cfg.ModelType
+	if modelType == ModelTypeAuto {
+		modelType = detectModelType(modelInfo.Metadata, llama.ModelGetVocab(mdl), template.Script)
+	}
+
+	var vision, audio bool
+	if cfg.ProjFile != "" {
+		vision, audio, err = projectionSupport(cfg.ProjFile, mdl)
+		if err != nil {
+			llama.ModelFree(mdl)
+			return nil, fmt.Errorf("projection-support: unable to check projection: %w", err)
+		}
+	}
+
+	modelInfo = applyModelType(modelInfo, modelType, template.Script, vision, audio)
+
+	l(ctx, "model-type", "type", modelType, "detected", cfg.ModelType == ModelTypeAuto, "capabilities", fmt.Sprintf("%+v", modelInfo.Capabilities))
+
+	// The harmony format of gpt-oss models has its own tool calls.
+	if modelType == ModelTypeChat {
+		toolFormat, ok := LookupToolCallFormat(cfg.ToolCallFormat)
+		if !ok {
+			toolFormat = detectToolCallFormat(llama.ModelGetVocab(mdl), template.Script)
+		}
+
+		modelInfo.ToolCallFormat = toolFormat.Name
+
+		l(ctx, "tool-call-format", "format", toolFormat.Name, "detected", !ok)
+	}
+
+	// -------------------------------------------------------------------------
+
+	w := weights{
+		model:   mdl,
+		mParams: mParams,
+	}
+
+	// Speculative decoding needs to remove rejected draft tokens from the
+	// KV cache, which recurrent memory doesn't support.
+	if len(cfg.DraftModelFiles) > 0 && modelType != ModelTypeEmbed && modelType != ModelTypeRerank {
+		switch {
+		case llama.ModelIsRecurrent(mdl) || llama.ModelIsHybrid(mdl):
+			l(ctx, "draft-model", "status", "ignored", "reason", "speculative decoding is not supported by recurrent models")
+
+		default:
+			w.draft, err = loadDraftModel(ctx, l, cfg, mdl, mParams)
+			if err != nil {
+				llama.ModelFree(mdl)
+				return nil, fmt.Errorf("load-draft-model: unable to load draft model: %w", err)
+			}
+		}
+	}
+
+	return newInstance(ctx, l, cfg, &w, modelInfo, template)
+}
+
+// NewInstance creates another instance of the model that shares the loaded
+// weights. The instance has its own context, so it only adds the memory of its
+// KV cache and compute buffers. The weights are freed when the last instance
+// sharing them is unloaded.
+func (m *Model) NewInstance(ctx context.Context) (*Model, error) {
+	if m.unloaded.Load() {
+		return nil, fmt.Errorf("new-instance: model is unloaded")
+	}
+
+	return newInstance(ctx, m.log, m.cfg, m.weights, m.modelInfo, m.template)
+}
+
+func newInstance(ctx context.Context, l Logger, cfg Config, w *weights, modelInfo ModelInfo, template Template) (*Model, error) {
+	w.acquire()
+
+	mdl := w.model
 	ctxParams := modelCtxParams(cfg, modelInfo)
 
 	l(ctx, "context-params", "NCtx", ctxParams.NCtx, "NBatch", ctxParams.NBatch, "NUBatch", ctxParams.NUbatch, "NSeqMax", ctxParams.NSeqMax, "TypeK", ctxParams.TypeK, "TypeV", ctxParams.TypeV, "NThreads", ctxParams.NThreads, "NThreadsBatch", ctxParams.NThreadsBatch)
 
 	lctx, err := llama.InitFromModel(mdl, ctxParams)
 	if err != nil {
-		llama.ModelFree(mdl)
+		w.release()
 		return nil, fmt.Errorf("init-from-model: unable to init context: %w", err)
 	}
 
 	mem, err := llama.GetMemory(lctx)
 	if err != nil {
 		llama.Free(lctx)
-		llama.ModelFree(mdl)
+		w.release()
 		return nil, fmt.Errorf("get-memory: unable to get memory: %w", err)
 	}
 
+	// The draft weights are shared, each instance has its own draft context.
+	var draft *draftModel
+	if w.draft != 0 {
+		draft, err = newDraftModel(cfg, w)
+		if err != nil {
+			llama.Free(lctx)
+			w.release()
+			return nil, fmt.Errorf("new-draft-model: unable to create draft context: %w", err)
+		}
+
+		l(ctx, "draft-model", "status", "loaded", "DraftMax", cfg.DraftMax, "DraftMin", cfg.DraftMin)
+	}
+
+	toolFormat, _ := LookupToolCallFormat(modelInfo.ToolCallFormat)
+
 	m := Model{
-		cfg:       cfg,
-		log:       l,
-		model:     mdl,
-		vocab:     llama.ModelGetVocab(mdl),
-		ctxParams: ctxParams,
-		lctx:      lctx,
-		mem:       mem,
-		template:  template,
-		projFile:  cfg.ProjFile,
-		modelInfo: modelInfo,
-	}
-
-	// Initialize batch engine for text-only models (no ProjFile).
-	// Batching is faster even for single-sequence inference.
-	if cfg.ProjFile == "" {
+		cfg:         cfg,
+		log:         l,
+		model:       mdl,
+		weights:     w,
+		vocab:       llama.ModelGetVocab(mdl),
+		ctxParams:   ctxParams,
+		lctx:        lctx,
+		mem:         mem,
+		template:    template,
+		toolFormat:  toolFormat,
+		projFile:    cfg.ProjFile,
+		modelInfo:   modelInfo,
+		fingerprint: systemFingerprint(cfg.ModelFiles),
+		draft:       draft,
+	}
+
+	// Initialize batch engine for text, vision and audio models.
+	// Batching is faster even for single-sequence inference. Embedding
+	// and rerank models use the context directly for their calls.
+	if !modelInfo.IsEmbedModel && !modelInfo.IsRerankModel {
+		m.reasoningEnd = m.newReasoningEnd(ctx)
+
 		nSlots := max(cfg.NSeqMax, 1)
 		m.batch = newBatchEngine(&m, nSlots)
 		m.batch.start(ctx)
@@ -254,453 +353,32 @@ func (m *Model) Unload(ctx context.Context) error {
 	// Synchronize ensures all GPU operations complete before freeing.
 	llama.Synchronize(m.lctx)
 	llama.Free(m.lctx)
-	llama.ModelFree(m.model)
-	llama.BackendFree()
-
-	return nil
-}
-
-func (m *Model) Config() Config {
-	return m.cfg
-}
-
-func (m *Model) ModelInfo() ModelInfo {
-	return m.modelInfo
-}
-
-func (m *Model) resetContext() {
-	llama.Synchronize(m.lctx)
-
-	mem, err := llama.GetMemory(m.lctx)
-	if err == nil {
-		llama.MemoryClear(mem, true)
-	}
-}
-
-func (m *Model) sequentialChatRequest(ctx context.Context, id string, lctx llama.Context, mtmdCtx mtmd.Context, object string, prompt string, media [][]byte, params params, ch chan<- ChatResponse) {
-	m.log(ctx, "process-chat-request", "status", "started", "id", id, "object", object)
-	defer m.log(ctx, "process-chat-request", "status", "completed", "id", id, "object", object)
-
-	ctx, span := otel.AddSpan(ctx, "process-chat-request",
-		attribute.String("id", id),
-		attribute.String("object", object),
-	)
-	defer span.End()
-
-	// These are for token counting.
-	var (
-		inputTokens      int
-		completionTokens int
-		reasonTokens     int
-		tokensPerSecond  float64
-	)
-
-	// These flags track what mode the model is operating in.
-	var (
-		reasonFlag     int
-		completionFlag int
-		toolFlag       int
-	)
-
-	// These builders contain the final content for each of these items.
-	var (
-		finalReasoning strings.Builder
-		finalContent   strings.Builder
-		finalTooling   strings.Builder
-	)
-
-	// The buffer is used to process tokens.
-	const bufferSize = 32 * 1024
-	buf := make([]byte, bufferSize)
-
-	// -------------------------------------------------------------------------
-
-	m.log(ctx, "process-chat-request", "status", "process input tokens")
-
-	// Start timing for time-to-first-token metric.
-	ttftStart := time.Now()
-
-	// Process the prompt and get the first batch for the response.
-	sampler, batch, inputTokens, outputTokens, bitmaps := m.processInputTokens(ctx, lctx, mtmdCtx, object, prompt, media, params)
-	defer llama.SamplerFree(sampler)
-	defer func() {
-		for _, b := range bitmaps {
-			mtmd.BitmapFree(b)
-		}
-	}()
-
-	// Check that we have not exceeded the context window.
-	if inputTokens > m.cfg.ContextWindow {
-		returnPrompt := ""
-		if params.ReturnPrompt {
-			returnPrompt = prompt
-		}
-
-		err := fmt.Errorf("input tokens %d exceed context window %d", inputTokens, m.cfg.ContextWindow)
-		m.sendErrorResponse(ctx, ch, id, object, 1, returnPrompt, err, Usage{
-			PromptTokens:     inputTokens,
-			ReasoningTokens:  reasonTokens,
-			CompletionTokens: completionTokens,
-			OutputTokens:     outputTokens,
-			TotalTokens:      inputTokens + outputTokens,
-		})
-		return
-	}
-
-	// -------------------------------------------------------------------------
-
-	m.log(ctx, "process-chat-request", "status", "start processing loop")
-
-	// Capture the time we start processing the request for a wall clock.
-	start := time.Now()
-
-	// We need to know if we are processing a standard or GPT model.
-	isGTP := m.modelInfo.IsGPTModel
-
-	// Create a processor to process the tokens.
-	processor := newProcessor(m)
-
-	// Track whether this is the first iteration. After prefill, the logits are
-	// already computed so we sample directly without re-decoding the prompt.
-	firstIteration := true
-
-loop:
-	for outputTokens <= params.MaxTokens {
-		var err error
-		var token llama.Token
-		var resp response
-
-		// ---------------------------------------------------------------------
-
-		// Process a set of tokens based on the model class.
-		switch {
-		case firstIteration && isGTP:
-			resp, token, err = processor.gptFirst(lctx, sampler, buf)
-			firstIteration = false
-
-			since := time.Since(ttftStart)
-			metrics.AddTimeToFirstToken(since)
-			span.SetAttributes(
-				attribute.String("ttft", since.String()),
-			)
-
-		case firstIteration:
-			resp, token, err = processor.standardFirst(lctx, sampler, buf)
-			firstIteration = false
-
-			since := time.Since(ttftStart)
-			metrics.AddTimeToFirstToken(since)
-			span.SetAttributes(
-				attribute.String("ttft", since.String()),
-			)
-
-		case isGTP:
-			resp, token, err = processor.gpt(lctx, batch, sampler, buf)
-
-		default:
-			resp, token, err = processor.standard(lctx, batch, sampler, buf)
-		}
-
-		// Did we get an error or are we at the end of the token stream.
-		if err != nil {
-			if errors.Is(err, io.EOF) {
-				break loop
-			}
-
-			returnPrompt := ""
-			if params.ReturnPrompt {
-				returnPrompt = prompt
-			}
-
-			m.sendErrorResponse(ctx, ch, id, object, 0, returnPrompt, err, Usage{
-				PromptTokens:     inputTokens,
-				ReasoningTokens:  reasonTokens,
-				CompletionTokens: completionTokens,
-				OutputTokens:     outputTokens,
-				TotalTokens:      inputTokens + outputTokens,
-			})
-			return
-		}
-
-		// ---------------------------------------------------------------------
-
-		// Set the flags so we know how to process the response.
-		switch resp.status {
-		case statusReasoning:
-			reasonFlag++
-			completionFlag = 0
-			toolFlag = 0
-
-		case statusCompletion:
-			completionFlag++
-			reasonFlag = 0
-			toolFlag = 0
-
-		case statusTooling:
-			toolFlag++
-			reasonFlag = 0
-			completionFlag = 0
-
-		default:
-			batch = m.nextBatch(token)
-			continue
-		}
-
-		// ---------------------------------------------------------------------
-
-		// Capture the time it took to process these tokens and calculate
-		// the tokens per second.
-
-		elapsedSeconds := time.Since(start).Seconds()
-		tokensPerSecond = float64(outputTokens) / elapsedSeconds
-
-		// ---------------------------------------------------------------------
-
-		// Do this if we are not processing tooling tokens.
-		if toolFlag == 0 {
-			// At the start or end of a mode we might have an extra CRLF we don't need.
-			if m.isUnncessaryCRLF(reasonFlag, completionFlag, resp.content) {
-				batch = m.nextBatch(token)
-				continue
-			}
-
-			// We have reasoning or completion content to return to the client.
-			err = m.sendDeltaResponse(ctx, ch, id, object, 0, prompt, resp.content, reasonFlag,
-				Usage{
-					PromptTokens:     inputTokens,
-					ReasoningTokens:  reasonTokens,
-					CompletionTokens: completionTokens,
-					OutputTokens:     outputTokens,
-					TotalTokens:      inputTokens + outputTokens,
-					TokensPerSecond:  tokensPerSecond,
-				},
-			)
-
-			if err != nil {
-				return
-			}
-		}
-
-		// ---------------------------------------------------------------------
-
-		// Store content for the final response.
-		switch {
-		case reasonFlag > 0:
-			finalReasoning.WriteString(resp.content)
-
-		case toolFlag > 0:
-			finalTooling.WriteString(resp.content)
-
-		default:
-			finalContent.WriteString(resp.content)
-		}
-
-		// ---------------------------------------------------------------------
-
-		// Get the next batch to process the next piece of content.
-		batch = m.nextBatch(token)
-
-		// ---------------------------------------------------------------------
-
-		// Calculate token counts.
-		switch {
-		case reasonFlag > 0:
-			reasonTokens += int(batch.NTokens)
-
-		default:
-			completionTokens += int(batch.NTokens)
-		}
 
-		outputTokens = reasonTokens + completionTokens
+	// The draft context goes before the weights it was created from.
+	if m.draft != nil {
+		m.draft.free()
 	}
 
-	// -------------------------------------------------------------------------
-
-	// If a tool call was provided, count tokens and process the tool call
-	// response into the slice of ResponseToolCall.
-	var respToolCalls []ResponseToolCall
-	if toolFlag > 0 {
-		content := finalTooling.String()
-		content = strings.TrimSuffix(content, "\n")
-
-		if len(content) > 0 {
-			// We will count the tokens for the final JSON document
-			// as completion tokens that would have been returned
-			// if we didn't provide a structured response.
-			tokens := llama.Tokenize(m.vocab, content, true, true)
-			batch := llama.BatchGetOne(tokens)
-			completionTokens += int(batch.NTokens)
-			outputTokens = reasonTokens + completionTokens
-		}
-
-		switch isGTP {
-		case true:
-			respToolCalls = parseGPTToolCall(content)
-		default:
-			respToolCalls = parseToolCall(content)
-		}
-	}
-
-	// -------------------------------------------------------------------------
-
-	totalTokens := inputTokens + outputTokens
-
-	// Add all this data to metrics and otel.
-	span.SetAttributes(
-		attribute.Int("prompt_tokens", inputTokens),
-		attribute.Int("reasoning_tokens", reasonTokens),
-		attribute.Int("completion_tokens", completionTokens),
-		attribute.Int("output_tokens", outputTokens),
-		attribute.Int("total_tokens", totalTokens),
-		attribute.Float64("tokens_per_second", tokensPerSecond),
-	)
-
-	metrics.AddChatCompletionsUsage(inputTokens, reasonTokens, completionTokens, outputTokens, totalTokens, tokensPerSecond)
-
-	// -------------------------------------------------------------------------
-
-	// Send the final response that contains eveything we have sent plus
-	// the final usage numbers.
-	returnPrompt := ""
-	if params.ReturnPrompt {
-		returnPrompt = prompt
-	}
-
-	m.sendFinalResponse(ctx, ch, id, object, 0, returnPrompt, &finalContent, &finalReasoning, respToolCalls,
-		Usage{
-			PromptTokens:     inputTokens,
-			ReasoningTokens:  reasonTokens,
-			CompletionTokens: completionTokens,
-			OutputTokens:     outputTokens,
-			TotalTokens:      totalTokens,
-			TokensPerSecond:  tokensPerSecond,
-		},
-	)
-}
-
-// processInputTokens handles the prefill phase for both text and media requests.
-// It tokenizes the prompt, processes any media attachments, and prepares the
-// model's KV cache for autoregressive generation. Returns a sampler configured
-// with the request parameters, an initial batch for generation, token counts,
-// and any bitmaps that need to be freed by the caller.
-func (m *Model) processInputTokens(ctx context.Context, lctx llama.Context, mtmdCtx mtmd.Context, object string, prompt string, media [][]byte, params params) (llama.Sampler, llama.Batch, int, int, []mtmd.Bitmap) {
-	_, span := otel.AddSpan(ctx, "process-input-tokens")
-	defer span.End()
-
-	// Apply any parameters to this request like temperature or top_p.
-	sampler := m.toSampler(params)
-
-	// Tokenize the prompt to get the input token count.
-	tokens := llama.Tokenize(m.vocab, prompt, true, true)
-	inputTokens := len(tokens)
-
-	var batch llama.Batch
-	var outputTokens int
-	var bitmaps []mtmd.Bitmap
-
-	switch object {
-	case ObjectChatMedia:
-		// Convert raw media bytes into bitmap structures for the vision encoder.
-		bitmaps = make([]mtmd.Bitmap, len(media))
-		for i, med := range media {
-			if len(med) == 0 {
-				continue
-			}
-			bitmaps[i] = mtmd.BitmapInitFromBuf(mtmdCtx, &med[0], uint64(len(med)))
-		}
-
-		// Create input chunks that interleave text tokens with image embeddings.
-		output := mtmd.InputChunksInit()
-		defer mtmd.InputChunksFree(output)
+	m.weights.release()
 
-		// Tokenize produces a sequence of chunks: text tokens and image patches.
-		input := mtmd.NewInputText(prompt, true, true)
-		mtmd.Tokenize(mtmdCtx, output, input, bitmaps)
-
-		start := time.Now()
-
-		// Prefill: Process all chunks through the model, populating the KV cache.
-		// This handles both text token decoding and vision encoder forward passes.
-		var n llama.Pos
-		mtmd.HelperEvalChunks(mtmdCtx, lctx, output, 0, 0, int32(m.ctxParams.NBatch), true, &n)
-
-		since := time.Since(start)
-		metrics.AddPrefillMediaTime(since)
-		span.SetAttributes(
-			attribute.String("prefill-media", since.String()),
-		)
-
-		// Sample the first token to start autoregressive generation.
-		batch = m.nextBatch(llama.SamplerSample(sampler, lctx, -1))
-		outputTokens = int(batch.NTokens)
-
-	default:
-		// Text-only prefill phase:
-		// - BatchGetOne: Wraps tokens into a batch structure for the model.
-		// - Decode: Processes the batch, updating the model's internal KV cache
-		//           with attention states.
-		// - After prefill: Logits are ready for sampling in processChatRequest.
-
-		nBatch := int(m.ctxParams.NBatch)
-		start := time.Now()
-
-		switch {
-		case inputTokens <= nBatch:
-			// Small prompt: process in a single batch.
-			llama.Decode(lctx, llama.BatchGetOne(tokens))
-
-		default:
-			// Large prompt: chunk into multiple batches to avoid memory issues.
-			for i := 0; i < len(tokens); i += nBatch {
-				end := min(i+nBatch, len(tokens))
-				chunk := tokens[i:end]
-				llama.Decode(lctx, llama.BatchGetOne(chunk))
-			}
-		}
-
-		since := time.Since(start)
-		metrics.AddPrefillNonMediaTime(since)
-		span.SetAttributes(
-			attribute.String("prefill-nonmedia", since.String()),
-		)
+	if m.unhealthy.Load() {
+		metrics.AddUnhealthyModels(-1)
 	}
 
-	return sampler, batch, inputTokens, outputTokens, bitmaps
-}
+	llama.BackendFree()
 
-// nextBatch wraps a single sampled token into a batch for the next
-// autoregressive decode step. Creates a 1-token batch from the sampled
-// tokens which prepares us for next iteration.
-func (m *Model) nextBatch(token llama.Token) llama.Batch {
-	tokens := []llama.Token{token}
-	return llama.BatchGetOne(tokens)
+	return nil
 }
 
-// batchResponse decodes the current batch into the model context, samples the
-// next token, and returns its string representation. Returns io.EOF when an
-// end-of-generation token is sampled.
-func (m *Model) batchResponse(lctx llama.Context, batch llama.Batch, sampler llama.Sampler, buf []byte) (string, llama.Token, error) {
-	llama.Decode(lctx, batch)
-	return m.sampleToken(lctx, sampler, buf)
+func (m *Model) Config() Config {
+	return m.cfg
 }
 
-// sampleToken samples the next token from the current logits without decoding.
-// Use this after prefill when logits are already computed.
-func (m *Model) sampleToken(lctx llama.Context, sampler llama.Sampler, buf []byte) (string, llama.Token, error) {
-	token := llama.SamplerSample(sampler, lctx, -1)
-
-	if llama.VocabIsEOG(m.vocab, token) {
-		return "", 0, io.EOF
-	}
-
-	l := llama.TokenToPiece(m.vocab, token, buf, 0, true)
-
-	content := string(buf[:l])
-	if content == "" {
-		return "", 0, io.EOF
-	}
+func (m *Model) ModelInfo() ModelInfo {
+	mi := m.modelInfo
+	mi.Instances = m.weights.instances()
 
-	return content, token, nil
+	return mi
 }
 
 func (m *Model) isUnncessaryCRLF(reasonFlag int, completionFlag int, content string) bool {
@@ -717,11 +395,17 @@ func (m *Model) isUnncessaryCRLF(reasonFlag int, completionFlag int, content str
 	return false
 }
 
-func (m *Model) sendDeltaResponse(ctx context.Context, ch chan<- ChatResponse, id string, object string, choiceIndex int, prompt string, content string, reasonFlag int, usage Usage) error {
+func (m *Model) sendDeltaResponse(ctx context.Context, ch chan<- ChatResponse, id string, object string, choiceIndex int, prompt string, content string, reasonFlag int, logprobs []ContentLogprob, usage Usage) error {
 	if usage.OutputTokens%500 == 0 {
 		m.log(ctx, "chat-completion", "status", "delta", "id", id, "tokens", usage.OutputTokens, "object", object, "reasoning", reasonFlag, "content", len(content))
 	}
 
+	// The last place in the channel is kept for the response that ends the
+	// stream.
+	if len(ch) >= cap(ch)-1 {
+		return fmt.Errorf("send-delta-response: %w: responses[%d]", ErrSlowClient, len(ch))
+	}
+
 	select {
 	case <-ctx.Done():
 		select {
@@ -731,15 +415,39 @@ func (m *Model) sendDeltaResponse(ctx context.Context, ch chan<- ChatResponse, i
 
 		return ctx.Err()
 
-	case ch <- chatResponseDelta(id, object, m.modelInfo.ID, choiceIndex, content, reasonFlag > 0, usage):
+	case ch <- m.withFingerprint(chatResponseDelta(id, object, m.modelInfo.ID, choiceIndex, content, reasonFlag > 0, logprobs, usage)):
 	}
 
 	return nil
 }
 
-func (m *Model) sendFinalResponse(ctx context.Context, ch chan<- ChatResponse, id string, object string, choiceIndex int, prompt string, finalContent *strings.Builder, finalReasoning *strings.Builder, respToolCalls []ResponseToolCall, usage Usage) {
+func (m *Model) sendToolCallDeltaResponse(ctx context.Context, ch chan<- ChatResponse, id string, object string, choiceIndex int, deltas []ResponseToolCallDelta, usage Usage) error {
+	// The last place in the channel is kept for the response that ends the
+	// stream.
+	if len(ch) >= cap(ch)-1 {
+		return fmt.Errorf("send-tool-call-delta-response: %w: responses[%d]", ErrSlowClient, len(ch))
+	}
+
+	select {
+	case <-ctx.Done():
+		select {
+		case ch <- ChatResponseErr(id, object, m.modelInfo.ID, choiceIndex, "", ctx.Err(), usage):
+		default:
+		}
+
+		return ctx.Err()
+
+	case ch <- m.withFingerprint(chatResponseToolCallDelta(id, object, m.modelInfo.ID, choiceIndex, deltas, usage)):
+	}
+
+	return nil
+}
+
+func (m *Model) sendFinalResponse(ctx context.Context, ch chan<- ChatResponse, id string, object string, choiceIndex int, prompt string, finalContent *strings.Builder, finalReasoning *strings.Builder, respToolCalls []ResponseToolCall, logprobs []ContentLogprob, length bool, overflow ContextOverflow, usage Usage) {
 	m.log(ctx, "chat-completion", "status", "final", "id", id, "tokens", usage.OutputTokens, "object", object, "tooling", len(respToolCalls) > 0, "reasoning", finalReasoning.Len(), "content", finalContent.Len())
 
+	// The deltas leave the last place in the channel for this response, so
+	// it's only waited on when the caller isn't reading at all.
 	select {
 	case <-ctx.Done():
 		select {
@@ -747,11 +455,13 @@ func (m *Model) sendFinalResponse(ctx context.Context, ch chan<- ChatResponse, i
 		default:
 		}
 
-	case ch <- chatResponseFinal(id, object, m.modelInfo.ID, choiceIndex, prompt,
+	case ch <- withContextOverflow(m.withFingerprint(chatResponseFinal(id, object, m.modelInfo.ID, choiceIndex, prompt,
 		finalContent.String(),
 		finalReasoning.String(),
 		respToolCalls,
-		usage):
+		logprobs,
+		length,
+		usage)), overflow):
 	}
 
 	contextTokens := usage.PromptTokens + usage.CompletionTokens
@@ -763,16 +473,31 @@ func (m *Model) sendFinalResponse(ctx context.Context, ch chan<- ChatResponse, i
 		"context", contextTokens, "down", fmt.Sprintf("(%.0f%% of %.0fK) TPS: %.2f", percentage, of, usage.TokensPerSecond))
 }
 
+func (m *Model) withFingerprint(resp ChatResponse) ChatResponse {
+	resp.SystemFingerprint = m.fingerprint
+	return resp
+}
+
+func withContextOverflow(resp ChatResponse, overflow ContextOverflow) ChatResponse {
+	resp.ContextOverflow = overflow
+	return resp
+}
+
 func (m *Model) sendErrorResponse(ctx context.Context, ch chan<- ChatResponse, id string, object string, choiceIndex int, prompt string, err error, usage Usage) {
 	m.log(ctx, "chat-completion", "status", "ERROR", "msg", err, "id", id, "object", object)
 
-	select {
-	case <-ctx.Done():
-
-	case ch <- ChatResponseErr(id, object, m.modelInfo.ID, choiceIndex, prompt,
-		err,
-		usage):
+	resp := ChatResponseErr(id, object, m.modelInfo.ID, choiceIndex, prompt, err, usage)
 
+	// The deltas leave the last place in the channel for this response, so
+	// it's sent even when the client is gone.
+	select {
+	case ch <- resp:
+		return
 	default:
 	}
+
+	select {
+	case <-ctx.Done():
+	case ch <- resp:
+	}
 }
@@ -31,29 +31,35 @@ const (
 
 // FinishReasons represent the different reasons a response can be finished.
 const (
-	FinishReasonStop  = "stop"
-	FinishReasonTool  = "tool_calls"
-	FinishReasonError = "error"
+	FinishReasonStop   = "stop"
+	FinishReasonTool   = "tool_calls"
+	FinishReasonLength = "length"
+	FinishReasonError  = "error"
 )
 
 // =============================================================================
 
-// ModelInfo represents the model's card information.
+// ModelInfo represents the model's card information. Size is the size of the
+// model's tensors. The weights are loaded once and shared by the Instances of
+// the model, which each add their own KV cache and compute buffers.
 type ModelInfo struct {
-	ID            string
-	HasProjection bool
-	Desc          string
-	Size          uint64
-	HasEncoder    bool
-	HasDecoder    bool
-	IsRecurrent   bool
-	IsHybrid      bool
-	IsGPTModel    bool
-	IsEmbedModel  bool
-	IsRerankModel bool
-	Metadata      map[string]string
-	TemplateFile  string
-	Template      Template
+	ID             string
+	HasProjection  bool
+	Desc           string
+	Size           uint64
+	Instances      int
+	HasEncoder     bool
+	HasDecoder     bool
+	IsRecurrent    bool
+	IsHybrid       bool
+	IsGPTModel     bool
+	IsEmbedModel   bool
+	IsRerankModel  bool
+	Metadata       map[string]string
+	TemplateFile   string
+	Template       Template
+	Capabilities   Capabilities
+	ToolCallFormat string
 }
 
 func toModelInfo(cfg Config, model llama.Model) ModelInfo {
@@ -98,21 +104,6 @@ func toModelInfo(cfg Config, model llama.Model) ModelInfo {
 
 	modelID := strings.TrimSuffix(filename, path.Ext(filename))
 
-	var isGPTModel bool
-	if strings.Contains(modelID, "gpt") {
-		isGPTModel = true
-	}
-
-	var isEmbedModel bool
-	if strings.Contains(modelID, "embed") {
-		isEmbedModel = true
-	}
-
-	var isRerankModel bool
-	if strings.Contains(modelID, "rerank") {
-		isRerankModel = true
-	}
-
 	return ModelInfo{
 		ID:            modelID,
 		HasProjection: cfg.ProjFile != "",
@@ -122,9 +113,6 @@ func toModelInfo(cfg Config, model llama.Model) ModelInfo {
 		HasDecoder:    decoder,
 		IsRecurrent:   recurrent,
 		IsHybrid:      hybrid,
-		IsGPTModel:    isGPTModel,
-		IsEmbedModel:  isEmbedModel,
-		IsRerankModel: isRerankModel,
 		Metadata:      metadata,
 	}
 }
@@ -395,6 +383,18 @@ type ResponseToolCallFunction struct {
 	Arguments ToolCallArguments `json:"arguments"`
 }
 
+// Status values of a tool call. A call with a status other than
+// ToolCallStatusOK has the reason in Error and the text the model wrote in Raw.
+const (
+	ToolCallStatusOK        = 0
+	ToolCallStatusMissing   = 1
+	ToolCallStatusMalformed = 2
+	ToolCallStatusInvalid   = 3
+)
+
+// ResponseToolCall is a tool call made by the model. Calls to a tool of the
+// request are checked against the parameters schema of the tool, and calls
+// that don't match it have the ToolCallStatusInvalid status.
 type ResponseToolCall struct {
 	ID       string                   `json:"id"`
 	Index    int                      `json:"index"`
@@ -405,12 +405,50 @@ type ResponseToolCall struct {
 	Error    string                   `json:"error,omitempty"`
 }
 
-// ResponseMessage represents a single message in a response.
+// ResponseToolCallDeltaFunction is the part of a function call in a delta.
+type ResponseToolCallDeltaFunction struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
+}
+
+// ResponseToolCallDelta is a part of a tool call streamed while the model
+// writes it. The first delta of a call has the ID, Type and Name, the deltas
+// after it add to the Arguments. Index identifies the call the delta belongs
+// to and matches the Index of the tool call in the final response.
+type ResponseToolCallDelta struct {
+	Index    int                           `json:"index"`
+	ID       string                        `json:"id,omitempty"`
+	Type     string                        `json:"type,omitempty"`
+	Function ResponseToolCallDeltaFunction `json:"function"`
+}
+
+// ResponseMessage represents a single message in a response. ToolCallDeltas
+// are only set on streamed deltas and are sent as the tool_calls field, the
+// final response has the complete ToolCalls.
 type ResponseMessage struct {
-	Role      string             `json:"role,omitempty"`
-	Content   string             `json:"content,omitempty"`
-	Reasoning string             `json:"reasoning_content,omitempty"`
-	ToolCalls []ResponseToolCall `json:"tool_calls,omitempty"`
+	Role           string                  `json:"role,omitempty"`
+	Content        string                  `json:"content,omitempty"`
+	Reasoning      string                  `json:"reasoning_content,omitempty"`
+	ToolCalls      []ResponseToolCall      `json:"tool_calls,omitempty"`
+	ToolCallDeltas []ResponseToolCallDelta `json:"-"`
+}
+
+// MarshalJSON sends the tool call deltas in the tool_calls field like the
+// OpenAI streaming format.
+func (rm ResponseMessage) MarshalJSON() ([]byte, error) {
+	type message ResponseMessage
+
+	if len(rm.ToolCallDeltas) == 0 {
+		return json.Marshal(message(rm))
+	}
+
+	return json.Marshal(struct {
+		message
+		ToolCalls []ResponseToolCallDelta `json:"tool_calls"`
+	}{
+		message:   message(rm),
+		ToolCalls: rm.ToolCallDeltas,
+	})
 }
 
 // Choice represents a single choice in a response.
@@ -418,6 +456,7 @@ type Choice struct {
 	Index           int              `json:"index"`
 	Message         *ResponseMessage `json:"message,omitempty"`
 	Delta           *ResponseMessage `json:"delta,omitempty"`
+	Logprobs        *Logprobs        `json:"logprobs,omitempty"`
 	FinishReasonPtr *string          `json:"finish_reason"`
 }
 
@@ -430,9 +469,12 @@ func (c Choice) FinishReason() string {
 	return *c.FinishReasonPtr
 }
 
-// Usage provides details usage information for the request.
+// Usage provides details usage information for the request. CachedTokens is
+// the part of PromptTokens that was reused from the KV cache and didn't need
+// to be processed again.
 type Usage struct {
 	PromptTokens     int     `json:"prompt_tokens"`
+	CachedTokens     int     `json:"cached_tokens"`
 	ReasoningTokens  int     `json:"reasoning_tokens"`
 	CompletionTokens int     `json:"completion_tokens"`
 	OutputTokens     int     `json:"output_tokens"`
@@ -440,18 +482,23 @@ type Usage struct {
 	TokensPerSecond  float64 `json:"tokens_per_second"`
 }
 
-// ChatResponse represents output for inference models.
+// ChatResponse represents output for inference models. SystemFingerprint
+// identifies the model files and llama.cpp version that produced the response.
+// ContextOverflow is the strategy that was applied when the request didn't
+// fit the context window.
 type ChatResponse struct {
-	ID      string   `json:"id"`
-	Object  string   `json:"object"`
-	Created int64    `json:"created"`
-	Model   string   `json:"model"`
-	Choice  []Choice `json:"choices"`
-	Usage   Usage    `json:"usage"`
-	Prompt  string   `json:"prompt,omitempty"`
-}
-
-func chatResponseDelta(id string, object string, model string, index int, content string, reasoning bool, u Usage) ChatResponse {
+	ID                string          `json:"id"`
+	Object            string          `json:"object"`
+	Created           int64           `json:"created"`
+	Model             string          `json:"model"`
+	SystemFingerprint string          `json:"system_fingerprint,omitempty"`
+	ContextOverflow   ContextOverflow `json:"context_overflow,omitempty"`
+	Choice            []Choice        `json:"choices"`
+	Usage             Usage           `json:"usage"`
+	Prompt            string          `json:"prompt,omitempty"`
+}
+
+func chatResponseDelta(id string, object string, model string, index int, content string, reasoning bool, logprobs []ContentLogprob, u Usage) ChatResponse {
 	return ChatResponse{
 		ID:      id,
 		Object:  object,
@@ -465,6 +512,27 @@ func chatResponseDelta(id string, object string, model string, index int, conten
 					Content:   forContent(content, reasoning),
 					Reasoning: forReasoning(content, reasoning),
 				},
+				Logprobs:        toLogprobs(logprobs),
+				FinishReasonPtr: nil,
+			},
+		},
+		Usage: u,
+	}
+}
+
+func chatResponseToolCallDelta(id string, object string, model string, index int, deltas []ResponseToolCallDelta, u Usage) ChatResponse {
+	return ChatResponse{
+		ID:      id,
+		Object:  object,
+		Created: time.Now().Unix(),
+		Model:   model,
+		Choice: []Choice{
+			{
+				Index: index,
+				Delta: &ResponseMessage{
+					Role:           RoleAssistant,
+					ToolCallDeltas: deltas,
+				},
 				FinishReasonPtr: nil,
 			},
 		},
@@ -488,9 +556,24 @@ func forReasoning(content string, reasoning bool) string {
 	return ""
 }
 
-func chatResponseFinal(id string, object string, model string, index int, prompt string, content string, reasoning string, respToolCalls []ResponseToolCall, u Usage) ChatResponse {
+func toLogprobs(logprobs []ContentLogprob) *Logprobs {
+	if logprobs == nil {
+		return nil
+	}
+
+	return &Logprobs{Content: logprobs}
+}
+
+// chatResponseFinal constructs the final response. The length flag means
+// generation was cut short by max_tokens or the context window, which takes
+// precedence since any tool call in the content may be incomplete.
+func chatResponseFinal(id string, object string, model string, index int, prompt string, content string, reasoning string, respToolCalls []ResponseToolCall, logprobs []ContentLogprob, length bool, u Usage) ChatResponse {
 	finishReason := FinishReasonStop
-	if len(respToolCalls) > 0 {
+	switch {
+	case length:
+		finishReason = FinishReasonLength
+
+	case len(respToolCalls) > 0:
 		finishReason = FinishReasonTool
 	}
 
@@ -514,6 +597,7 @@ func chatResponseFinal(id string, object string, model string, index int, prompt
 					Reasoning: reasoning,
 					ToolCalls: respToolCalls,
 				},
+				Logprobs:        toLogprobs(logprobs),
 				FinishReasonPtr: &finishReason,
 			},
 		},
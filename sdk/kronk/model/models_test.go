@@ -0,0 +1,29 @@
+package model
+
+import "testing"
+
+func Test_ChatResponseFinal(t *testing.T) {
+	toolCalls := []ResponseToolCall{{ID: "call_1"}}
+
+	tests := []struct {
+		name      string
+		toolCalls []ResponseToolCall
+		length    bool
+		want      string
+	}{
+		{"stop", nil, false, FinishReasonStop},
+		{"tool", toolCalls, false, FinishReasonTool},
+		{"length", nil, true, FinishReasonLength},
+		{"length-over-tool", toolCalls, true, FinishReasonLength},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := chatResponseFinal("id", ObjectChatTextFinal, "model", 0, "", "content", "", tt.toolCalls, nil, tt.length, Usage{})
+
+			if got := resp.Choice[0].FinishReason(); got != tt.want {
+				t.Errorf("finish reason = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
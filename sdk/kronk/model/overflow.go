@@ -0,0 +1,212 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hybridgroup/yzma/pkg/llama"
+)
+
+// ContextOverflow is the strategy used when a request doesn't fit in the
+// context window.
+type ContextOverflow string
+
+// ContextOverflow strategies.
+const (
+	// ContextOverflowError fails a request when the prompt is larger than
+	// the context window (default).
+	ContextOverflowError ContextOverflow = "error"
+
+	// ContextOverflowTruncateMiddle keeps the system prompt and the latest
+	// turns of the conversation, dropping the oldest turns until the prompt
+	// leaves room for the response.
+	ContextOverflowTruncateMiddle ContextOverflow = "truncate_middle"
+
+	// ContextOverflowShift discards the oldest half of the non-system KV
+	// cache when generation runs out of room in the context window.
+	ContextOverflowShift ContextOverflow = "shift"
+)
+
+// String returns the string representation of a ContextOverflow.
+func (co ContextOverflow) String() string {
+	return string(co)
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler to validate string values.
+func (co *ContextOverflow) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+
+	parsed, err := ParseContextOverflow(s)
+	if err != nil {
+		return err
+	}
+
+	*co = parsed
+
+	return nil
+}
+
+// ParseContextOverflow parses a string into a ContextOverflow.
+// Supported values: "error", "truncate_middle", "shift".
+func ParseContextOverflow(s string) (ContextOverflow, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "error", "":
+		return ContextOverflowError, nil
+
+	case "truncate_middle", "truncate-middle", "truncate":
+		return ContextOverflowTruncateMiddle, nil
+
+	case "shift", "context_shift", "context-shift":
+		return ContextOverflowShift, nil
+
+	default:
+		return ContextOverflowError, fmt.Errorf("parse-context-overflow: unknown context overflow: %s (valid: error, truncate_middle, shift)", s)
+	}
+}
+
+// =============================================================================
+
+// truncateMiddle drops the oldest turns of the conversation until the prompt
+// leaves room for the response. The leading system messages and the latest
+// turn are always kept. A turn starts with a user message and includes the
+// assistant and tool messages that follow it, so tool results are never
+// separated from their calls. Returns true when messages were dropped.
+func (m *Model) truncateMiddle(ctx context.Context, d D, prompt string, media [][]byte, maxTokens int) (D, string, [][]byte, bool, error) {
+	budget := promptBudget(m.cfg.ContextWindow, maxTokens)
+
+	if len(llama.Tokenize(m.vocab, prompt, true, true)) <= budget {
+		return d, prompt, media, false, nil
+	}
+
+	msgs, _ := d["messages"].([]D)
+	nSystem, turns := conversationTurns(msgs)
+	if len(turns) <= 1 {
+		return d, prompt, media, false, nil
+	}
+
+	for _, turn := range turns[1:] {
+		kept := make([]D, 0, nSystem+len(msgs)-turn)
+		kept = append(kept, msgs[:nSystem]...)
+		kept = append(kept, msgs[turn:]...)
+
+		truncated := d.Clone()
+		truncated["messages"] = kept
+
+		var err error
+		prompt, media, err = m.createPrompt(ctx, truncated)
+		if err != nil {
+			return nil, "", nil, false, fmt.Errorf("truncate-middle: unable to apply jinja template: %w", err)
+		}
+
+		d = truncated
+
+		if len(llama.Tokenize(m.vocab, prompt, true, true)) <= budget {
+			break
+		}
+	}
+
+	return d, prompt, media, true, nil
+}
+
+// promptBudget returns the number of prompt tokens that leave room for a
+// response of maxTokens. At least half of the context window is available to
+// the prompt.
+func promptBudget(contextWindow int, maxTokens int) int {
+	return max(contextWindow-maxTokens, contextWindow/2)
+}
+
+// conversationTurns returns the number of leading system messages and the
+// index of the first message of every turn that follows them.
+func conversationTurns(msgs []D) (int, []int) {
+	var nSystem int
+	for nSystem < len(msgs) && isSystemMessage(msgs[nSystem]) {
+		nSystem++
+	}
+
+	var turns []int
+	for i := nSystem; i < len(msgs); i++ {
+		role, _ := msgs[i]["role"].(string)
+		if i == nSystem || role == RoleUser {
+			turns = append(turns, i)
+		}
+	}
+
+	return nSystem, turns
+}
+
+func isSystemMessage(msg D) bool {
+	role, _ := msg["role"].(string)
+	return role == RoleSystem || role == "developer"
+}
+
+// systemPromptTokens returns the number of leading tokens that belong to the
+// system messages of the request. These tokens are kept by a context shift.
+func (m *Model) systemPromptTokens(ctx context.Context, d D, tokens []llama.Token) int {
+	nKeep := 0
+	if llama.VocabGetAddBOS(m.vocab) {
+		nKeep = 1
+	}
+
+	msgs, _ := d["messages"].([]D)
+	nSystem, _ := conversationTurns(msgs)
+	if nSystem == 0 {
+		return min(nKeep, len(tokens))
+	}
+
+	system := d.Clone()
+	system["messages"] = msgs[:nSystem]
+
+	prompt, _, err := m.applyRequestJinjaTemplate(ctx, system)
+	if err != nil {
+		return min(nKeep, len(tokens))
+	}
+
+	n := commonPrefix(llama.Tokenize(m.vocab, prompt, true, true), tokens)
+
+	return max(n, min(nKeep, len(tokens)))
+}
+
+// =============================================================================
+
+// shiftContext discards the oldest half of the slot's KV sequence that comes
+// after the system prompt and moves the remaining tokens down so generation
+// can continue. Returns false when the memory doesn't support shifting.
+func (e *batchEngine) shiftContext(s *slot) bool {
+	mem := e.model.mem
+
+	if ok, err := llama.MemoryCanShift(mem); err != nil || !ok {
+		return false
+	}
+
+	if s.nKeep < 0 {
+		s.nKeep = e.model.systemPromptTokens(s.job.ctx, s.job.d, s.cachedTokens)
+	}
+
+	nKeep := llama.Pos(s.nKeep)
+	nDiscard := (s.nPast - nKeep) / 2
+	if nDiscard <= 0 {
+		return false
+	}
+
+	if ok, err := llama.MemorySeqRm(mem, s.seqID, nKeep, nKeep+nDiscard); err != nil || !ok {
+		return false
+	}
+
+	if err := llama.MemorySeqAdd(mem, s.seqID, nKeep+nDiscard, s.nPast, -nDiscard); err != nil {
+		llama.MemorySeqRm(mem, s.seqID, -1, -1)
+		s.cachedTokens = s.cachedTokens[:0]
+		return false
+	}
+
+	s.cachedTokens = append(s.cachedTokens[:nKeep], s.cachedTokens[nKeep+nDiscard:]...)
+	s.nPast -= nDiscard
+	s.overflow = ContextOverflowShift
+
+	e.model.log(s.job.ctx, "batch-engine", "status", "context-shift", "slot", s.id, "id", s.job.id, "keep", nKeep, "discard", nDiscard)
+
+	return true
+}
@@ -0,0 +1,95 @@
+package model
+
+import (
+	"slices"
+	"testing"
+)
+
+func Test_ConversationTurns(t *testing.T) {
+	tests := []struct {
+		name       string
+		msgs       []D
+		wantSystem int
+		wantTurns  []int
+	}{
+		{
+			name:       "empty",
+			msgs:       nil,
+			wantSystem: 0,
+			wantTurns:  nil,
+		},
+		{
+			name: "system-and-turns",
+			msgs: []D{
+				{"role": "system"},
+				{"role": "user"},
+				{"role": "assistant"},
+				{"role": "user"},
+				{"role": "assistant"},
+			},
+			wantSystem: 1,
+			wantTurns:  []int{1, 3},
+		},
+		{
+			name: "tool-results-stay-with-turn",
+			msgs: []D{
+				{"role": "developer"},
+				{"role": "system"},
+				{"role": "user"},
+				{"role": "assistant"},
+				{"role": "tool"},
+				{"role": "assistant"},
+				{"role": "user"},
+			},
+			wantSystem: 2,
+			wantTurns:  []int{2, 6},
+		},
+		{
+			name: "starts-with-assistant",
+			msgs: []D{
+				{"role": "assistant"},
+				{"role": "user"},
+			},
+			wantSystem: 0,
+			wantTurns:  []int{0, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nSystem, turns := conversationTurns(tt.msgs)
+			if nSystem != tt.wantSystem {
+				t.Errorf("nSystem = %d, want %d", nSystem, tt.wantSystem)
+			}
+			if !slices.Equal(turns, tt.wantTurns) {
+				t.Errorf("turns = %v, want %v", turns, tt.wantTurns)
+			}
+		})
+	}
+}
+
+func Test_ParseContextOverflow(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    ContextOverflow
+		wantErr bool
+	}{
+		{"", ContextOverflowError, false},
+		{"error", ContextOverflowError, false},
+		{"truncate_middle", ContextOverflowTruncateMiddle, false},
+		{"Shift", ContextOverflowShift, false},
+		{"drop", ContextOverflowError, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseContextOverflow(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
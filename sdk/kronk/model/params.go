@@ -2,11 +2,25 @@ package model
 
 import (
 	"fmt"
+	"math"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/hybridgroup/yzma/pkg/llama"
 )
 
+// context_overflow is the strategy used when a request doesn't fit in the
+// context window. It accepts "error", "truncate_middle" to drop the oldest
+// turns of the conversation before the prompt is processed, or "shift" to
+// discard the oldest tokens after the system prompt when generation runs out
+// of room. Default is the model config setting, which defaults to "error".
+//
+// continue_final_message continues the final message of the conversation when
+// it's from the assistant. The turn is left open in the prompt and the
+// response holds the text that follows the message, so a reply can be started
+// for the model. Default is false.
+//
 // dry_allowed_length is the minimum n-gram length before DRY applies. Default is 2.
 //
 // dry_base is the base for exponential penalty growth in DRY. Default is 1.75.
@@ -21,12 +35,52 @@ import (
 // most non-GPT models. It accepts 1, t, T, TRUE, true, True, 0, f, F, FALSE,
 // false, False. Default is "true".
 //
+// grammar is a GBNF grammar that constrains the generated output. The grammar
+// must define a "root" rule. Default is "" (unconstrained).
+//
+// frequency_penalty is a value between -2.0 and 2.0 that penalizes tokens
+// based on how often they already appear in the output. Positive values
+// reduce verbatim repetition. Default is 0.0.
+//
+// logit_bias maps tokens to a bias between -100 and 100 that is added to the
+// token's logit before sampling. A bias of -100 bans the token. Keys are token
+// ids, or text that is a single token of the model vocab. Text is looked up
+// as is and with a leading space, so a word is biased at the start and in the
+// middle of a sentence. Text made of several tokens is rejected since biasing
+// its pieces would bias other words too. Default is none.
+//
+// logprobs determines whether to return the log probability of each output
+// token. Default is false.
+//
+// max_reasoning_tokens is the thinking budget of the request. Once the model
+// reasoned for this many tokens, the end of the reasoning the template uses is
+// given to the model and it continues with the answer. The tokens of the end
+// count as completion tokens. It works with response_format and tool_choice,
+// whose grammars let the reasoning through, but not with grammar since that
+// grammar decides on its own whether the model can reason. Default is 0 (no
+// budget).
+//
 // min_p is a dynamic sampling threshold that helps balance the coherence
 // (quality) and diversity (creativity) of the generated text. Default is 0.0.
 //
+// presence_penalty is a value between -2.0 and 2.0 that penalizes tokens that
+// already appear in the output, encouraging the model to move to new topics.
+// Default is 0.0.
+//
+// priority is the scheduling class of the request: "low", "normal" or "high".
+// Waiting requests are started by priority and then in turns across the
+// subjects making them. When every slot is busy, a request pauses the running
+// request with the lowest priority below its own, which continues once a slot
+// is free again. Default is "normal".
+//
 // reasoning_effort is a string that specifies the level of reasoning effort to
 // use for GPT models. Default is ReasoningEffortMedium
 //
+// response_format constrains the output to JSON. It accepts {"type": "text"},
+// {"type": "json_object"} or {"type": "json_schema", "json_schema": {"name":
+// ..., "schema": {...}}} and is turned into a grammar, so it can't be used
+// with grammar. Default is "text".
+//
 // repeat_last_n specifies how many recent tokens to consider when applying the
 // repetition penalty. A larger value considers more context but may be slower.
 // Default is 64.
@@ -39,9 +93,33 @@ import (
 // return_prompt determines whether to include the prompt in the final response.
 // When set to true, the prompt will be included. Default is false.
 //
+// seed sets the random seed used for sampling so a request can be replayed
+// with the same output. Default is a random seed.
+//
+// stop is a string or an array of up to 4 strings. Generation ends when one of
+// them is produced and the stop sequence isn't included in the output.
+// Default is none.
+//
 // temperature controls the randomness of the output. It rescales the probability
 // distribution of possible next tokens. Default is 0.8.
 //
+// tool_call_repair asks the model once more when a tool call isn't valid JSON
+// or its arguments don't match the parameters schema of the tool. The tool
+// calls and their errors are added to the conversation and the responses of
+// the first attempt are held back until its tool calls are checked. Default
+// is false.
+//
+// tool_choice controls how the model uses the tools of the request. It accepts
+// "auto" to let the model decide, "none" to hide the tools from the model,
+// "required" to force at least one tool call, or {"type": "function",
+// "function": {"name": ...}} to force a call to that function. A forced call
+// is turned into a grammar with arguments that match the parameters schema of
+// the tool, so it can't be used with grammar or response_format. Default is
+// "auto".
+//
+// top_logprobs is the number of most likely tokens, between 0 and 20, to
+// return at each output position. Setting it turns on logprobs. Default is 0.
+//
 // top_k limits the pool of possible next tokens to the K number of most probable
 // tokens. If a model predicts 10,000 possible next tokens, setting top_k to 50
 // means only the 50 tokens with the highest probabilities are considered for
@@ -113,23 +191,38 @@ const (
 )
 
 type params struct {
-	Temperature     float32 `json:"temperature"`
-	TopK            int32   `json:"top_k"`
-	TopP            float32 `json:"top_p"`
-	MinP            float32 `json:"min_p"`
-	MaxTokens       int     `json:"max_tokens"`
-	RepeatPenalty   float32 `json:"repeat_penalty"`
-	RepeatLastN     int32   `json:"repeat_last_n"`
-	DryMultiplier   float32 `json:"dry_multiplier"`
-	DryBase         float32 `json:"dry_base"`
-	DryAllowedLen   int32   `json:"dry_allowed_length"`
-	DryPenaltyLast  int32   `json:"dry_penalty_last_n"`
-	XtcProbability  float32 `json:"xtc_probability"`
-	XtcThreshold    float32 `json:"xtc_threshold"`
-	XtcMinKeep      uint32  `json:"xtc_min_keep"`
-	Thinking        string  `json:"enable_thinking"`
-	ReasoningEffort string  `json:"reasoning_effort"`
-	ReturnPrompt    bool    `json:"return_prompt"`
+	Temperature        float32                 `json:"temperature"`
+	TopK               int32                   `json:"top_k"`
+	TopP               float32                 `json:"top_p"`
+	MinP               float32                 `json:"min_p"`
+	MaxTokens          int                     `json:"max_tokens"`
+	RepeatPenalty      float32                 `json:"repeat_penalty"`
+	RepeatLastN        int32                   `json:"repeat_last_n"`
+	DryMultiplier      float32                 `json:"dry_multiplier"`
+	DryBase            float32                 `json:"dry_base"`
+	DryAllowedLen      int32                   `json:"dry_allowed_length"`
+	DryPenaltyLast     int32                   `json:"dry_penalty_last_n"`
+	XtcProbability     float32                 `json:"xtc_probability"`
+	XtcThreshold       float32                 `json:"xtc_threshold"`
+	XtcMinKeep         uint32                  `json:"xtc_min_keep"`
+	Thinking           string                  `json:"enable_thinking"`
+	ReasoningEffort    string                  `json:"reasoning_effort"`
+	ReturnPrompt       bool                    `json:"return_prompt"`
+	Grammar            string                  `json:"grammar"`
+	Logprobs           bool                    `json:"logprobs"`
+	TopLogprobs        int                     `json:"top_logprobs"`
+	Stop               []string                `json:"stop"`
+	Seed               uint32                  `json:"seed"`
+	PresencePenalty    float32                 `json:"presence_penalty"`
+	FrequencyPenalty   float32                 `json:"frequency_penalty"`
+	LogitBias          map[llama.Token]float32 `json:"logit_bias"`
+	ContextOverflow    ContextOverflow         `json:"context_overflow"`
+	Priority           Priority                `json:"priority"`
+	ToolChoice         toolChoice              `json:"tool_choice"`
+	ToolCallRepair     bool                    `json:"tool_call_repair"`
+	Tools              []map[string]any        `json:"tools"`
+	MaxReasoningTokens int                     `json:"max_reasoning_tokens"`
+	Prefill            string                  `json:"prefill"`
 }
 
 func (m *Model) parseParams(d D) (params, error) {
@@ -178,6 +271,19 @@ func (m *Model) parseParams(d D) (params, error) {
 		}
 	}
 
+	var maxReasoning int
+	if val, exists := d["max_reasoning_tokens"]; exists {
+		var err error
+		maxReasoning, err = parseInt("max_reasoning_tokens", val)
+		if err != nil {
+			return params{}, err
+		}
+
+		if maxReasoning < 0 {
+			return params{}, fmt.Errorf("parse-params: max_reasoning_tokens must be 0 or more")
+		}
+	}
+
 	enableThinking := true
 	if enableThinkingVal, exists := d["enable_thinking"]; exists {
 		var err error
@@ -286,24 +392,194 @@ func (m *Model) parseParams(d D) (params, error) {
 		}
 	}
 
+	var logprobs bool
+	if val, exists := d["logprobs"]; exists {
+		var err error
+		logprobs, err = parseBool("logprobs", val)
+		if err != nil {
+			return params{}, err
+		}
+	}
+
+	var topLogprobs int
+	if val, exists := d["top_logprobs"]; exists {
+		var err error
+		topLogprobs, err = parseInt("top_logprobs", val)
+		if err != nil {
+			return params{}, err
+		}
+
+		if topLogprobs < 0 || topLogprobs > maxTopLogprobs {
+			return params{}, fmt.Errorf("parse-params: top_logprobs must be between 0 and %d", maxTopLogprobs)
+		}
+
+		if topLogprobs > 0 {
+			logprobs = true
+		}
+	}
+
+	seed := uint32(llama.DefaultSeed)
+	if val, exists := d["seed"]; exists {
+		var err error
+		seed, err = parseSeed("seed", val)
+		if err != nil {
+			return params{}, err
+		}
+	}
+
+	var presencePenalty float32
+	if val, exists := d["presence_penalty"]; exists {
+		var err error
+		presencePenalty, err = parsePenalty("presence_penalty", val)
+		if err != nil {
+			return params{}, err
+		}
+	}
+
+	var frequencyPenalty float32
+	if val, exists := d["frequency_penalty"]; exists {
+		var err error
+		frequencyPenalty, err = parsePenalty("frequency_penalty", val)
+		if err != nil {
+			return params{}, err
+		}
+	}
+
+	var logitBias map[llama.Token]float32
+	if val, exists := d["logit_bias"]; exists {
+		var err error
+		logitBias, err = m.parseLogitBias("logit_bias", val)
+		if err != nil {
+			return params{}, err
+		}
+	}
+
+	contextOverflow := m.cfg.ContextOverflow
+	if val, exists := d["context_overflow"]; exists {
+		var err error
+		contextOverflow, err = parseContextOverflow("context_overflow", val)
+		if err != nil {
+			return params{}, err
+		}
+	}
+
+	priority := PriorityNormal
+	if val, exists := d["priority"]; exists {
+		var err error
+		priority, err = parsePriority("priority", val)
+		if err != nil {
+			return params{}, err
+		}
+	}
+
+	var stop []string
+	if val, exists := d["stop"]; exists {
+		var err error
+		stop, err = parseStop("stop", val)
+		if err != nil {
+			return params{}, err
+		}
+	}
+
+	var grammar string
+	if val, exists := d["grammar"]; exists {
+		var err error
+		grammar, err = m.parseGrammar("grammar", val)
+		if err != nil {
+			return params{}, err
+		}
+
+		// The tokens that end the reasoning might not be allowed by the
+		// grammar, which llama.cpp doesn't recover from. The grammars of
+		// response_format and tool_choice start with the reasoning block,
+		// which accepts them.
+		if grammar != "" && maxReasoning > 0 {
+			return params{}, fmt.Errorf("parse-params: grammar and max_reasoning_tokens can't be used together, use response_format instead")
+		}
+	}
+
+	if val, exists := d["response_format"]; exists {
+		formatGrammar, err := m.parseResponseFormat("response_format", val)
+		if err != nil {
+			return params{}, err
+		}
+
+		if formatGrammar != "" {
+			if grammar != "" {
+				return params{}, fmt.Errorf("parse-params: grammar and response_format can't be used together")
+			}
+			grammar = formatGrammar
+		}
+	}
+
+	tools := requestTools(d)
+
+	toolChoice, err := parseToolChoice("tool_choice", d["tool_choice"], tools)
+	if err != nil {
+		return params{}, err
+	}
+
+	choiceGrammar, err := m.toolChoiceGrammar("tool_choice", toolChoice, tools)
+	if err != nil {
+		return params{}, err
+	}
+
+	if choiceGrammar != "" {
+		if grammar != "" {
+			return params{}, fmt.Errorf("parse-params: tool_choice %s can't be used with grammar or response_format", toolChoice.Mode)
+		}
+		grammar = choiceGrammar
+	}
+
+	logitBias = m.toolChoiceBias(toolChoice, logitBias)
+
+	var toolCallRepair bool
+	if val, exists := d["tool_call_repair"]; exists {
+		var err error
+		toolCallRepair, err = parseBool("tool_call_repair", val)
+		if err != nil {
+			return params{}, err
+		}
+	}
+
+	prefill, err := continueFinalMessage(d)
+	if err != nil {
+		return params{}, err
+	}
+
 	p := params{
-		Temperature:     temp,
-		TopK:            int32(topK),
-		TopP:            topP,
-		MinP:            minP,
-		MaxTokens:       maxTokens,
-		RepeatPenalty:   repeatPenalty,
-		RepeatLastN:     int32(repeatLastN),
-		DryMultiplier:   dryMultiplier,
-		DryBase:         dryBase,
-		DryAllowedLen:   int32(dryAllowedLen),
-		DryPenaltyLast:  int32(dryPenaltyLast),
-		XtcProbability:  xtcProbability,
-		XtcThreshold:    xtcThreshold,
-		XtcMinKeep:      uint32(xtcMinKeep),
-		Thinking:        strconv.FormatBool(enableThinking),
-		ReasoningEffort: reasoningEffort,
-		ReturnPrompt:    returnPrompt,
+		Temperature:        temp,
+		TopK:               int32(topK),
+		TopP:               topP,
+		MinP:               minP,
+		MaxTokens:          maxTokens,
+		RepeatPenalty:      repeatPenalty,
+		RepeatLastN:        int32(repeatLastN),
+		DryMultiplier:      dryMultiplier,
+		DryBase:            dryBase,
+		DryAllowedLen:      int32(dryAllowedLen),
+		DryPenaltyLast:     int32(dryPenaltyLast),
+		XtcProbability:     xtcProbability,
+		XtcThreshold:       xtcThreshold,
+		XtcMinKeep:         uint32(xtcMinKeep),
+		Thinking:           strconv.FormatBool(enableThinking),
+		ReasoningEffort:    reasoningEffort,
+		ReturnPrompt:       returnPrompt,
+		Grammar:            grammar,
+		Logprobs:           logprobs,
+		TopLogprobs:        topLogprobs,
+		Stop:               stop,
+		Seed:               seed,
+		PresencePenalty:    presencePenalty,
+		FrequencyPenalty:   frequencyPenalty,
+		LogitBias:          logitBias,
+		ContextOverflow:    contextOverflow,
+		Priority:           priority,
+		ToolChoice:         toolChoice,
+		ToolCallRepair:     toolCallRepair,
+		Tools:              tools,
+		MaxReasoningTokens: maxReasoning,
+		Prefill:            prefill,
 	}
 
 	return m.adjustParams(p), nil
@@ -380,25 +656,153 @@ func (m *Model) adjustParams(p params) params {
 func (m *Model) toSampler(p params) llama.Sampler {
 	sampler := llama.SamplerChainInit(llama.SamplerChainDefaultParams())
 
+	if len(p.LogitBias) > 0 {
+		biases := make([]llama.LogitBias, 0, len(p.LogitBias))
+		for token, bias := range p.LogitBias {
+			biases = append(biases, llama.LogitBias{Token: token, Bias: bias})
+		}
+
+		slices.SortFunc(biases, func(a, b llama.LogitBias) int {
+			return int(a.Token - b.Token)
+		})
+
+		llama.SamplerChainAdd(sampler, llama.SamplerInitLogitBias(llama.VocabNTokens(m.vocab), int32(len(biases)), &biases[0]))
+	}
+
+	// The grammar goes first so the rest of the chain only sees the tokens
+	// the grammar allows.
+	if p.Grammar != "" {
+		llama.SamplerChainAdd(sampler, llama.SamplerInitGrammar(m.vocab, p.Grammar, grammarRoot))
+	}
+
 	// TODO: DRY sampler disabled - yzma crashes when seqBreakers is nil.
 	// Waiting for yzma fix to properly handle empty sequence breakers.
 	// if p.DryMultiplier > 0 {
 	// 	llama.SamplerChainAdd(sampler, llama.SamplerInitDry(m.vocab, int32(m.cfg.ContextWindow), p.DryMultiplier, p.DryBase, p.DryAllowedLen, p.DryPenaltyLast, nil, 0))
 	// }
 
-	llama.SamplerChainAdd(sampler, llama.SamplerInitPenalties(p.RepeatLastN, p.RepeatPenalty, 0, 0))
+	llama.SamplerChainAdd(sampler, llama.SamplerInitPenalties(p.RepeatLastN, p.RepeatPenalty, p.FrequencyPenalty, p.PresencePenalty))
 	llama.SamplerChainAdd(sampler, llama.SamplerInitTopK(p.TopK))
 	llama.SamplerChainAdd(sampler, llama.SamplerInitTopP(p.TopP, 0))
 	llama.SamplerChainAdd(sampler, llama.SamplerInitMinP(p.MinP, 0))
 	if p.XtcProbability > 0 {
-		llama.SamplerChainAdd(sampler, llama.SamplerInitXTC(p.XtcProbability, p.XtcThreshold, p.XtcMinKeep, llama.DefaultSeed))
+		llama.SamplerChainAdd(sampler, llama.SamplerInitXTC(p.XtcProbability, p.XtcThreshold, p.XtcMinKeep, p.Seed))
 	}
 	llama.SamplerChainAdd(sampler, llama.SamplerInitTempExt(p.Temperature, 0, 1.0))
-	llama.SamplerChainAdd(sampler, llama.SamplerInitDist(llama.DefaultSeed))
+	llama.SamplerChainAdd(sampler, llama.SamplerInitDist(p.Seed))
 
 	return sampler
 }
 
+// grammarRoot is the rule a grammar must define to start generation from.
+const grammarRoot = "root"
+
+func (m *Model) parseGrammar(fieldName string, val any) (string, error) {
+	grammar, ok := val.(string)
+	if !ok {
+		return "", fmt.Errorf("parse-grammar: field-name[%s] is not a valid type", fieldName)
+	}
+
+	if grammar == "" {
+		return "", nil
+	}
+
+	if !m.validGrammar(grammar) {
+		return "", fmt.Errorf("parse-grammar: field-name[%s] is not a valid GBNF grammar with a %q rule", fieldName, grammarRoot)
+	}
+
+	return grammar, nil
+}
+
+// validGrammar reports if llama.cpp accepts the grammar. llama.cpp returns a
+// nil sampler when the grammar can't be parsed or the root rule is missing,
+// so one is built here to reject a bad grammar before the request reaches
+// the model.
+func (m *Model) validGrammar(grammar string) bool {
+	sampler := llama.SamplerInitGrammar(m.vocab, grammar, grammarRoot)
+	if sampler == 0 {
+		return false
+	}
+	llama.SamplerFree(sampler)
+
+	return true
+}
+
+func (m *Model) parseResponseFormat(fieldName string, val any) (string, error) {
+	v, err := normalizeSchema(val)
+	if err != nil {
+		return "", fmt.Errorf("parse-response-format: field-name[%s] is not valid: %w", fieldName, err)
+	}
+
+	format, ok := v.(map[string]any)
+	if !ok {
+		return "", fmt.Errorf("parse-response-format: field-name[%s] is not a valid type", fieldName)
+	}
+
+	var rules, start string
+
+	switch typ, _ := format["type"].(string); typ {
+	case "", ResponseFormatText:
+		return "", nil
+
+	case ResponseFormatJSONObject:
+		rules, start = jsonObjectRules()
+
+		if schema, exists := format["schema"]; exists {
+			rules, start, err = jsonSchemaRules(schema)
+			if err != nil {
+				return "", fmt.Errorf("parse-response-format: field-name[%s] schema is not supported: %w", fieldName, err)
+			}
+		}
+
+	case ResponseFormatJSONSchema:
+		jsonSchema, _ := format["json_schema"].(map[string]any)
+
+		schema, exists := jsonSchema["schema"]
+		if !exists {
+			return "", fmt.Errorf("parse-response-format: field-name[%s] json_schema.schema is required", fieldName)
+		}
+
+		rules, start, err = jsonSchemaRules(schema)
+		if err != nil {
+			return "", fmt.Errorf("parse-response-format: field-name[%s] schema is not supported: %w", fieldName, err)
+		}
+
+	default:
+		return "", fmt.Errorf("parse-response-format: field-name[%s] is not valid type[%s]", fieldName, typ)
+	}
+
+	grammar := m.formatGrammar(rules, start)
+
+	if !m.validGrammar(grammar) {
+		return "", fmt.Errorf("parse-response-format: field-name[%s] schema produced an invalid grammar", fieldName)
+	}
+
+	return grammar, nil
+}
+
+// formatGrammar adds the root rule for a structured output grammar. The
+// model is still allowed to reason first, so the root lets the reasoning
+// block through before the document starts.
+func (m *Model) formatGrammar(rules string, start string) string {
+	root := m.reasoningGrammar() + " " + start
+	if m.modelInfo.IsGPTModel {
+		root = m.reasoningGrammar() + ` "<|channel|>final<|message|>" ` + start
+	}
+
+	return grammarRoot + " ::= " + root + "\n" + rules
+}
+
+// reasoningGrammar returns the expression for the optional reasoning block
+// the model can write before the constrained output.
+func (m *Model) reasoningGrammar() string {
+	if m.modelInfo.IsGPTModel {
+		return `( "<|channel|>analysis<|message|>" ( [^<] | "<" [^|] )* "<|end|><|start|>assistant" )?`
+	}
+
+	return `( "<think>" ( [^<] | "<" [^/] )* "</think>" [ \n]{0,8} )?`
+}
+
 func parseFloat32(fieldName string, val any) (float32, error) {
 	var result float32
 
@@ -465,10 +869,149 @@ func parseInt(fieldName string, val any) (int, error) {
 	return result, nil
 }
 
+// Logit bias values are clamped to this range, where the minimum bans the
+// token from being sampled.
+const (
+	minLogitBias = -100
+	maxLogitBias = 100
+)
+
+func (m *Model) parseLogitBias(fieldName string, val any) (map[llama.Token]float32, error) {
+	var entries map[string]any
+
+	switch v := val.(type) {
+	case nil:
+		return nil, nil
+
+	case D:
+		entries = v
+
+	case map[string]any:
+		entries = v
+
+	default:
+		return nil, fmt.Errorf("parse-logit-bias: field-name[%s] is not a valid type", fieldName)
+	}
+
+	nVocab := llama.VocabNTokens(m.vocab)
+	result := make(map[llama.Token]float32, len(entries))
+
+	for key, v := range entries {
+		bias, err := parseFloat32(fieldName, v)
+		if err != nil {
+			return nil, err
+		}
+
+		if bias < minLogitBias || bias > maxLogitBias {
+			return nil, fmt.Errorf("parse-logit-bias: field-name[%s] key[%s] bias must be between %d and %d", fieldName, key, minLogitBias, maxLogitBias)
+		}
+
+		if bias == minLogitBias {
+			bias = float32(math.Inf(-1))
+		}
+
+		var tokens []llama.Token
+		switch id, err := strconv.ParseInt(key, 10, 32); {
+		case err == nil:
+			if id < 0 || id >= int64(nVocab) {
+				return nil, fmt.Errorf("parse-logit-bias: field-name[%s] token[%d] is not in the vocab", fieldName, id)
+			}
+			tokens = []llama.Token{llama.Token(id)}
+
+		default:
+			tokenize := func(text string) []llama.Token {
+				return llama.Tokenize(m.vocab, text, false, false)
+			}
+
+			tokens, err = logitBiasTokens(key, tokenize)
+			if err != nil {
+				return nil, fmt.Errorf("parse-logit-bias: field-name[%s] %w", fieldName, err)
+			}
+		}
+
+		for _, token := range tokens {
+			result[token] += bias
+		}
+	}
+
+	return result, nil
+}
+
+// logitBiasTokens returns the tokens of a text key of logit_bias. The text is
+// tokenized as is and with a leading space, the form words take in the middle
+// of a sentence, and each form that is a single token gets the bias. Biasing
+// the pieces of a longer text would also bias the other words that share
+// them, so text that isn't a single token in either form is rejected.
+func logitBiasTokens(key string, tokenize func(text string) []llama.Token) ([]llama.Token, error) {
+	forms := []string{key}
+	if !strings.HasPrefix(key, " ") {
+		forms = append(forms, " "+key)
+	}
+
+	var tokens []llama.Token
+	for _, form := range forms {
+		if t := tokenize(form); len(t) == 1 && !slices.Contains(tokens, t[0]) {
+			tokens = append(tokens, t[0])
+		}
+	}
+
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("key[%s] is not a single token, use the token ids instead", key)
+	}
+
+	return tokens, nil
+}
+
+func parsePenalty(fieldName string, val any) (float32, error) {
+	penalty, err := parseFloat32(fieldName, val)
+	if err != nil {
+		return 0, err
+	}
+
+	if penalty < -2 || penalty > 2 {
+		return 0, fmt.Errorf("parse-penalty: field-name[%s] must be between -2.0 and 2.0", fieldName)
+	}
+
+	return penalty, nil
+}
+
+func parseSeed(fieldName string, val any) (uint32, error) {
+	var seed int
+
+	switch v := val.(type) {
+	case nil:
+		return llama.DefaultSeed, nil
+
+	case string:
+		// Parsed directly since a float32 can't hold every seed value.
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("parse-seed: field-name[%s] is not valid: %w", fieldName, err)
+		}
+		seed = int(n)
+
+	default:
+		var err error
+		seed, err = parseInt(fieldName, val)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if seed < 0 || seed >= llama.DefaultSeed {
+		return 0, fmt.Errorf("parse-seed: field-name[%s] must be between 0 and %d", fieldName, uint32(llama.DefaultSeed)-1)
+	}
+
+	return uint32(seed), nil
+}
+
 func parseBool(fieldName string, val any) (bool, error) {
 	result := true
 
 	switch v := val.(type) {
+	case bool:
+		result = v
+
 	case string:
 		if v == "" {
 			break
@@ -503,3 +1046,31 @@ func parseReasoningString(fieldName string, val any) (string, error) {
 
 	return result, nil
 }
+
+func parseContextOverflow(fieldName string, val any) (ContextOverflow, error) {
+	v, ok := val.(string)
+	if !ok {
+		return "", fmt.Errorf("parse-context-overflow: field-name[%s] is not a string", fieldName)
+	}
+
+	co, err := ParseContextOverflow(v)
+	if err != nil {
+		return "", fmt.Errorf("parse-context-overflow: field-name[%s]: %w", fieldName, err)
+	}
+
+	return co, nil
+}
+
+func parsePriority(fieldName string, val any) (Priority, error) {
+	v, ok := val.(string)
+	if !ok {
+		return PriorityNormal, fmt.Errorf("parse-priority: field-name[%s] is not a string", fieldName)
+	}
+
+	p, err := ParsePriority(v)
+	if err != nil {
+		return PriorityNormal, fmt.Errorf("parse-priority: field-name[%s]: %w", fieldName, err)
+	}
+
+	return p, nil
+}
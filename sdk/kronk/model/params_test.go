@@ -0,0 +1,53 @@
+package model
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/hybridgroup/yzma/pkg/llama"
+)
+
+func Test_LogitBiasTokens(t *testing.T) {
+	vocab := map[string][]llama.Token{
+		"word":     {10},
+		" word":    {11},
+		"Hello":    {20},
+		" Hello":   {20},
+		"only":     {30, 31},
+		" only":    {32},
+		"unbroken": {40, 41},
+	}
+
+	tokenize := func(text string) []llama.Token {
+		if tokens, ok := vocab[text]; ok {
+			return tokens
+		}
+		return []llama.Token{98, 99}
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		want []llama.Token
+		ok   bool
+	}{
+		{"bare-and-spaced", "word", []llama.Token{10, 11}, true},
+		{"same-token", "Hello", []llama.Token{20}, true},
+		{"spaced-only", "only", []llama.Token{32}, true},
+		{"leading-space", " word", []llama.Token{11}, true},
+		{"multi-token", "unbroken", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := logitBiasTokens(tt.key, tokenize)
+			if (err == nil) != tt.ok {
+				t.Fatalf("logitBiasTokens(%q) error = %v, want ok %v", tt.key, err, tt.ok)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("logitBiasTokens(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
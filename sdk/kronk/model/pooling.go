@@ -0,0 +1,96 @@
+package model
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hybridgroup/yzma/pkg/llama"
+)
+
+// maxPooledTokens returns the maximum number of tokens a single input can
+// have for embedding and reranking. The whole input has to be processed in one
+// micro batch for the pooled output.
+func maxPooledTokens(lctx llama.Context) int {
+	return int(min(llama.NUBatch(lctx), llama.NCtx(lctx)))
+}
+
+// decodePooled packs the token sequences into batches, each input on its own
+// sequence id, up to the number of sequences and tokens the context allows.
+// After each batch is decoded, fn is called in input order with the pooled
+// output of every sequence in the batch. The output is only valid until the
+// next decode, so fn needs to copy what it keeps.
+func (m *Model) decodePooled(ctx context.Context, seqs [][]llama.Token, nOut int32, fn func(i int, out []float32) error) error {
+	maxTokens := maxPooledTokens(m.lctx)
+	nSeq := max(int(llama.NSeqMax(m.lctx)), 1)
+
+	batch := llama.BatchInit(int32(maxTokens), 0, 1)
+	defer llama.BatchFree(batch)
+
+	for start := 0; start < len(seqs); {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		default:
+		}
+
+		batchClear(&batch)
+
+		end := pooledBatchEnd(seqs, start, nSeq, maxTokens)
+		if end == start {
+			return fmt.Errorf("decode-pooled: input[%d] has %d tokens but max is %d", start, len(seqs[start]), maxTokens)
+		}
+
+		for i := start; i < end; i++ {
+			seqID := []llama.SeqId{llama.SeqId(i - start)}
+			for pos, token := range seqs[i] {
+				batchAdd(&batch, token, llama.Pos(pos), seqID, true)
+			}
+		}
+
+		// The sequence ids are used again by the next batch.
+		llama.MemoryClear(m.mem, true)
+
+		ret, err := llama.Decode(m.lctx, batch)
+		if err != nil {
+			return fmt.Errorf("decode-pooled: decode failed for input[%d:%d]: %w", start, end, err)
+		}
+
+		if ret != 0 {
+			return fmt.Errorf("decode-pooled: decode returned non-zero for input[%d:%d]: %d", start, end, ret)
+		}
+
+		for i := start; i < end; i++ {
+			out, err := llama.GetEmbeddingsSeq(m.lctx, llama.SeqId(i-start), nOut)
+			if err != nil {
+				return fmt.Errorf("decode-pooled: unable to get pooled output for input[%d]: %w", i, err)
+			}
+
+			if err := fn(i, out); err != nil {
+				return err
+			}
+		}
+
+		start = end
+	}
+
+	llama.MemoryClear(m.mem, true)
+
+	return nil
+}
+
+// pooledBatchEnd returns the end of the batch that starts with the input at
+// start. The batch takes the inputs in order while there is a sequence id and
+// room for all of their tokens. The end is start when the first input doesn't
+// fit on its own.
+func pooledBatchEnd(seqs [][]llama.Token, start int, nSeq int, maxTokens int) int {
+	var nTokens int
+
+	end := start
+	for end < len(seqs) && end-start < nSeq && nTokens+len(seqs[end]) <= maxTokens {
+		nTokens += len(seqs[end])
+		end++
+	}
+
+	return end
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/hybridgroup/yzma/pkg/llama"
+)
+
+func Test_PooledBatchEnd(t *testing.T) {
+	seqs := func(lens ...int) [][]llama.Token {
+		s := make([][]llama.Token, len(lens))
+		for i, n := range lens {
+			s[i] = make([]llama.Token, n)
+		}
+		return s
+	}
+
+	tests := []struct {
+		name      string
+		seqs      [][]llama.Token
+		nSeq      int
+		maxTokens int
+		want      [][2]int
+	}{
+		{"one-batch", seqs(2, 3, 4), 4, 16, [][2]int{{0, 3}}},
+		{"seq-limit", seqs(1, 1, 1, 1, 1), 2, 16, [][2]int{{0, 2}, {2, 4}, {4, 5}}},
+		{"token-limit", seqs(4, 4, 4, 8), 4, 8, [][2]int{{0, 2}, {2, 3}, {3, 4}}},
+		{"single-seq", seqs(3, 3), 1, 16, [][2]int{{0, 1}, {1, 2}}},
+		{"too-long", seqs(2, 9, 2), 4, 8, [][2]int{{0, 1}, {1, 1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got [][2]int
+			for start := 0; start < len(tt.seqs); {
+				end := pooledBatchEnd(tt.seqs, start, tt.nSeq, tt.maxTokens)
+				got = append(got, [2]int{start, end})
+				if end == start {
+					break
+				}
+				start = end
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("batches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
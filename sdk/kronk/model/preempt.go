@@ -0,0 +1,125 @@
+package model
+
+import (
+	"slices"
+	"time"
+
+	"github.com/ardanlabs/kronk/sdk/kronk/observ/metrics"
+	"github.com/hybridgroup/yzma/pkg/llama"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// preemptSlot frees a slot for a pending job when every slot is busy and the
+// job has a higher priority than a generating slot. The request of the lowest
+// priority slot is parked with its sampler and generation state and put back
+// in the queue. Only the slot's KV sequence is given up, so the request
+// continues where it left off once it gets a slot again. Slots with media
+// are never preempted since their prompt can't be decoded again from tokens.
+func (e *batchEngine) preemptSlot() {
+	e.queueSubmitted()
+
+	if e.hasIdleSlots() {
+		return
+	}
+
+	priority, ok := e.pending.priority()
+	if !ok {
+		return
+	}
+
+	var victim *slot
+	for _, s := range e.slots {
+		if !s.active || !s.prefillDone || s.media || s.job.params.Priority >= priority {
+			continue
+		}
+
+		switch {
+		case victim == nil:
+			victim = s
+
+		case s.job.params.Priority < victim.job.params.Priority:
+			victim = s
+
+		// Among slots of the same priority, the one that started last has
+		// the least work to redo.
+		case s.job.params.Priority == victim.job.params.Priority && s.startTime.After(victim.startTime):
+			victim = s
+		}
+	}
+
+	if victim != nil {
+		e.parkSlot(victim)
+	}
+}
+
+// parkSlot takes the slot's request out of the engine and queues it again.
+// The slot is replaced by an idle slot that keeps the KV sequence, so its
+// cache can still be reused by the next request.
+func (e *batchEngine) parkSlot(s *slot) {
+	idle := slot{
+		id:           s.id,
+		seqID:        s.seqID,
+		proc:         newProcessor(e.model),
+		cachedTokens: s.cachedTokens,
+		lastUsed:     time.Now(),
+	}
+	idle.reset()
+
+	e.slots[s.id] = &idle
+
+	// The prompt and generated tokens are decoded again when the request
+	// resumes. The sampled token hasn't been decoded yet so it's kept
+	// aside and decoded in the first batch after the prefill.
+	s.resumeTokens = slices.Clone(s.cachedTokens[:s.nPast])
+	s.cachedTokens = nil
+	s.iBatch = -1
+
+	s.job.parked = s
+	s.job.queued = time.Now()
+	e.pending.push(s.job)
+
+	s.span.AddEvent("preempted")
+	metrics.AddPreemptions()
+
+	e.model.log(s.job.ctx, "batch-engine", "status", "slot-preempted", "slot", s.id, "id", s.job.id, "priority", s.job.params.Priority, "tokens", len(s.resumeTokens))
+}
+
+// resumeSlot puts a parked request into the place of the idle slot and
+// starts the prefill of its prompt and generated tokens. Returns the number
+// of tokens added to the batch.
+func (e *batchEngine) resumeSlot(idle *slot, s *slot, budget int) int {
+	tokens := s.resumeTokens
+
+	s.id = idle.id
+	s.seqID = idle.seqID
+	s.cachedTokens = idle.cachedTokens
+	s.lastUsed = time.Now()
+	s.resumeTokens = nil
+	s.job.parked = nil
+
+	e.slots[s.id] = s
+
+	nCached := e.reuseCachedPrefix(s, tokens)
+
+	s.prefillTokens = tokens
+	s.nPrefilled = nCached
+	s.nPast = llama.Pos(nCached)
+	s.prefillDone = false
+	s.resuming = true
+
+	s.span.AddEvent("resumed", trace.WithAttributes(attribute.Int("slot", s.id)))
+
+	n := e.addPrefillChunk(s, budget)
+
+	e.model.log(s.job.ctx, "batch-engine", "status", "slot-resumed", "slot", s.id, "id", s.job.id, "tokens", len(tokens), "cached_tokens", nCached)
+
+	return n
+}
+
+// releaseParked frees the resources held by a parked request that will not
+// be resumed.
+func (e *batchEngine) releaseParked(s *slot) {
+	s.span.End()
+	e.freeSlotResources(s)
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// continueFinalMessage returns the content of the final message when
+// continue_final_message is set and the conversation ends with a message from
+// the assistant. The response continues this text instead of starting a new
+// turn. An empty string means there is nothing to continue.
+func continueFinalMessage(d D) (string, error) {
+	val, exists := d["continue_final_message"]
+	if !exists {
+		return "", nil
+	}
+
+	cont, err := parseBool("continue_final_message", val)
+	if err != nil || !cont {
+		return "", err
+	}
+
+	msgs, _ := d["messages"].([]D)
+	if len(msgs) == 0 {
+		return "", nil
+	}
+
+	last := msgs[len(msgs)-1]
+
+	if role, _ := last["role"].(string); role != RoleAssistant {
+		return "", nil
+	}
+
+	content, _ := last["content"].(string)
+
+	return content, nil
+}
+
+// openFinalMessage cuts the prompt right after the content of the final
+// message, which drops the end of turn the template wrote after it. Templates
+// that trim the content only leave the trimmed text in the prompt.
+func openFinalMessage(prompt string, content string) (string, error) {
+	for _, text := range []string{content, strings.TrimSpace(content)} {
+		if text == "" {
+			continue
+		}
+
+		if i := strings.LastIndex(prompt, text); i >= 0 {
+			return prompt[:i+len(text)], nil
+		}
+	}
+
+	return "", fmt.Errorf("open-final-message: unable to find the final message in the prompt")
+}
@@ -0,0 +1,88 @@
+package model
+
+import "testing"
+
+func Test_ContinueFinalMessage(t *testing.T) {
+	msgs := []D{
+		{"role": RoleUser, "content": "List three colors as JSON."},
+		{"role": RoleAssistant, "content": "```json\n{"},
+	}
+
+	tests := []struct {
+		name string
+		d    D
+		want string
+	}{
+		{name: "off", d: D{"messages": msgs}, want: ""},
+		{name: "on", d: D{"messages": msgs, "continue_final_message": true}, want: "```json\n{"},
+		{name: "last-user", d: D{"messages": msgs[:1], "continue_final_message": true}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := continueFinalMessage(tt.d)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_OpenFinalMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		prompt  string
+		content string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "chatml",
+			prompt:  "<|im_start|>user\nhi<|im_end|>\n<|im_start|>assistant\nHello, <|im_end|>\n",
+			content: "Hello, ",
+			want:    "<|im_start|>user\nhi<|im_end|>\n<|im_start|>assistant\nHello, ",
+		},
+		{
+			name:    "trimmed",
+			prompt:  "<|start_header_id|>assistant<|end_header_id|>\n\nHello,<|eot_id|>",
+			content: "Hello, ",
+			want:    "<|start_header_id|>assistant<|end_header_id|>\n\nHello,",
+		},
+		{
+			name:    "last-occurrence",
+			prompt:  "<|im_start|>user\nok<|im_end|>\n<|im_start|>assistant\nok<|im_end|>\n",
+			content: "ok",
+			want:    "<|im_start|>user\nok<|im_end|>\n<|im_start|>assistant\nok",
+		},
+		{
+			name:    "missing",
+			prompt:  "<|im_start|>assistant\nsomething else<|im_end|>\n",
+			content: "Hello",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := openFinalMessage(tt.prompt, tt.content)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
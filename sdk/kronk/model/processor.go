@@ -2,9 +2,7 @@ package model
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
 	"strings"
 
 	"github.com/google/uuid"
@@ -29,190 +27,36 @@ type processor struct {
 	collecting      bool
 	awaitingChannel bool
 
-	// For accumulating tool call content across tokens (batch engine use).
+	// format marks and parses the tool calls of standard models.
+	format ToolCallFormat
+
+	// For accumulating tool call content across tokens.
 	toolCallBuf strings.Builder
 	inToolCall  bool
+
+	// forced holds the tokens that end the reasoning once the reasoning
+	// budget is spent. They are handed out in place of sampled tokens.
+	forced         []llama.Token
+	reasoningEnded bool
 }
 
 func newProcessor(m *Model) *processor {
 	return &processor{
 		model:  m,
 		status: statusCompletion,
+		format: m.toolFormat,
 	}
 }
 
-// standardFirst samples the first token after prefill without re-decoding.
-// Use this for the first token after prefill when logits are already computed.
-func (p *processor) standardFirst(lctx llama.Context, sampler llama.Sampler, buf []byte) (response, llama.Token, error) {
-	content, token, err := p.model.sampleToken(lctx, sampler, buf)
-	if err != nil {
-		return response{}, token, err
-	}
-
-	return p.standardProcess(lctx, content, token, sampler, buf)
-}
-
-func (p *processor) standard(lctx llama.Context, batch llama.Batch, sampler llama.Sampler, buf []byte) (response, llama.Token, error) {
-	content, token, err := p.model.batchResponse(lctx, batch, sampler, buf)
-	if err != nil {
-		return response{}, token, err
+// toolCallContent trims the newlines around the content of a tool call and
+// ends it with a single newline to separate it from the next one.
+func toolCallContent(content string) string {
+	content = strings.Trim(content, "\n")
+	if content == "" {
+		return ""
 	}
 
-	return p.standardProcess(lctx, content, token, sampler, buf)
-}
-
-// standardProcess handles token content for standard (non-GPT) models.
-func (p *processor) standardProcess(lctx llama.Context, content string, token llama.Token, sampler llama.Sampler, buf []byte) (response, llama.Token, error) {
-	switch content {
-	case "<think>":
-		p.status = statusReasoning
-		return response{}, token, nil
-
-	case "</think>":
-		p.status = statusCompletion
-		return response{}, token, nil
-
-	case "<tool_call>":
-		p.status = statusTooling
-		var w strings.Builder
-
-		for {
-			batch, content, err := p.standardToolCall(lctx, token, sampler, buf)
-			if err != nil {
-				return response{}, token, err
-			}
-
-			w.WriteString(content)
-
-			_, token, err = p.model.batchResponse(lctx, batch, sampler, buf)
-			if err != nil {
-				if errors.Is(err, io.EOF) {
-					break
-				}
-
-				return response{}, token, err
-			}
-		}
-
-		return response{status: p.status, content: w.String()}, token, nil
-
-	default:
-		return response{status: p.status, content: content}, token, nil
-	}
-}
-
-func (p *processor) standardToolCall(lctx llama.Context, token llama.Token, sampler llama.Sampler, buf []byte) (llama.Batch, string, error) {
-	var batch llama.Batch
-	var content string
-	var err error
-	var data strings.Builder
-
-	for {
-		batch = p.model.nextBatch(token)
-		content, token, err = p.model.batchResponse(lctx, batch, sampler, buf)
-		if err != nil {
-			return batch, "", err
-		}
-
-		if content == "<tool_call>" {
-			continue
-		}
-
-		if content == "</tool_call>" {
-			break
-		}
-
-		data.WriteString(content)
-	}
-
-	content = strings.Trim(data.String(), "\n")
-	content = fmt.Sprintf("%s\n", content)
-
-	batch = p.model.nextBatch(token)
-
-	return batch, content, nil
-}
-
-// =============================================================================
-
-// gptFirst samples the first token after prefill without re-decoding.
-// Use this for the first token after prefill when logits are already computed.
-func (p *processor) gptFirst(lctx llama.Context, sampler llama.Sampler, buf []byte) (response, llama.Token, error) {
-	content, token, err := p.model.sampleToken(lctx, sampler, buf)
-	if err != nil {
-		return response{}, token, err
-	}
-
-	return p.gptProcess(content, token)
-}
-
-func (p *processor) gpt(lctx llama.Context, batch llama.Batch, sampler llama.Sampler, buf []byte) (response, llama.Token, error) {
-	content, token, err := p.model.batchResponse(lctx, batch, sampler, buf)
-	if err != nil {
-		return response{}, token, err
-	}
-
-	return p.gptProcess(content, token)
-}
-
-// gptProcess handles token content for GPT models.
-// Template format:
-//   - Reasoning: <|start|>assistant<|channel|>analysis<|message|>...content...<|end|>
-//   - Final: <|start|>assistant<|channel|>final<|message|>...content...<|return|>
-//   - Tool call: <|start|>assistant to=functions.name<|channel|>commentary json<|message|>...args...<|call|>
-func (p *processor) gptProcess(content string, token llama.Token) (response, llama.Token, error) {
-	if p.collecting {
-		if content == "<|return|>" || content == "<|call|>" {
-			p.collecting = false
-			p.status = statusNone
-			return response{}, token, io.EOF
-		}
-
-		if content == "<|end|>" {
-			p.collecting = false
-			p.status = statusNone
-			return response{}, token, nil
-		}
-
-		return response{status: p.status, content: content}, token, nil
-	}
-
-	if p.awaitingChannel {
-		p.awaitingChannel = false
-		switch content {
-		case "analysis":
-			p.status = statusReasoning
-		case "final":
-			p.status = statusCompletion
-		case "commentary":
-			p.status = statusTooling
-		}
-		return response{}, token, nil
-	}
-
-	switch content {
-	case "<|start|>":
-		p.status = statusNone
-		p.collecting = false
-		p.awaitingChannel = false
-		return response{}, token, nil
-
-	case "<|channel|>":
-		p.awaitingChannel = true
-		return response{}, token, nil
-
-	case "<|message|>":
-		p.collecting = true
-		return response{}, token, nil
-
-	case "functions":
-		p.collecting = true
-		p.status = statusTooling
-		return response{}, token, nil
-
-	default:
-		return response{}, token, nil
-	}
+	return fmt.Sprintf("%s\n", content)
 }
 
 // =============================================================================
@@ -438,17 +282,18 @@ func parseArgKeyValueFormat(content string) []ResponseToolCall {
 func (p *processor) stepStandard(content string) (response, bool) {
 	// Handle tool call accumulation mode.
 	if p.inToolCall {
-		switch content {
-		case "<tool_call>":
-			// Nested or repeated tag, skip.
+		switch {
+		case content == p.format.Start:
+			// Nested or repeated tag. Formats without an end marker repeat
+			// the start marker for every tool call.
+			if p.format.End == "" && p.toolCallBuf.Len() > 0 {
+				p.toolCallBuf.WriteString("\n")
+			}
 			return response{}, false
 
-		case "</tool_call>":
+		case p.format.End != "" && content == p.format.End:
 			// End of one tool call block. Check if we have accumulated content.
-			toolContent := strings.Trim(p.toolCallBuf.String(), "\n")
-			if toolContent != "" {
-				toolContent = fmt.Sprintf("%s\n", toolContent)
-			}
+			toolContent := toolCallContent(p.toolCallBuf.String())
 
 			p.toolCallBuf.Reset()
 
@@ -473,7 +318,7 @@ func (p *processor) stepStandard(content string) (response, bool) {
 		p.status = statusCompletion
 		return response{}, false
 
-	case "<tool_call>":
+	case p.format.Start:
 		p.status = statusTooling
 		p.inToolCall = true
 		p.toolCallBuf.Reset()
@@ -484,6 +329,16 @@ func (p *processor) stepStandard(content string) (response, bool) {
 	}
 }
 
+// flushToolCall returns the tool call content collected by stepStandard that
+// wasn't ended by an end marker. This is the case for formats that run to the
+// end of the generation.
+func (p *processor) flushToolCall() string {
+	content := toolCallContent(p.toolCallBuf.String())
+	p.toolCallBuf.Reset()
+
+	return content
+}
+
 // stepGPT processes a single token for GPT models without calling llama.
 // This is used by the batch engine where decode/sample happens externally.
 // Returns (response, endOfGeneration).
@@ -545,6 +400,46 @@ func (p *processor) stepGPT(content string) (response, bool) {
 	}
 }
 
+// endReasoning queues the tokens that end the reasoning of the model. It
+// only happens once for a request.
+func (p *processor) endReasoning(tokens []llama.Token) {
+	if p.reasoningEnded {
+		return
+	}
+
+	p.reasoningEnded = true
+	p.forced = tokens
+}
+
+// nextForced returns the next token queued by endReasoning.
+func (p *processor) nextForced() (llama.Token, bool) {
+	if len(p.forced) == 0 {
+		return 0, false
+	}
+
+	token := p.forced[0]
+	p.forced = p.forced[1:]
+
+	return token, true
+}
+
+// continueMessage sets up the processor to continue the final message of the
+// prompt, whose turn the template left open. GPT models are in the middle of
+// the final channel and standard models are reasoning when the message has an
+// open <think> tag.
+func (p *processor) continueMessage(content string) {
+	if p.model.modelInfo.IsGPTModel {
+		p.collecting = true
+		p.status = statusCompletion
+		return
+	}
+
+	p.status = statusCompletion
+	if strings.LastIndex(content, "<think>") > strings.LastIndex(content, "</think>") {
+		p.status = statusReasoning
+	}
+}
+
 // resetState resets the processor state for reuse in a new slot.
 func (p *processor) resetState() {
 	p.status = statusCompletion
@@ -552,4 +447,6 @@ func (p *processor) resetState() {
 	p.awaitingChannel = false
 	p.toolCallBuf.Reset()
 	p.inToolCall = false
+	p.forced = nil
+	p.reasoningEnded = false
 }
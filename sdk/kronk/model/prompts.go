@@ -33,6 +33,20 @@ func (m *Model) applyRequestJinjaTemplate(ctx context.Context, d D) (string, [][
 	// templates call .items() on nested structures like tool_calls arguments.
 	normalized := deepNormalize(d)
 
+	// The tool_choice decides which tools the template gets to see.
+	applyToolChoice(normalized)
+
+	// A continued assistant message is left open instead of starting a
+	// new turn for the response.
+	prefill, err := continueFinalMessage(d)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if prefill != "" {
+		normalized["add_generation_prompt"] = false
+	}
+
 	var media [][]byte
 
 	if msgs, ok := normalized["messages"].([]any); ok {
@@ -53,6 +67,12 @@ func (m *Model) applyRequestJinjaTemplate(ctx context.Context, d D) (string, [][
 		return "", nil, err
 	}
 
+	if prefill != "" {
+		if prompt, err = openFinalMessage(prompt, prefill); err != nil {
+			return "", nil, err
+		}
+	}
+
 	return prompt, media, nil
 }
 
@@ -0,0 +1,128 @@
+package model
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hybridgroup/yzma/pkg/llama"
+)
+
+const (
+	// defReasoningEnd closes the reasoning block of standard models when
+	// the template doesn't show how it's closed.
+	defReasoningEnd = "\n</think>\n\n"
+
+	// gptReasoningEnd closes the analysis channel of GPT models and opens
+	// the final channel, gptReasoningEndTooling leaves the channel to the
+	// tool call grammar.
+	gptReasoningEnd        = "<|end|><|start|>assistant<|channel|>final<|message|>"
+	gptReasoningEndTooling = "<|end|><|start|>assistant"
+)
+
+// reasoningEnd holds the tokens that close the reasoning block of the model
+// once the reasoning budget is spent. tooling is used when a tool call is
+// forced.
+type reasoningEnd struct {
+	answer  []llama.Token
+	tooling []llama.Token
+}
+
+// newReasoningEnd tokenizes the text that closes the reasoning block of the
+// model. GPT models use the channels of the harmony format, the text of other
+// models comes from their template.
+func (m *Model) newReasoningEnd(ctx context.Context) reasoningEnd {
+	if m.modelInfo.IsGPTModel {
+		return reasoningEnd{
+			answer:  llama.Tokenize(m.vocab, gptReasoningEnd, false, true),
+			tooling: llama.Tokenize(m.vocab, gptReasoningEndTooling, false, true),
+		}
+	}
+
+	text := m.templateReasoningEnd(ctx)
+	m.log(ctx, "reasoning-end", "text", text)
+
+	tokens := llama.Tokenize(m.vocab, text, false, true)
+
+	return reasoningEnd{
+		answer:  tokens,
+		tooling: tokens,
+	}
+}
+
+// templateReasoningEnd renders an assistant message with reasoning through the
+// template and returns the text written between the reasoning and the content.
+// The default is used when the template drops the reasoning or closes it in a
+// way the reasoning block of the structured output grammars doesn't accept.
+func (m *Model) templateReasoningEnd(ctx context.Context) string {
+	const (
+		reasoning = "kronk-reasoning"
+		content   = "kronk-content"
+	)
+
+	d := D{
+		"messages": []D{
+			{"role": RoleUser, "content": "kronk-question"},
+			{"role": RoleAssistant, "reasoning_content": reasoning, "content": content},
+		},
+		"add_generation_prompt": false,
+	}
+
+	prompt, err := m.applyJinjaTemplate(ctx, deepNormalize(d))
+	if err != nil {
+		return defReasoningEnd
+	}
+
+	start := strings.Index(prompt, reasoning)
+	if start < 0 {
+		return defReasoningEnd
+	}
+	start += len(reasoning)
+
+	end := strings.Index(prompt[start:], content)
+	if end < 0 {
+		return defReasoningEnd
+	}
+
+	text := prompt[start : start+end]
+
+	// The grammars accept up to 8 spaces or newlines after the end tag.
+	tag := strings.Index(text, "</think>")
+	if tag < 0 || strings.TrimSpace(text) != "</think>" {
+		return defReasoningEnd
+	}
+
+	if after := text[tag+len("</think>"):]; len(after) > 8 || strings.Trim(after, " \n") != "" {
+		return defReasoningEnd
+	}
+
+	return text
+}
+
+// reasoningEndTokens returns the tokens that close the reasoning block of the
+// model and start the answer. When a tool call is forced, GPT models are left
+// to open the commentary channel the grammar requires.
+func (m *Model) reasoningEndTokens(p params) []llama.Token {
+	if p.ToolChoice.Mode == ToolChoiceRequired {
+		return m.reasoningEnd.tooling
+	}
+
+	return m.reasoningEnd.answer
+}
+
+// countOutputToken adds a token the slot streamed to the usage of the request.
+// Tokens forced to close the reasoning count as completion tokens, so the
+// reasoning tokens never go over the reasoning budget. The close is queued
+// as soon as the budget is spent.
+func (e *batchEngine) countOutputToken(s *slot, forced bool) {
+	switch {
+	case s.reasonFlag > 0 && !forced:
+		s.reasonTokens++
+
+	default:
+		s.completionTokens++
+	}
+
+	if budget := s.job.params.MaxReasoningTokens; budget > 0 && s.reasonFlag > 0 && s.reasonTokens >= budget && !s.proc.reasoningEnded {
+		s.proc.endReasoning(e.model.reasoningEndTokens(s.job.params))
+	}
+}
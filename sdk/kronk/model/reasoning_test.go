@@ -0,0 +1,129 @@
+package model
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/hybridgroup/yzma/pkg/llama"
+)
+
+func Test_TemplateReasoningEnd(t *testing.T) {
+	template := func(reasoning string) string {
+		return `{%- for message in messages %}
+{%- if message.role == 'assistant' %}<|im_start|>assistant
+{% if message.reasoning_content %}` + reasoning + `{% endif %}{{ message.content }}<|im_end|>
+{%- else %}<|im_start|>{{ message.role }}
+{{ message.content }}<|im_end|>
+{%- endif %}
+{%- endfor %}`
+	}
+
+	tests := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{"qwen", template("<think>\n{{ message.reasoning_content }}\n</think>\n\n"), "\n</think>\n\n"},
+		{"compact", template("<think>{{ message.reasoning_content }}</think>"), "</think>"},
+		{"dropped", template(""), defReasoningEnd},
+		{"other-tag", template("[THINK]{{ message.reasoning_content }}[/THINK]"), defReasoningEnd},
+		{"text-after-tag", template("<think>{{ message.reasoning_content }}</think>Answer: "), defReasoningEnd},
+		{"invalid", "{% if %}", defReasoningEnd},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := Model{
+				log:      func(context.Context, string, ...any) {},
+				template: Template{Script: tt.script},
+			}
+
+			if got := m.templateReasoningEnd(context.Background()); got != tt.want {
+				t.Errorf("templateReasoningEnd() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_CountOutputToken(t *testing.T) {
+	m := Model{
+		reasoningEnd: reasoningEnd{
+			answer:  []llama.Token{100, 101, 102},
+			tooling: []llama.Token{200},
+		},
+	}
+
+	e := batchEngine{model: &m}
+
+	s := slot{proc: newProcessor(&m)}
+	s.job = &chatJob{params: params{MaxReasoningTokens: 3}}
+	s.reasonFlag = 1
+
+	// The close isn't queued before the budget is spent.
+	for range 2 {
+		e.countOutputToken(&s, false)
+		if _, forced := s.proc.nextForced(); forced {
+			t.Fatalf("close forced after %d reasoning tokens", s.reasonTokens)
+		}
+	}
+
+	e.countOutputToken(&s, false)
+
+	// The close comes right after the last token of the budget, in order,
+	// and is only queued once.
+	var got []llama.Token
+	for {
+		token, forced := s.proc.nextForced()
+		if !forced {
+			break
+		}
+		got = append(got, token)
+
+		e.countOutputToken(&s, true)
+	}
+
+	if want := m.reasoningEnd.answer; !slices.Equal(got, want) {
+		t.Fatalf("forced = %v, want %v", got, want)
+	}
+
+	// The answer.
+	s.reasonFlag = 0
+	for range 4 {
+		e.countOutputToken(&s, false)
+	}
+
+	if s.reasonTokens != 3 {
+		t.Errorf("reasoning tokens = %d, want 3", s.reasonTokens)
+	}
+
+	if want := len(m.reasoningEnd.answer) + 4; s.completionTokens != want {
+		t.Errorf("completion tokens = %d, want %d", s.completionTokens, want)
+	}
+
+	t.Run("tool-choice", func(t *testing.T) {
+		s := slot{proc: newProcessor(&m)}
+		s.job = &chatJob{params: params{MaxReasoningTokens: 1, ToolChoice: toolChoice{Mode: ToolChoiceRequired}}}
+		s.reasonFlag = 1
+
+		e.countOutputToken(&s, false)
+
+		if token, forced := s.proc.nextForced(); !forced || token != 200 {
+			t.Errorf("forced = %d, %v, want 200, true", token, forced)
+		}
+	})
+
+	t.Run("no-budget", func(t *testing.T) {
+		s := slot{proc: newProcessor(&m)}
+		s.job = &chatJob{}
+		s.reasonFlag = 1
+
+		for range 10 {
+			e.countOutputToken(&s, false)
+		}
+
+		if _, forced := s.proc.nextForced(); forced {
+			t.Error("close forced without a budget")
+		}
+	})
+}
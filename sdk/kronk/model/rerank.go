@@ -20,10 +20,12 @@ import (
 //   - top_n (int): return only the top N results (optional, default: all)
 //   - return_documents (bool): include document text in results (default: false)
 //
-// Each model instance processes calls sequentially (llama.cpp only supports
-// sequence 0 for rerank extraction). Use NSeqMax > 1 to create multiple
-// model instances for concurrent request handling. Batch multiple texts in the
-// input parameter for better performance within a single request.
+// The query-document pairs are packed into batches across sequences, up to
+// NSeqMax+1 pairs and NBatch tokens per forward pass. Each model instance
+// processes calls sequentially using the context of the instance. Use
+// NSeqMax > 1 to create multiple model instances for concurrent request
+// handling. Batch multiple texts in the input parameter for better performance
+// within a single request.
 func (m *Model) Rerank(ctx context.Context, d D) (RerankResponse, error) {
 	if !m.modelInfo.IsRerankModel {
 		return RerankResponse{}, fmt.Errorf("rerank: model doesn't support reranking")
@@ -71,20 +73,8 @@ func (m *Model) Rerank(ctx context.Context, d D) (RerankResponse, error) {
 
 	// -------------------------------------------------------------------------
 
-	lctx, err := llama.InitFromModel(m.model, m.ctxParams)
-	if err != nil {
-		return RerankResponse{}, fmt.Errorf("rerank: unable to init from model: %w", err)
-	}
-
-	defer func() {
-		llama.Synchronize(lctx)
-		llama.Free(lctx)
-	}()
-
-	mem, err := llama.GetMemory(lctx)
-	if err != nil {
-		return RerankResponse{}, fmt.Errorf("rerank: unable to get memory: %w", err)
-	}
+	m.pooledMu.Lock()
+	defer m.pooledMu.Unlock()
 
 	select {
 	case <-ctx.Done():
@@ -93,11 +83,7 @@ func (m *Model) Rerank(ctx context.Context, d D) (RerankResponse, error) {
 	default:
 	}
 
-	maxTokens := int(llama.NUBatch(lctx))
-	ctxTokens := int(llama.NCtx(lctx))
-	if ctxTokens < maxTokens {
-		maxTokens = ctxTokens
-	}
+	maxTokens := maxPooledTokens(m.lctx)
 
 	nClsOut := llama.ModelNClsOut(m.model)
 	if nClsOut == 0 {
@@ -106,19 +92,12 @@ func (m *Model) Rerank(ctx context.Context, d D) (RerankResponse, error) {
 
 	// -------------------------------------------------------------------------
 
-	results := make([]RerankResult, len(documents))
+	// Format the query-document pairs for the reranker model.
+	// Most reranker models expect this format or similar.
+	allTokens := make([][]llama.Token, len(documents))
 	totalPromptTokens := 0
 
 	for i, doc := range documents {
-		select {
-		case <-ctx.Done():
-			return RerankResponse{}, ctx.Err()
-
-		default:
-		}
-
-		// Format the query-document pair for the reranker model.
-		// Most reranker models expect this format or similar.
 		pairText := formatRerankPair(query, doc)
 
 		tokens := llama.Tokenize(m.vocab, pairText, true, true)
@@ -128,26 +107,17 @@ func (m *Model) Rerank(ctx context.Context, d D) (RerankResponse, error) {
 			tokens = tokens[:maxTokens]
 		}
 
+		allTokens[i] = tokens
 		totalPromptTokens += len(tokens)
+	}
 
-		batch := llama.BatchGetOne(tokens)
-
-		ret, err := llama.Decode(lctx, batch)
-		if err != nil {
-			return RerankResponse{}, fmt.Errorf("rerank: decode failed for document[%d]: %w", i, err)
-		}
-
-		if ret != 0 {
-			return RerankResponse{}, fmt.Errorf("rerank: decode returned non-zero for document[%d]: %d", i, ret)
-		}
+	// -------------------------------------------------------------------------
 
-		// Get the rank output. For reranker models with PoolingTypeRank,
-		// GetEmbeddingsSeq returns float[n_cls_out] with the relevance score(s).
-		rawScore, err := llama.GetEmbeddingsSeq(lctx, 0, int32(nClsOut))
-		if err != nil {
-			return RerankResponse{}, fmt.Errorf("rerank: unable to get score for document[%d]: %w", i, err)
-		}
+	results := make([]RerankResult, len(documents))
 
+	// Get the rank output. For reranker models with PoolingTypeRank, the
+	// pooled output is float[n_cls_out] with the relevance score(s).
+	f := func(i int, rawScore []float32) error {
 		// Apply sigmoid to normalize score to [0, 1] range.
 		var score float32
 		if len(rawScore) > 0 {
@@ -160,11 +130,14 @@ func (m *Model) Rerank(ctx context.Context, d D) (RerankResponse, error) {
 		}
 
 		if returnDocuments {
-			results[i].Document = doc
+			results[i].Document = documents[i]
 		}
 
-		// Clear KV cache before next document.
-		llama.MemoryClear(mem, true)
+		return nil
+	}
+
+	if err := m.decodePooled(ctx, allTokens, int32(nClsOut), f); err != nil {
+		return RerankResponse{}, fmt.Errorf("rerank: %w", err)
 	}
 
 	// -------------------------------------------------------------------------
@@ -0,0 +1,196 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Priority is the scheduling class of a request in the batch engine. Pending
+// requests with a higher priority are started first.
+type Priority int
+
+// Priority classes.
+const (
+	// PriorityLow is for bulk work that can wait behind interactive
+	// requests, like summarization jobs.
+	PriorityLow Priority = iota
+
+	// PriorityNormal is the default priority.
+	PriorityNormal
+
+	// PriorityHigh is for interactive requests that need the lowest
+	// latency.
+	PriorityHigh
+
+	numPriorities = int(PriorityHigh) + 1
+)
+
+// String returns the string representation of a Priority.
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityHigh:
+		return "high"
+	default:
+		return "normal"
+	}
+}
+
+// ParsePriority parses a string into a Priority.
+// Supported values: "low", "normal", "high".
+func ParsePriority(s string) (Priority, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "low":
+		return PriorityLow, nil
+
+	case "normal", "":
+		return PriorityNormal, nil
+
+	case "high":
+		return PriorityHigh, nil
+
+	default:
+		return PriorityNormal, fmt.Errorf("parse-priority: unknown priority: %s (valid: low, normal, high)", s)
+	}
+}
+
+// =============================================================================
+
+type ctxKey int
+
+const (
+	subjectKey ctxKey = iota + 1
+)
+
+// SetSubject sets the subject making the request in the context. The batch
+// engine uses it to share the slots fairly between subjects.
+func SetSubject(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, subjectKey, subject)
+}
+
+// GetSubject returns the subject from the context.
+func GetSubject(ctx context.Context) string {
+	v, ok := ctx.Value(subjectKey).(string)
+	if !ok {
+		return ""
+	}
+	return v
+}
+
+// =============================================================================
+
+// jobQueue holds the jobs waiting for a slot. Jobs are taken by priority and
+// then round-robin across subjects so a burst of requests from one subject
+// can't starve the others. It's only used by the engine goroutine.
+type jobQueue struct {
+	classes [numPriorities]subjectQueue
+	n       int
+}
+
+// subjectQueue holds the pending jobs of a priority class for each subject.
+// order holds the subjects with pending jobs in the order they are served.
+type subjectQueue struct {
+	order []string
+	jobs  map[string][]*chatJob
+}
+
+func (q *jobQueue) len() int {
+	return q.n
+}
+
+// priority returns the highest priority of the pending jobs.
+func (q *jobQueue) priority() (Priority, bool) {
+	for p := numPriorities - 1; p >= 0; p-- {
+		if len(q.classes[p].order) > 0 {
+			return Priority(p), true
+		}
+	}
+
+	return PriorityNormal, false
+}
+
+// push adds the job to the end of its subject's queue.
+func (q *jobQueue) push(job *chatJob) {
+	c := &q.classes[job.params.Priority]
+	if c.jobs == nil {
+		c.jobs = make(map[string][]*chatJob)
+	}
+
+	if len(c.jobs[job.subject]) == 0 {
+		c.order = append(c.order, job.subject)
+	}
+
+	c.jobs[job.subject] = append(c.jobs[job.subject], job)
+	q.n++
+}
+
+// pop removes and returns the next job to start. The subject it belongs to
+// moves to the back of the line for its priority class.
+func (q *jobQueue) pop() *chatJob {
+	for p := numPriorities - 1; p >= 0; p-- {
+		c := &q.classes[p]
+		if len(c.order) == 0 {
+			continue
+		}
+
+		subject := c.order[0]
+		c.order = c.order[1:]
+
+		jobs := c.jobs[subject]
+		job := jobs[0]
+		jobs[0] = nil
+
+		switch len(jobs) {
+		case 1:
+			delete(c.jobs, subject)
+		default:
+			c.jobs[subject] = jobs[1:]
+			c.order = append(c.order, subject)
+		}
+
+		q.n--
+
+		return job
+	}
+
+	return nil
+}
+
+// remove takes the jobs that match out of the queue and returns them in the
+// order they were queued for each subject.
+func (q *jobQueue) remove(match func(job *chatJob) bool) []*chatJob {
+	var removed []*chatJob
+
+	for p := range q.classes {
+		c := &q.classes[p]
+
+		order := c.order[:0]
+		for _, subject := range c.order {
+			var keep []*chatJob
+			for _, job := range c.jobs[subject] {
+				switch match(job) {
+				case true:
+					removed = append(removed, job)
+					q.n--
+
+				case false:
+					keep = append(keep, job)
+				}
+			}
+
+			if len(keep) == 0 {
+				delete(c.jobs, subject)
+				continue
+			}
+
+			c.jobs[subject] = keep
+			order = append(order, subject)
+		}
+
+		c.order = order
+	}
+
+	return removed
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"slices"
+	"testing"
+)
+
+func Test_JobQueue(t *testing.T) {
+	newJob := func(id string, subject string, priority Priority) *chatJob {
+		return &chatJob{id: id, subject: subject, params: params{Priority: priority}}
+	}
+
+	var q jobQueue
+	q.push(newJob("a1", "alice", PriorityNormal))
+	q.push(newJob("a2", "alice", PriorityNormal))
+	q.push(newJob("a3", "alice", PriorityNormal))
+	q.push(newJob("b1", "bob", PriorityNormal))
+	q.push(newJob("l1", "bob", PriorityLow))
+	q.push(newJob("c1", "carol", PriorityNormal))
+	q.push(newJob("h1", "dave", PriorityHigh))
+
+	if q.len() != 7 {
+		t.Fatalf("len = %d, want 7", q.len())
+	}
+
+	if p, ok := q.priority(); !ok || p != PriorityHigh {
+		t.Errorf("priority = %v, %v, want %v, true", p, ok, PriorityHigh)
+	}
+
+	var got []string
+	for job := q.pop(); job != nil; job = q.pop() {
+		got = append(got, job.id)
+	}
+
+	want := []string{"h1", "a1", "b1", "c1", "a2", "a3", "l1"}
+	if !slices.Equal(got, want) {
+		t.Errorf("order = %v, want %v", got, want)
+	}
+
+	if q.len() != 0 {
+		t.Errorf("len = %d, want 0", q.len())
+	}
+
+	if _, ok := q.priority(); ok {
+		t.Error("priority of an empty queue should not be ok")
+	}
+}
+
+func Test_ParsePriority(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Priority
+		wantErr bool
+	}{
+		{"", PriorityNormal, false},
+		{"low", PriorityLow, false},
+		{"normal", PriorityNormal, false},
+		{"High", PriorityHigh, false},
+		{"urgent", PriorityNormal, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParsePriority(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
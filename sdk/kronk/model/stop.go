@@ -0,0 +1,126 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxStopSequences is the largest number of stop sequences a request can
+// provide.
+const maxStopSequences = 4
+
+// stopMatcher looks for stop sequences in streamed content. Content that
+// could be the start of a stop sequence is held back until the following
+// tokens show whether it's part of a match, so a stop sequence split across
+// tokens is never streamed to the client.
+type stopMatcher struct {
+	stops   []string
+	pending string
+}
+
+// newStopMatcher returns a matcher for the stop sequences or nil when there
+// are none. A nil matcher passes all content through.
+func newStopMatcher(stops []string) *stopMatcher {
+	if len(stops) == 0 {
+		return nil
+	}
+
+	return &stopMatcher{
+		stops: stops,
+	}
+}
+
+// process adds the content to what is being held back and returns the
+// content that is safe to stream. When a stop sequence is found, stopped is
+// true and the stop sequence and anything after it are dropped.
+func (sm *stopMatcher) process(content string) (string, bool) {
+	if sm == nil {
+		return content, false
+	}
+
+	text := sm.pending + content
+
+	// Find the earliest stop sequence in the text.
+	idx := -1
+	for _, stop := range sm.stops {
+		if i := strings.Index(text, stop); i >= 0 && (idx < 0 || i < idx) {
+			idx = i
+		}
+	}
+
+	if idx >= 0 {
+		sm.pending = ""
+		return text[:idx], true
+	}
+
+	// Hold back the longest tail of the text that is the start of a stop
+	// sequence.
+	var hold int
+	for _, stop := range sm.stops {
+		for n := min(len(stop)-1, len(text)); n > hold; n-- {
+			if strings.HasSuffix(text, stop[:n]) {
+				hold = n
+				break
+			}
+		}
+	}
+
+	sm.pending = text[len(text)-hold:]
+
+	return text[:len(text)-hold], false
+}
+
+// flush returns the content being held back when generation ends without a
+// stop sequence.
+func (sm *stopMatcher) flush() string {
+	if sm == nil {
+		return ""
+	}
+
+	text := sm.pending
+	sm.pending = ""
+
+	return text
+}
+
+// =============================================================================
+
+func parseStop(fieldName string, val any) ([]string, error) {
+	var stops []string
+
+	switch v := val.(type) {
+	case nil:
+		return nil, nil
+
+	case string:
+		stops = []string{v}
+
+	case []string:
+		stops = v
+
+	case []any:
+		for _, s := range v {
+			str, ok := s.(string)
+			if !ok {
+				return nil, fmt.Errorf("parse-stop: field-name[%s] must only contain strings", fieldName)
+			}
+			stops = append(stops, str)
+		}
+
+	default:
+		return nil, fmt.Errorf("parse-stop: field-name[%s] is not a valid type", fieldName)
+	}
+
+	var result []string
+	for _, s := range stops {
+		if s != "" {
+			result = append(result, s)
+		}
+	}
+
+	if len(result) > maxStopSequences {
+		return nil, fmt.Errorf("parse-stop: field-name[%s] has %d sequences, the max is %d", fieldName, len(result), maxStopSequences)
+	}
+
+	return result, nil
+}
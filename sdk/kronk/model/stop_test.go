@@ -0,0 +1,75 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_StopMatcher(t *testing.T) {
+	tests := []struct {
+		name    string
+		stops   []string
+		tokens  []string
+		want    string
+		stopped bool
+	}{
+		{"no-stops", nil, []string{"Hello", " world"}, "Hello world", false},
+		{"no-match", []string{"END"}, []string{"Hello", " world"}, "Hello world", false},
+		{"single-token", []string{"END"}, []string{"Hello", "END", "more"}, "Hello", true},
+		{"split-across-tokens", []string{"END"}, []string{"Hello E", "N", "D after"}, "Hello ", true},
+		{"false-start", []string{"END"}, []string{"Hello E", "NOUGH"}, "Hello ENOUGH", false},
+		{"held-at-end", []string{"END"}, []string{"Hello E", "N"}, "Hello EN", false},
+		{"earliest-wins", []string{"world", "lo"}, []string{"Hello world"}, "Hel", true},
+		{"multi-stop-hold", []string{"\n\n", "###"}, []string{"a\n", "#", "#", "#b"}, "a\n", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := newStopMatcher(tt.stops)
+
+			var b strings.Builder
+			var stopped bool
+
+			for _, tok := range tt.tokens {
+				var out string
+				out, stopped = sm.process(tok)
+				b.WriteString(out)
+
+				if stopped {
+					break
+				}
+			}
+
+			if !stopped {
+				b.WriteString(sm.flush())
+			}
+
+			if b.String() != tt.want {
+				t.Errorf("expected content %q, got %q", tt.want, b.String())
+			}
+
+			if stopped != tt.stopped {
+				t.Errorf("expected stopped %t, got %t", tt.stopped, stopped)
+			}
+		})
+	}
+}
+
+func Test_ParseStop(t *testing.T) {
+	stops, err := parseStop("stop", []any{"a", "", "b"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(stops) != 2 || stops[0] != "a" || stops[1] != "b" {
+		t.Errorf("expected [a b], got %v", stops)
+	}
+
+	if _, err := parseStop("stop", []any{"a", "b", "c", "d", "e"}); err == nil {
+		t.Error("expected an error for too many stop sequences")
+	}
+
+	if _, err := parseStop("stop", []any{"a", 1}); err == nil {
+		t.Error("expected an error for a non-string stop sequence")
+	}
+}
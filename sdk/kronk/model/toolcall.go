@@ -0,0 +1,304 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/hybridgroup/yzma/pkg/llama"
+)
+
+// Names of the built-in tool call formats.
+const (
+	ToolCallFormatHermes   = "hermes"
+	ToolCallFormatMistral  = "mistral"
+	ToolCallFormatLlama3   = "llama3"
+	ToolCallFormatDeepSeek = "deepseek"
+)
+
+// ToolCallFormat describes how a model family marks the tool calls in its
+// output. The Start and End markers are matched against whole tokens, so they
+// need to be special tokens in the vocab of the model. When End is empty the
+// tool calls run to the end of the generation. Content of several tool calls
+// is passed to Parse separated by a newline.
+//
+// The harmony format of gpt-oss models is handled separately and isn't a
+// registered format.
+type ToolCallFormat struct {
+	Name  string
+	Start string
+	End   string
+	Parse func(content string) []ResponseToolCall
+}
+
+var toolCallFormats = struct {
+	mu      sync.RWMutex
+	formats []ToolCallFormat
+}{
+	formats: []ToolCallFormat{
+		{
+			// <tool_call>{"name":"get_weather","arguments":{"location":"NYC"}}</tool_call>
+			Name:  ToolCallFormatHermes,
+			Start: "<tool_call>",
+			End:   "</tool_call>",
+			Parse: parseToolCall,
+		},
+		{
+			// [TOOL_CALLS][{"name":"get_weather","arguments":{"location":"NYC"}}]
+			// [TOOL_CALLS]get_weather[ARGS]{"location":"NYC"}
+			Name:  ToolCallFormatMistral,
+			Start: "[TOOL_CALLS]",
+			Parse: parseMistralToolCall,
+		},
+		{
+			// <|python_tag|>{"name":"get_weather","parameters":{"location":"NYC"}}<|eom_id|>
+			// The <|eom_id|> token ends the generation.
+			Name:  ToolCallFormatLlama3,
+			Start: "<|python_tag|>",
+			Parse: parseJSONToolCalls,
+		},
+		{
+			// <｜tool▁calls▁begin｜><｜tool▁call▁begin｜>get_weather<｜tool▁sep｜>{"location":"NYC"}<｜tool▁call▁end｜><｜tool▁calls▁end｜>
+			Name:  ToolCallFormatDeepSeek,
+			Start: "<｜tool▁calls▁begin｜>",
+			End:   "<｜tool▁calls▁end｜>",
+			Parse: parseDeepSeekToolCall,
+		},
+	},
+}
+
+// RegisterToolCallFormat adds a tool call format that can be selected with
+// the ToolCallFormat config setting. Formats registered later are preferred
+// when the format is detected, and registering a format with the name of an
+// existing one replaces it. Formats need to be registered before the model
+// is loaded.
+func RegisterToolCallFormat(format ToolCallFormat) error {
+	switch {
+	case format.Name == "":
+		return fmt.Errorf("register-tool-call-format: name is required")
+
+	case format.Start == "":
+		return fmt.Errorf("register-tool-call-format: format[%s]: start marker is required", format.Name)
+
+	case format.Parse == nil:
+		return fmt.Errorf("register-tool-call-format: format[%s]: parse function is required", format.Name)
+	}
+
+	toolCallFormats.mu.Lock()
+	defer toolCallFormats.mu.Unlock()
+
+	for i, f := range toolCallFormats.formats {
+		if f.Name == format.Name {
+			toolCallFormats.formats[i] = format
+			return nil
+		}
+	}
+
+	toolCallFormats.formats = append(toolCallFormats.formats, format)
+
+	return nil
+}
+
+// LookupToolCallFormat returns the registered tool call format by name.
+func LookupToolCallFormat(name string) (ToolCallFormat, bool) {
+	toolCallFormats.mu.RLock()
+	defer toolCallFormats.mu.RUnlock()
+
+	for _, f := range toolCallFormats.formats {
+		if f.Name == name {
+			return f, true
+		}
+	}
+
+	return ToolCallFormat{}, false
+}
+
+// detectToolCallFormat selects the tool call format whose start marker is
+// used by the chat template, or else is a special token in the vocab. The
+// hermes format is used when no marker is found.
+func detectToolCallFormat(vocab llama.Vocab, template string) ToolCallFormat {
+	toolCallFormats.mu.RLock()
+	formats := make([]ToolCallFormat, len(toolCallFormats.formats))
+	copy(formats, toolCallFormats.formats)
+	toolCallFormats.mu.RUnlock()
+
+	for i := len(formats) - 1; i >= 0; i-- {
+		if strings.Contains(template, formats[i].Start) {
+			return formats[i]
+		}
+	}
+
+	for i := len(formats) - 1; i >= 0; i-- {
+		if hasSpecialToken(vocab, formats[i].Start) {
+			return formats[i]
+		}
+	}
+
+	f, _ := LookupToolCallFormat(ToolCallFormatHermes)
+
+	return f
+}
+
+// =============================================================================
+
+// jsonToolCall is a tool call in the JSON formats. Llama models use
+// parameters instead of arguments for the function arguments.
+type jsonToolCall struct {
+	Name       string          `json:"name"`
+	Arguments  json.RawMessage `json:"arguments"`
+	Parameters json.RawMessage `json:"parameters"`
+}
+
+// parseJSONToolCalls parses a sequence of JSON tool calls, which can be
+// separated by whitespace or semicolons and can be wrapped in arrays.
+func parseJSONToolCalls(content string) []ResponseToolCall {
+	var toolCalls []ResponseToolCall
+
+	rest := content
+	for {
+		rest = strings.TrimLeft(rest, " \t\r\n;")
+		if rest == "" {
+			break
+		}
+
+		dec := json.NewDecoder(strings.NewReader(rest))
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			toolCalls = append(toolCalls, ResponseToolCall{
+				ID:     uuid.NewString(),
+				Type:   "function",
+				Raw:    rest,
+				Status: ToolCallStatusMalformed,
+				Error:  err.Error(),
+			})
+			break
+		}
+
+		rest = rest[dec.InputOffset():]
+
+		if raw[0] != '[' {
+			toolCalls = append(toolCalls, toJSONToolCall(raw))
+			continue
+		}
+
+		var calls []json.RawMessage
+		if err := json.Unmarshal(raw, &calls); err != nil {
+			toolCalls = append(toolCalls, ResponseToolCall{
+				ID:     uuid.NewString(),
+				Type:   "function",
+				Raw:    string(raw),
+				Status: ToolCallStatusMalformed,
+				Error:  err.Error(),
+			})
+			continue
+		}
+
+		for _, call := range calls {
+			toolCalls = append(toolCalls, toJSONToolCall(call))
+		}
+	}
+
+	return toolCalls
+}
+
+func toJSONToolCall(raw json.RawMessage) ResponseToolCall {
+	var call jsonToolCall
+	if err := json.Unmarshal(raw, &call); err != nil {
+		return ResponseToolCall{
+			ID:     uuid.NewString(),
+			Type:   "function",
+			Raw:    string(raw),
+			Status: ToolCallStatusMalformed,
+			Error:  err.Error(),
+		}
+	}
+
+	args := call.Arguments
+	if len(args) == 0 {
+		args = call.Parameters
+	}
+
+	return newToolCall(string(raw), call.Name, string(args))
+}
+
+// newToolCall creates a tool call from the function name and the JSON
+// arguments.
+func newToolCall(raw string, name string, args string) ResponseToolCall {
+	toolCall := ResponseToolCall{
+		ID:   uuid.NewString(),
+		Type: "function",
+		Function: ResponseToolCallFunction{
+			Name: name,
+		},
+		Raw: raw,
+	}
+
+	args = strings.TrimSpace(args)
+	if args == "" {
+		return toolCall
+	}
+
+	if err := json.Unmarshal([]byte(args), &toolCall.Function.Arguments); err != nil {
+		toolCall.Status = ToolCallStatusMalformed
+		toolCall.Error = err.Error()
+	}
+
+	return toolCall
+}
+
+// parseMistralToolCall parses the JSON array of the older Mistral models and
+// the name[ARGS]arguments format of the newer ones.
+func parseMistralToolCall(content string) []ResponseToolCall {
+	var toolCalls []ResponseToolCall
+
+	for call := range strings.SplitSeq(content, "\n") {
+		call = strings.TrimSpace(call)
+
+		switch {
+		case call == "":
+			continue
+
+		case strings.HasPrefix(call, "[") || strings.HasPrefix(call, "{"):
+			toolCalls = append(toolCalls, parseJSONToolCalls(call)...)
+
+		default:
+			name, args, _ := strings.Cut(call, "[ARGS]")
+			toolCalls = append(toolCalls, newToolCall(call, strings.TrimSpace(name), args))
+		}
+	}
+
+	return toolCalls
+}
+
+// parseDeepSeekToolCall parses the tool calls of DeepSeek models. DeepSeek V3
+// and R1 prefix the name with the call type and put the arguments in a json
+// code block, while V3.1 and later put the arguments right after the name.
+//
+//	<｜tool▁call▁begin｜>function<｜tool▁sep｜>get_weather\n```json\n{"location":"NYC"}\n```<｜tool▁call▁end｜>
+//	<｜tool▁call▁begin｜>get_weather<｜tool▁sep｜>{"location":"NYC"}<｜tool▁call▁end｜>
+func parseDeepSeekToolCall(content string) []ResponseToolCall {
+	var toolCalls []ResponseToolCall
+
+	for call := range strings.SplitSeq(content, "<｜tool▁call▁begin｜>") {
+		call, _, _ = strings.Cut(call, "<｜tool▁call▁end｜>")
+		call = strings.TrimSpace(call)
+		if call == "" {
+			continue
+		}
+
+		name, args, _ := strings.Cut(call, "<｜tool▁sep｜>")
+
+		if start := strings.Index(args, "```json"); start != -1 {
+			name, _, _ = strings.Cut(args, "\n")
+			args = strings.TrimPrefix(args[start:], "```json")
+			args, _, _ = strings.Cut(args, "```")
+		}
+
+		toolCalls = append(toolCalls, newToolCall(call, strings.TrimSpace(name), args))
+	}
+
+	return toolCalls
+}
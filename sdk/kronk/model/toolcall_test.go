@@ -0,0 +1,123 @@
+package model
+
+import (
+	"testing"
+)
+
+func Test_ToolCallFormatParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		content string
+		want    []string
+	}{
+		{
+			name:    "hermes",
+			format:  ToolCallFormatHermes,
+			content: `{"name":"get_weather","arguments":{"location":"NYC"}}`,
+			want:    []string{"get_weather"},
+		},
+		{
+			name:    "mistral-array",
+			format:  ToolCallFormatMistral,
+			content: `[{"name":"get_weather","arguments":{"location":"NYC"}},{"name":"get_time","arguments":{"location":"NYC"}}]`,
+			want:    []string{"get_weather", "get_time"},
+		},
+		{
+			name:    "mistral-args",
+			format:  ToolCallFormatMistral,
+			content: "get_weather[ARGS]{\"location\":\"NYC\"}\nget_time[ARGS]{\"location\":\"NYC\"}",
+			want:    []string{"get_weather", "get_time"},
+		},
+		{
+			name:    "llama3",
+			format:  ToolCallFormatLlama3,
+			content: `{"name":"get_weather","parameters":{"location":"NYC"}}; {"name":"get_time","parameters":{"location":"NYC"}}`,
+			want:    []string{"get_weather", "get_time"},
+		},
+		{
+			name:    "deepseek-r1",
+			format:  ToolCallFormatDeepSeek,
+			content: "<｜tool▁call▁begin｜>function<｜tool▁sep｜>get_weather\n```json\n{\"location\":\"NYC\"}\n```<｜tool▁call▁end｜>",
+			want:    []string{"get_weather"},
+		},
+		{
+			name:    "deepseek-v3.1",
+			format:  ToolCallFormatDeepSeek,
+			content: `<｜tool▁call▁begin｜>get_weather<｜tool▁sep｜>{"location":"NYC"}<｜tool▁call▁end｜><｜tool▁call▁begin｜>get_time<｜tool▁sep｜>{"location":"NYC"}<｜tool▁call▁end｜>`,
+			want:    []string{"get_weather", "get_time"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, ok := LookupToolCallFormat(tt.format)
+			if !ok {
+				t.Fatalf("format %q not registered", tt.format)
+			}
+
+			calls := format.Parse(tt.content)
+			if len(calls) != len(tt.want) {
+				t.Fatalf("got %d tool calls, want %d: %+v", len(calls), len(tt.want), calls)
+			}
+
+			for i, call := range calls {
+				if call.Status != 0 {
+					t.Errorf("call[%d]: status %d: %s", i, call.Status, call.Error)
+				}
+				if call.Function.Name != tt.want[i] {
+					t.Errorf("call[%d]: got name %q, want %q", i, call.Function.Name, tt.want[i])
+				}
+				if call.Function.Arguments["location"] != "NYC" {
+					t.Errorf("call[%d]: got arguments %v", i, call.Function.Arguments)
+				}
+			}
+		})
+	}
+}
+
+func Test_DetectToolCallFormat(t *testing.T) {
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"{{- '<tool_call>\\n' }}", ToolCallFormatHermes},
+		{"{{- '[TOOL_CALLS]' + tool_calls|tojson }}", ToolCallFormatMistral},
+		{"{{- '<|python_tag|>' + tool_call.name }}", ToolCallFormatLlama3},
+		{"{{'<｜tool▁calls▁begin｜>'}}", ToolCallFormatDeepSeek},
+		{"{{ message.content }}", ToolCallFormatHermes},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := detectToolCallFormat(0, tt.template); got.Name != tt.want {
+				t.Errorf("got %q, want %q", got.Name, tt.want)
+			}
+		})
+	}
+}
+
+func Test_RegisterToolCallFormat(t *testing.T) {
+	if err := RegisterToolCallFormat(ToolCallFormat{Name: "test-missing-parse", Start: "<call>"}); err == nil {
+		t.Fatal("expected an error for a format without a parse function")
+	}
+
+	format := ToolCallFormat{
+		Name:  "test-register",
+		Start: "<|test_call|>",
+		End:   "<|test_call_end|>",
+		Parse: parseJSONToolCalls,
+	}
+
+	if err := RegisterToolCallFormat(format); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	if _, ok := LookupToolCallFormat(format.Name); !ok {
+		t.Fatal("registered format not found")
+	}
+
+	if got := detectToolCallFormat(0, "{{ '<|test_call|>' }}"); got.Name != format.Name {
+		t.Errorf("got %q, want %q", got.Name, format.Name)
+	}
+}
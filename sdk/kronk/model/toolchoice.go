@@ -0,0 +1,285 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/hybridgroup/yzma/pkg/llama"
+)
+
+// Tool choice modes supported by the tool_choice parameter.
+const (
+	ToolChoiceAuto     = "auto"
+	ToolChoiceNone     = "none"
+	ToolChoiceRequired = "required"
+)
+
+// toolChoice is the parsed tool_choice parameter. Name is set when a specific
+// function is forced, in which case the mode is ToolChoiceRequired.
+type toolChoice struct {
+	Mode string `json:"mode"`
+	Name string `json:"name"`
+}
+
+// parseToolChoice accepts "auto", "none", "required" or a named function in
+// the chat completions form {"type": "function", "function": {"name": ...}}
+// or the flat form of the Responses API {"type": "function", "name": ...}.
+// A tool call can only be forced when the request has the tool.
+func parseToolChoice(fieldName string, val any, tools []map[string]any) (toolChoice, error) {
+	var choice toolChoice
+
+	switch v := val.(type) {
+	case nil:
+		return toolChoice{Mode: ToolChoiceAuto}, nil
+
+	case string:
+		switch v {
+		case "", ToolChoiceAuto:
+			return toolChoice{Mode: ToolChoiceAuto}, nil
+
+		case ToolChoiceNone, ToolChoiceRequired:
+			choice.Mode = v
+
+		default:
+			return toolChoice{}, fmt.Errorf("parse-tool-choice: field-name[%s] is not valid option[%s]", fieldName, v)
+		}
+
+	default:
+		nv, err := normalizeSchema(val)
+		if err != nil {
+			return toolChoice{}, fmt.Errorf("parse-tool-choice: field-name[%s] is not valid: %w", fieldName, err)
+		}
+
+		doc, ok := nv.(map[string]any)
+		if !ok {
+			return toolChoice{}, fmt.Errorf("parse-tool-choice: field-name[%s] is not a valid type", fieldName)
+		}
+
+		if typ, _ := doc["type"].(string); typ != "function" {
+			return toolChoice{}, fmt.Errorf("parse-tool-choice: field-name[%s] is not valid type[%s]", fieldName, typ)
+		}
+
+		name, _ := toolFunction(doc)
+		if name == "" {
+			return toolChoice{}, fmt.Errorf("parse-tool-choice: field-name[%s] function name is required", fieldName)
+		}
+
+		choice = toolChoice{Mode: ToolChoiceRequired, Name: name}
+	}
+
+	if choice.Mode == ToolChoiceRequired {
+		if len(tools) == 0 {
+			return toolChoice{}, fmt.Errorf("parse-tool-choice: field-name[%s] requires tools in the request", fieldName)
+		}
+
+		if choice.Name != "" && findTool(tools, choice.Name) == nil {
+			return toolChoice{}, fmt.Errorf("parse-tool-choice: field-name[%s] function[%s] is not in the tools", fieldName, choice.Name)
+		}
+	}
+
+	return choice, nil
+}
+
+// requestTools returns the tools of the request as plain maps.
+func requestTools(d map[string]any) []map[string]any {
+	val, exists := d["tools"]
+	if !exists {
+		return nil
+	}
+
+	nv, err := normalizeSchema(val)
+	if err != nil {
+		return nil
+	}
+
+	list, _ := nv.([]any)
+
+	tools := make([]map[string]any, 0, len(list))
+	for _, v := range list {
+		if tool, ok := v.(map[string]any); ok {
+			tools = append(tools, tool)
+		}
+	}
+
+	return tools
+}
+
+// toolFunction returns the name and the parameters schema of a tool in the
+// chat completions form or the flat form of the Responses API.
+func toolFunction(tool map[string]any) (string, any) {
+	if fn, ok := tool["function"].(map[string]any); ok {
+		tool = fn
+	}
+
+	name, _ := tool["name"].(string)
+
+	return name, tool["parameters"]
+}
+
+func findTool(tools []map[string]any, name string) map[string]any {
+	for _, tool := range tools {
+		if n, _ := toolFunction(tool); n == name {
+			return tool
+		}
+	}
+
+	return nil
+}
+
+// applyToolChoice removes the tools the tool choice doesn't allow from the
+// document the chat template is applied to, so the model isn't told about
+// them. The tool choice was validated with the request.
+func applyToolChoice(d map[string]any) {
+	tools := requestTools(d)
+	if len(tools) == 0 {
+		return
+	}
+
+	choice, err := parseToolChoice("tool_choice", d["tool_choice"], tools)
+	if err != nil {
+		return
+	}
+
+	switch {
+	case choice.Mode == ToolChoiceNone:
+		delete(d, "tools")
+
+	case choice.Name != "":
+		d["tools"] = []any{findTool(tools, choice.Name)}
+	}
+}
+
+// =============================================================================
+
+// toolChoiceBias bans the start marker of the tool call format when tool
+// calls are turned off, so a model that is used to calling tools can't start
+// one. GPT models get no bias since the tool call starts with plain text.
+func (m *Model) toolChoiceBias(choice toolChoice, bias map[llama.Token]float32) map[llama.Token]float32 {
+	if choice.Mode != ToolChoiceNone || m.modelInfo.IsGPTModel || m.toolFormat.Start == "" {
+		return bias
+	}
+
+	tokens := llama.Tokenize(m.vocab, m.toolFormat.Start, false, true)
+	if len(tokens) != 1 {
+		return bias
+	}
+
+	if bias == nil {
+		bias = make(map[llama.Token]float32)
+	}
+	bias[tokens[0]] = float32(math.Inf(-1))
+
+	return bias
+}
+
+// toolChoiceGrammar returns the grammar that forces the model to call the
+// tools allowed by the tool choice, with arguments that match the JSON schema
+// of the tool. The model is still allowed to reason first.
+func (m *Model) toolChoiceGrammar(fieldName string, choice toolChoice, tools []map[string]any) (string, error) {
+	if choice.Mode != ToolChoiceRequired {
+		return "", nil
+	}
+
+	rules, start, err := toolCallRules(choice, tools, m.toolFormat, m.modelInfo.IsGPTModel)
+	if err != nil {
+		return "", fmt.Errorf("tool-choice-grammar: field-name[%s] tool parameters are not supported: %w", fieldName, err)
+	}
+
+	grammar := grammarRoot + " ::= " + m.reasoningGrammar() + " " + start + "\n" + rules
+
+	if !m.validGrammar(grammar) {
+		return "", fmt.Errorf("tool-choice-grammar: field-name[%s] tools produced an invalid grammar", fieldName)
+	}
+
+	return grammar, nil
+}
+
+// toolCallRules converts the tools allowed by the tool choice into GBNF rules
+// for the tool call syntax of the model. It returns the rules and the
+// expression that matches the tool calls.
+//
+// GPT models make a single call on the commentary channel. The other formats
+// can make several calls and use the JSON body of their format, which is the
+// hermes body for registered formats.
+func toolCallRules(choice toolChoice, tools []map[string]any, format ToolCallFormat, isGPT bool) (string, string, error) {
+	c := schemaConverter{
+		rules: make(map[string]string),
+	}
+
+	var calls []string
+
+	for _, tool := range tools {
+		name, schema := toolFunction(tool)
+		if name == "" || (choice.Name != "" && name != choice.Name) {
+			continue
+		}
+
+		args := c.primitive("object")
+		if schema != nil {
+			c.root = schema
+			c.refs = make(map[string]string)
+
+			var err error
+			args, err = c.visit(schema, "args-"+name)
+			if err != nil {
+				return "", "", err
+			}
+		}
+
+		calls = append(calls, c.addRule("call-"+name, toolCallBody(format, isGPT, name, args)))
+	}
+
+	if len(calls) == 0 {
+		return "", "", fmt.Errorf("tool-call-rules: no tools to call")
+	}
+
+	call := c.addRule("call", strings.Join(calls, " | "))
+
+	var start string
+
+	switch {
+	case isGPT:
+		start = `"<|channel|>commentary to=functions." ` + call
+
+	case format.Name == ToolCallFormatDeepSeek:
+		start = gbnfLiteral(format.Start) + " " + call + "+ " + gbnfLiteral(format.End)
+
+	case format.Name == ToolCallFormatMistral:
+		start = gbnfLiteral(format.Start) + ` "[" ` + call + ` ( ", " ` + call + ` )* "]"`
+
+	case format.End == "":
+		start = gbnfLiteral(format.Start) + " " + call
+
+	default:
+		block := gbnfLiteral(format.Start) + ` "\n" ` + call + ` "\n" ` + gbnfLiteral(format.End)
+		start = block + ` ( "\n" ` + block + ` )*`
+	}
+
+	return c.String(), start, nil
+}
+
+// toolCallBody returns the expression for a call to the named tool, where
+// args is the rule matching its arguments.
+func toolCallBody(format ToolCallFormat, isGPT bool, name string, args string) string {
+	switch {
+	case isGPT:
+		// .get_weather <|constrain|>json<|message|>{"location":"NYC"}
+		return gbnfLiteral(name+" <|constrain|>json<|message|>") + " " + args
+
+	case format.Name == ToolCallFormatDeepSeek:
+		// <｜tool▁call▁begin｜>get_weather<｜tool▁sep｜>{"location":"NYC"}<｜tool▁call▁end｜>
+		return gbnfLiteral("<｜tool▁call▁begin｜>"+name+"<｜tool▁sep｜>") + " " + args + " " + gbnfLiteral("<｜tool▁call▁end｜>")
+	}
+
+	key := "arguments"
+	if format.Name == ToolCallFormatLlama3 {
+		key = "parameters"
+	}
+
+	// {"name": "get_weather", "arguments": {"location":"NYC"}}
+	nameJSON, _ := json.Marshal(name)
+
+	return gbnfLiteral(`{"name": `+string(nameJSON)+`, "`+key+`": `) + " " + args + ` "}"`
+}
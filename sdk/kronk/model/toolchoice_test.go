@@ -0,0 +1,155 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func toolChoiceTestTools() []D {
+	return []D{
+		{
+			"type": "function",
+			"function": D{
+				"name": "get_weather",
+				"parameters": D{
+					"type":       "object",
+					"properties": D{"location": D{"type": "string"}},
+					"required":   []any{"location"},
+				},
+			},
+		},
+		{
+			"type": "function",
+			"name": "get_time",
+		},
+	}
+}
+
+func Test_ParseToolChoice(t *testing.T) {
+	tools := requestTools(D{"tools": toolChoiceTestTools()})
+
+	tests := []struct {
+		name    string
+		val     any
+		tools   []map[string]any
+		want    toolChoice
+		wantErr bool
+	}{
+		{name: "default", val: nil, tools: tools, want: toolChoice{Mode: ToolChoiceAuto}},
+		{name: "none", val: "none", tools: tools, want: toolChoice{Mode: ToolChoiceNone}},
+		{name: "required", val: "required", tools: tools, want: toolChoice{Mode: ToolChoiceRequired}},
+		{name: "named", val: D{"type": "function", "function": D{"name": "get_weather"}}, tools: tools, want: toolChoice{Mode: ToolChoiceRequired, Name: "get_weather"}},
+		{name: "named-flat", val: D{"type": "function", "name": "get_time"}, tools: tools, want: toolChoice{Mode: ToolChoiceRequired, Name: "get_time"}},
+		{name: "unknown-function", val: D{"type": "function", "name": "get_news"}, tools: tools, wantErr: true},
+		{name: "required-no-tools", val: "required", wantErr: true},
+		{name: "bad-option", val: "always", tools: tools, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseToolChoice("tool_choice", tt.val, tt.tools)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ApplyToolChoice(t *testing.T) {
+	d := deepNormalize(D{"tools": toolChoiceTestTools(), "tool_choice": "none"})
+	applyToolChoice(d)
+
+	if _, exists := d["tools"]; exists {
+		t.Errorf("expected tools to be removed for none")
+	}
+
+	d = deepNormalize(D{"tools": toolChoiceTestTools(), "tool_choice": D{"type": "function", "name": "get_time"}})
+	applyToolChoice(d)
+
+	tools := requestTools(d)
+	if len(tools) != 1 || findTool(tools, "get_time") == nil {
+		t.Errorf("expected only get_time in the tools, got %v", d["tools"])
+	}
+}
+
+func Test_ToolCallRules(t *testing.T) {
+	tools := requestTools(D{"tools": toolChoiceTestTools()})
+
+	hermes, _ := LookupToolCallFormat(ToolCallFormatHermes)
+	deepseek, _ := LookupToolCallFormat(ToolCallFormatDeepSeek)
+
+	tests := []struct {
+		name      string
+		choice    toolChoice
+		format    ToolCallFormat
+		isGPT     bool
+		wantStart string
+		want      []string
+	}{
+		{
+			name:      "hermes-required",
+			choice:    toolChoice{Mode: ToolChoiceRequired},
+			format:    hermes,
+			wantStart: `"<tool_call>" "\n" call "\n" "</tool_call>" ( "\n" "<tool_call>" "\n" call "\n" "</tool_call>" )*`,
+			want: []string{
+				`call ::= call-get-weather | call-get-time`,
+				`call-get-weather ::= "{\"name\": \"get_weather\", \"arguments\": " args-get-weather "}"`,
+				`args-get-weather ::= "{" space args-get-weather-location-kv "}" space`,
+				`call-get-time ::= "{\"name\": \"get_time\", \"arguments\": " object "}"`,
+			},
+		},
+		{
+			name:      "deepseek-named",
+			choice:    toolChoice{Mode: ToolChoiceRequired, Name: "get_weather"},
+			format:    deepseek,
+			wantStart: `"<｜tool▁calls▁begin｜>" call+ "<｜tool▁calls▁end｜>"`,
+			want: []string{
+				`call ::= call-get-weather`,
+				`call-get-weather ::= "<｜tool▁call▁begin｜>get_weather<｜tool▁sep｜>" args-get-weather "<｜tool▁call▁end｜>"`,
+			},
+		},
+		{
+			name:      "gpt-named",
+			choice:    toolChoice{Mode: ToolChoiceRequired, Name: "get_weather"},
+			isGPT:     true,
+			wantStart: `"<|channel|>commentary to=functions." call`,
+			want: []string{
+				`call-get-weather ::= "get_weather <|constrain|>json<|message|>" args-get-weather`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, start, err := toolCallRules(tt.choice, tools, tt.format, tt.isGPT)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if start != tt.wantStart {
+				t.Errorf("got start %q, want %q", start, tt.wantStart)
+			}
+
+			for _, w := range tt.want {
+				if !strings.Contains(rules, w+"\n") {
+					t.Errorf("expected rule %q in grammar:\n%s", w, rules)
+				}
+			}
+
+			if tt.choice.Name != "" && strings.Contains(rules, "get_time") {
+				t.Errorf("expected only the named function in grammar:\n%s", rules)
+			}
+		})
+	}
+}
@@ -0,0 +1,342 @@
+package model
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// partialToolCall is a tool call as far as the model has written it. The args
+// are the JSON text of the arguments written so far.
+type partialToolCall struct {
+	name string
+	args string
+}
+
+// streamedToolCall is a tool call that was sent to the client with the part
+// of its arguments that was sent.
+type streamedToolCall struct {
+	id       string
+	argsSent int
+}
+
+// toolCallStream streams tool calls while the model writes them. The id and
+// name of a call are sent first, then the arguments as they grow. Tool calls
+// the scanner can't follow are sent in full once the model is done.
+type toolCallStream struct {
+	scan  func(text string) []partialToolCall
+	calls []streamedToolCall
+}
+
+func newToolCallStream(isGPT bool) toolCallStream {
+	scan := scanJSONToolCalls
+	if isGPT {
+		scan = scanGPTToolCalls
+	}
+
+	return toolCallStream{
+		scan: scan,
+	}
+}
+
+// update scans the tool call text written so far and returns the deltas that
+// weren't sent yet.
+func (ts *toolCallStream) update(text string) []ResponseToolCallDelta {
+	var deltas []ResponseToolCallDelta
+
+	for i, call := range ts.scan(text) {
+		if i == len(ts.calls) {
+			if call.name == "" {
+				break
+			}
+
+			ts.calls = append(ts.calls, streamedToolCall{id: uuid.NewString()})
+
+			deltas = append(deltas, ResponseToolCallDelta{
+				Index: i,
+				ID:    ts.calls[i].id,
+				Type:  "function",
+				Function: ResponseToolCallDeltaFunction{
+					Name: call.name,
+				},
+			})
+		}
+
+		if sent := ts.calls[i].argsSent; len(call.args) > sent {
+			deltas = appendToolCallArgs(deltas, i, call.args[sent:])
+			ts.calls[i].argsSent = len(call.args)
+		}
+	}
+
+	return deltas
+}
+
+// finish returns the deltas for what's left of the tool calls once they are
+// parsed. The parsed tool calls get the ids that were streamed so the final
+// response matches the deltas.
+func (ts *toolCallStream) finish(text string, toolCalls []ResponseToolCall) []ResponseToolCallDelta {
+	deltas := ts.update(text)
+
+	for i := range toolCalls {
+		toolCalls[i].Index = i
+
+		switch {
+		case i < len(ts.calls):
+			toolCalls[i].ID = ts.calls[i].id
+			if ts.calls[i].argsSent == 0 {
+				deltas = appendToolCallArgs(deltas, i, toolCallArgs(toolCalls[i]))
+			}
+
+		default:
+			deltas = append(deltas, ResponseToolCallDelta{
+				Index: i,
+				ID:    toolCalls[i].ID,
+				Type:  "function",
+				Function: ResponseToolCallDeltaFunction{
+					Name:      toolCalls[i].Function.Name,
+					Arguments: toolCallArgs(toolCalls[i]),
+				},
+			})
+		}
+	}
+
+	return deltas
+}
+
+// appendToolCallArgs adds the arguments to the delta of the tool call when
+// it's the last one, otherwise a new delta is added.
+func appendToolCallArgs(deltas []ResponseToolCallDelta, index int, args string) []ResponseToolCallDelta {
+	if args == "" {
+		return deltas
+	}
+
+	if n := len(deltas); n > 0 && deltas[n-1].Index == index {
+		deltas[n-1].Function.Arguments += args
+		return deltas
+	}
+
+	return append(deltas, ResponseToolCallDelta{
+		Index: index,
+		Function: ResponseToolCallDeltaFunction{
+			Arguments: args,
+		},
+	})
+}
+
+// toolCallArgs returns the arguments of a parsed tool call as JSON text.
+func toolCallArgs(toolCall ResponseToolCall) string {
+	if toolCall.Function.Arguments == nil {
+		return ""
+	}
+
+	data, err := json.Marshal(map[string]any(toolCall.Function.Arguments))
+	if err != nil {
+		return ""
+	}
+
+	return string(data)
+}
+
+// =============================================================================
+
+// scanJSONToolCalls follows tool calls written as JSON objects with a name
+// and the arguments or parameters. The objects can be wrapped in an array and
+// separated by whitespace, commas or semicolons. Text that doesn't start with
+// a JSON object isn't followed.
+func scanJSONToolCalls(text string) []partialToolCall {
+	var calls []partialToolCall
+
+	pos := 0
+	for {
+		pos = skipJSONSeparators(text, pos)
+		if pos >= len(text) || text[pos] != '{' {
+			return calls
+		}
+
+		call, end, complete := scanJSONToolCall(text, pos)
+		if call.name != "" {
+			calls = append(calls, call)
+		}
+
+		if !complete {
+			return calls
+		}
+
+		pos = end
+	}
+}
+
+// scanJSONToolCall scans the tool call object that starts at pos. It returns
+// the position after the object and if the object is complete.
+func scanJSONToolCall(text string, pos int) (partialToolCall, int, bool) {
+	var call partialToolCall
+
+	pos++
+	for {
+		pos = skipJSONSeparators(text, pos)
+		if pos >= len(text) {
+			return call, pos, false
+		}
+
+		if text[pos] == '}' {
+			return call, pos + 1, true
+		}
+
+		if text[pos] != '"' {
+			return call, pos, false
+		}
+
+		keyEnd, complete := scanJSONValue(text, pos)
+		if !complete {
+			return call, keyEnd, false
+		}
+
+		key := text[pos+1 : keyEnd-1]
+
+		pos = skipJSONSeparators(text, keyEnd)
+		if pos >= len(text) || text[pos] != ':' {
+			return call, pos, false
+		}
+
+		pos = skipJSONSeparators(text, pos+1)
+		if pos >= len(text) {
+			return call, pos, false
+		}
+
+		end, complete := scanJSONValue(text, pos)
+
+		switch key {
+		case "name":
+			if complete {
+				json.Unmarshal([]byte(text[pos:end]), &call.name)
+			}
+
+		case "arguments", "parameters":
+			switch {
+			case text[pos] == '"':
+				// Arguments encoded as a string are only sent once the
+				// string is complete.
+				if complete {
+					json.Unmarshal([]byte(text[pos:end]), &call.args)
+				}
+
+			default:
+				call.args = text[pos:end]
+			}
+		}
+
+		if !complete {
+			return call, end, false
+		}
+
+		pos = end
+	}
+}
+
+// scanJSONValue returns the position after the JSON value that starts at pos
+// and if the value is complete.
+func scanJSONValue(text string, pos int) (int, bool) {
+	switch text[pos] {
+	case '"':
+		for i := pos + 1; i < len(text); i++ {
+			switch text[i] {
+			case '\\':
+				i++
+
+			case '"':
+				return i + 1, true
+			}
+		}
+
+		return len(text), false
+
+	case '{', '[':
+		var depth int
+		var inString bool
+
+		for i := pos; i < len(text); i++ {
+			c := text[i]
+
+			if inString {
+				switch c {
+				case '\\':
+					i++
+
+				case '"':
+					inString = false
+				}
+				continue
+			}
+
+			switch c {
+			case '"':
+				inString = true
+
+			case '{', '[':
+				depth++
+
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return i + 1, true
+				}
+			}
+		}
+
+		return len(text), false
+
+	default:
+		if i := strings.IndexAny(text[pos:], ",}] \t\r\n"); i != -1 {
+			return pos + i, true
+		}
+
+		return len(text), false
+	}
+}
+
+// skipJSONSeparators skips the characters between tool call objects and
+// between the members of an object.
+func skipJSONSeparators(text string, pos int) int {
+	for pos < len(text) {
+		switch text[pos] {
+		case ' ', '\t', '\r', '\n', ',', ';', '[', ']':
+			pos++
+
+		default:
+			return pos
+		}
+	}
+
+	return pos
+}
+
+// scanGPTToolCalls follows the tool calls of GPT models, one per line.
+// Format: .get_weather <|constrain|>json<|message|>{"location":"NYC"}
+func scanGPTToolCalls(text string) []partialToolCall {
+	var calls []partialToolCall
+
+	for line := range strings.SplitSeq(text, "\n") {
+		if line == "" {
+			continue
+		}
+
+		// The name is complete once something follows it.
+		i := strings.IndexAny(line, " <")
+		if i == -1 {
+			break
+		}
+
+		call := partialToolCall{
+			name: strings.TrimPrefix(line[:i], "."),
+		}
+
+		if idx := strings.Index(line, "<|message|>"); idx != -1 {
+			call.args = line[idx+11:]
+		}
+
+		calls = append(calls, call)
+	}
+
+	return calls
+}
@@ -0,0 +1,102 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_ToolCallStream(t *testing.T) {
+	tests := []struct {
+		name   string
+		isGPT  bool
+		format string
+		text   string
+		want   [][2]string
+	}{
+		{
+			name:   "hermes",
+			format: ToolCallFormatHermes,
+			text:   "{\"name\": \"get_weather\", \"arguments\": {\"location\": \"NYC\"}}\n{\"name\": \"get_time\", \"arguments\": {\"zone\": \"EST\"}}\n",
+			want:   [][2]string{{"get_weather", `{"location": "NYC"}`}, {"get_time", `{"zone": "EST"}`}},
+		},
+		{
+			name:   "mistral-array",
+			format: ToolCallFormatMistral,
+			text:   `[{"name":"get_weather","arguments":{"location":"NYC"}}]`,
+			want:   [][2]string{{"get_weather", `{"location":"NYC"}`}},
+		},
+		{
+			name:   "llama3-parameters",
+			format: ToolCallFormatLlama3,
+			text:   `{"name":"get_weather","parameters":{"location":"N}Y{C"}}`,
+			want:   [][2]string{{"get_weather", `{"location":"N}Y{C"}`}},
+		},
+		{
+			name:   "hermes-function-format",
+			format: ToolCallFormatHermes,
+			text:   "<function=get_weather>\n<parameter=location>\nNYC\n</parameter>\n</function>\n",
+			want:   [][2]string{{"get_weather", `{"location":"NYC"}`}},
+		},
+		{
+			name:  "gpt",
+			isGPT: true,
+			text:  `.get_weather <|constrain|>json<|message|>{"location":"NYC"}`,
+			want:  [][2]string{{"get_weather", `{"location":"NYC"}`}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newToolCallStream(tt.isGPT)
+
+			var deltas []ResponseToolCallDelta
+			for i := range tt.text {
+				deltas = append(deltas, ts.update(tt.text[:i+1])...)
+			}
+
+			content := strings.TrimSuffix(tt.text, "\n")
+
+			var toolCalls []ResponseToolCall
+			switch {
+			case tt.isGPT:
+				toolCalls = parseGPTToolCall(content)
+
+			default:
+				format, _ := LookupToolCallFormat(tt.format)
+				toolCalls = format.Parse(content)
+			}
+
+			deltas = append(deltas, ts.finish(tt.text, toolCalls)...)
+
+			ids := make(map[int]string)
+			names := make(map[int]string)
+			args := make(map[int]string)
+			for _, d := range deltas {
+				if d.ID != "" {
+					if _, exists := ids[d.Index]; exists {
+						t.Fatalf("call[%d]: id sent twice", d.Index)
+					}
+					ids[d.Index] = d.ID
+					names[d.Index] = d.Function.Name
+				}
+				args[d.Index] += d.Function.Arguments
+			}
+
+			if len(ids) != len(tt.want) {
+				t.Fatalf("got %d tool calls, want %d", len(ids), len(tt.want))
+			}
+
+			for i, want := range tt.want {
+				if names[i] != want[0] {
+					t.Errorf("call[%d]: got name %q, want %q", i, names[i], want[0])
+				}
+				if args[i] != want[1] {
+					t.Errorf("call[%d]: got arguments %q, want %q", i, args[i], want[1])
+				}
+				if toolCalls[i].ID != ids[i] || toolCalls[i].Index != i {
+					t.Errorf("call[%d]: final tool call id %q index %d doesn't match the deltas", i, toolCalls[i].ID, toolCalls[i].Index)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,336 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// validateToolCalls flags the tool calls that call a function that isn't in
+// the tools of the request or have arguments that don't match the parameters
+// schema of the tool. Calls that failed to parse keep their status. Nothing is
+// checked when the request has no tools.
+func validateToolCalls(toolCalls []ResponseToolCall, tools []map[string]any) {
+	if len(tools) == 0 {
+		return
+	}
+
+	for i := range toolCalls {
+		tc := &toolCalls[i]
+		if tc.Status != ToolCallStatusOK {
+			continue
+		}
+
+		tool := findTool(tools, tc.Function.Name)
+		if tool == nil {
+			tc.Status = ToolCallStatusInvalid
+			tc.Error = fmt.Sprintf("validate-tool-calls: function[%s] is not in the tools", tc.Function.Name)
+			continue
+		}
+
+		_, schema := toolFunction(tool)
+		if schema == nil {
+			continue
+		}
+
+		args := map[string]any(tc.Function.Arguments)
+		if args == nil {
+			args = map[string]any{}
+		}
+
+		coerceArguments(schema, args)
+
+		if err := validateSchema(schema, schema, args, "arguments"); err != nil {
+			tc.Status = ToolCallStatusInvalid
+			tc.Error = fmt.Sprintf("validate-tool-calls: function[%s]: %s", tc.Function.Name, err)
+		}
+	}
+}
+
+// coerceArguments decodes the string arguments the schema doesn't want as a
+// string. Formats like <parameter=name>value</parameter> write every value as
+// text, so a number or an object arrives as a string.
+func coerceArguments(schema any, args map[string]any) {
+	s, _ := schema.(map[string]any)
+	props, _ := s["properties"].(map[string]any)
+
+	for key, value := range args {
+		text, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		prop, _ := props[key].(map[string]any)
+
+		typ, exists := prop["type"]
+		if !exists || typ == "string" {
+			continue
+		}
+
+		if types, ok := typ.([]any); ok && slicesContainsValue(types, "string") {
+			continue
+		}
+
+		var decoded any
+		if err := json.Unmarshal([]byte(text), &decoded); err == nil {
+			args[key] = decoded
+		}
+	}
+}
+
+// invalidToolCalls returns the tool calls that aren't valid.
+func invalidToolCalls(toolCalls []ResponseToolCall) []ResponseToolCall {
+	var invalid []ResponseToolCall
+	for _, tc := range toolCalls {
+		if tc.Status != ToolCallStatusOK {
+			invalid = append(invalid, tc)
+		}
+	}
+
+	return invalid
+}
+
+// validateSchema reports the first place the value doesn't match the schema.
+// It supports the subset of JSON Schema the grammar supports, where root is
+// the schema local references are resolved against. The value needs to be
+// decoded from JSON.
+func validateSchema(root any, schema any, v any, path string) error {
+	s, ok := schema.(map[string]any)
+	if !ok {
+		if b, ok := schema.(bool); ok && !b {
+			return fmt.Errorf("path[%s]: no value is allowed", path)
+		}
+		return nil
+	}
+
+	if ref, ok := s["$ref"].(string); ok {
+		c := schemaConverter{root: root}
+
+		target, err := c.resolve(ref)
+		if err != nil {
+			return fmt.Errorf("path[%s]: %w", path, err)
+		}
+
+		return validateSchema(root, target, v, path)
+	}
+
+	if want, exists := s["const"]; exists && !reflect.DeepEqual(v, want) {
+		return fmt.Errorf("path[%s]: expected the value %v", path, want)
+	}
+
+	if values, ok := s["enum"].([]any); ok {
+		if !slicesContainsValue(values, v) {
+			return fmt.Errorf("path[%s]: value %v is not one of %v", path, v, values)
+		}
+	}
+
+	for _, key := range []string{"anyOf", "oneOf"} {
+		subs, ok := s[key].([]any)
+		if !ok {
+			continue
+		}
+
+		var firstErr error
+		for _, sub := range subs {
+			err := validateSchema(root, sub, v, path)
+			if err == nil {
+				firstErr = nil
+				break
+			}
+
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+
+		if firstErr != nil {
+			return fmt.Errorf("path[%s]: value doesn't match any schema of %s: %w", path, key, firstErr)
+		}
+	}
+
+	if subs, ok := s["allOf"].([]any); ok {
+		for _, sub := range subs {
+			if err := validateSchema(root, sub, v, path); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch t := s["type"].(type) {
+	case string:
+		if !schemaTypeMatches(t, v) {
+			return fmt.Errorf("path[%s]: expected type %s, got %s", path, t, jsonTypeName(v))
+		}
+
+	case []any:
+		var match bool
+		for _, typ := range t {
+			if name, ok := typ.(string); ok && schemaTypeMatches(name, v) {
+				match = true
+				break
+			}
+		}
+
+		if !match {
+			return fmt.Errorf("path[%s]: expected type %v, got %s", path, t, jsonTypeName(v))
+		}
+	}
+
+	switch val := v.(type) {
+	case map[string]any:
+		return validateObject(root, s, val, path)
+
+	case []any:
+		return validateArray(root, s, val, path)
+
+	case string:
+		n := utf8.RuneCountInString(val)
+
+		if minLen := schemaInt(s, "minLength"); minLen >= 0 && n < minLen {
+			return fmt.Errorf("path[%s]: expected at least %d characters, got %d", path, minLen, n)
+		}
+
+		if maxLen := schemaInt(s, "maxLength"); maxLen >= 0 && n > maxLen {
+			return fmt.Errorf("path[%s]: expected at most %d characters, got %d", path, maxLen, n)
+		}
+	}
+
+	return nil
+}
+
+func validateObject(root any, s map[string]any, v map[string]any, path string) error {
+	if reqs, ok := s["required"].([]any); ok {
+		for _, r := range reqs {
+			key, ok := r.(string)
+			if !ok {
+				continue
+			}
+
+			if _, exists := v[key]; !exists {
+				return fmt.Errorf("path[%s]: required property %q is missing", path, key)
+			}
+		}
+	}
+
+	props, _ := s["properties"].(map[string]any)
+
+	for key, value := range v {
+		if sub, exists := props[key]; exists {
+			if err := validateSchema(root, sub, value, path+"."+key); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch ap := s["additionalProperties"].(type) {
+		case bool:
+			if !ap {
+				return fmt.Errorf("path[%s]: property %q is not allowed", path, key)
+			}
+
+		case map[string]any:
+			if err := validateSchema(root, ap, value, path+"."+key); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateArray(root any, s map[string]any, v []any, path string) error {
+	if minItems := schemaInt(s, "minItems"); minItems >= 0 && len(v) < minItems {
+		return fmt.Errorf("path[%s]: expected at least %d items, got %d", path, minItems, len(v))
+	}
+
+	if maxItems := schemaInt(s, "maxItems"); maxItems >= 0 && len(v) > maxItems {
+		return fmt.Errorf("path[%s]: expected at most %d items, got %d", path, maxItems, len(v))
+	}
+
+	prefix, _ := s["prefixItems"].([]any)
+
+	for i, item := range v {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+
+		switch {
+		case i < len(prefix):
+			if err := validateSchema(root, prefix[i], item, itemPath); err != nil {
+				return err
+			}
+
+		case s["items"] != nil:
+			if err := validateSchema(root, s["items"], item, itemPath); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// schemaTypeMatches reports if the decoded JSON value is of the schema type.
+func schemaTypeMatches(typ string, v any) bool {
+	switch typ {
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+
+	case "array":
+		_, ok := v.([]any)
+		return ok
+
+	case "string":
+		_, ok := v.(string)
+		return ok
+
+	case "number":
+		_, ok := v.(float64)
+		return ok
+
+	case "integer":
+		n, ok := v.(float64)
+		return ok && n == math.Trunc(n)
+
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+
+	case "null":
+		return v == nil
+	}
+
+	return true
+}
+
+// jsonTypeName returns the JSON type of the decoded value.
+func jsonTypeName(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+
+	return strings.ToLower(fmt.Sprintf("%T", v))
+}
+
+func slicesContainsValue(values []any, v any) bool {
+	for _, value := range values {
+		if reflect.DeepEqual(value, v) {
+			return true
+		}
+	}
+
+	return false
+}
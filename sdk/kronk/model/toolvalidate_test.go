@@ -0,0 +1,149 @@
+package model
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func Test_ValidateToolCalls(t *testing.T) {
+	tools := requestTools(D{"tools": []D{
+		{
+			"type": "function",
+			"function": D{
+				"name": "get_weather",
+				"parameters": D{
+					"type": "object",
+					"properties": D{
+						"location": D{"type": "string", "minLength": 1},
+						"days":     D{"type": "integer"},
+						"unit":     D{"enum": []any{"c", "f"}},
+					},
+					"required":             []any{"location"},
+					"additionalProperties": false,
+				},
+			},
+		},
+	}})
+
+	tests := []struct {
+		name string
+		call ResponseToolCall
+		want int
+	}{
+		{
+			name: "valid",
+			call: newToolCall("", "get_weather", `{"location":"NYC","days":3,"unit":"c"}`),
+			want: ToolCallStatusOK,
+		},
+		{
+			name: "coerced",
+			call: ResponseToolCall{Function: ResponseToolCallFunction{Name: "get_weather", Arguments: ToolCallArguments{"location": "NYC", "days": "3"}}},
+			want: ToolCallStatusOK,
+		},
+		{
+			name: "missing-required",
+			call: newToolCall("", "get_weather", `{"days":3}`),
+			want: ToolCallStatusInvalid,
+		},
+		{
+			name: "wrong-type",
+			call: newToolCall("", "get_weather", `{"location":"NYC","days":1.5}`),
+			want: ToolCallStatusInvalid,
+		},
+		{
+			name: "not-in-enum",
+			call: newToolCall("", "get_weather", `{"location":"NYC","unit":"k"}`),
+			want: ToolCallStatusInvalid,
+		},
+		{
+			name: "additional-property",
+			call: newToolCall("", "get_weather", `{"location":"NYC","zip":"10001"}`),
+			want: ToolCallStatusInvalid,
+		},
+		{
+			name: "unknown-function",
+			call: newToolCall("", "get_time", `{}`),
+			want: ToolCallStatusInvalid,
+		},
+		{
+			name: "malformed",
+			call: newToolCall("", "get_weather", `{"location":`),
+			want: ToolCallStatusMalformed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := []ResponseToolCall{tt.call}
+			validateToolCalls(calls, tools)
+
+			if calls[0].Status != tt.want {
+				t.Errorf("got status %d, want %d: %s", calls[0].Status, tt.want, calls[0].Error)
+			}
+
+			if tt.want == ToolCallStatusOK && calls[0].Error != "" {
+				t.Errorf("expected no error, got: %s", calls[0].Error)
+			}
+		})
+	}
+}
+
+func Test_ToolCallRepairMessages(t *testing.T) {
+	// A template that requires the user and assistant roles to alternate
+	// like the Mistral and Gemma templates.
+	const script = `{%- for message in messages %}
+{%- if (message['role'] == 'user') != (loop.index0 % 2 == 0) %}{{ raise_exception('roles must alternate') }}{%- endif %}
+{%- if message['role'] == 'user' %}[INST] {{ message['content'] }} [/INST]
+{%- elif message['tool_calls'] %}[TOOL_CALLS][{%- for tc in message['tool_calls'] %}{"name": "{{ tc['function']['name'] }}", "arguments": {{ tc['function']['arguments'] | tojson }}}{%- endfor %}]</s>
+{%- else %}{{ message['content'] }}</s>
+{%- endif %}
+{%- endfor %}`
+
+	m := Model{
+		log:      func(context.Context, string, ...any) {},
+		template: Template{Script: script},
+	}
+
+	message := ResponseMessage{
+		Role:      RoleAssistant,
+		ToolCalls: []ResponseToolCall{newToolCall(`{"name":"get_weather","arguments":{"days":3}}`, "get_weather", `{"days":3}`)},
+	}
+	message.ToolCalls[0].Status = ToolCallStatusInvalid
+	message.ToolCalls[0].Error = "get_weather: missing required argument: location"
+
+	messages := []D{
+		{"role": RoleUser, "content": "What is the weather in NYC?"},
+		toolCallMessage(&message),
+		{"role": RoleUser, "content": toolCallRepairPrompt(message.ToolCalls)},
+	}
+
+	prompt, err := m.applyJinjaTemplate(context.Background(), deepNormalize(D{"messages": messages}))
+	if err != nil {
+		t.Fatalf("apply template: %s", err)
+	}
+
+	for _, want := range []string{`[TOOL_CALLS][{"name": "get_weather", "arguments": {"days":3}}]`, message.ToolCalls[0].Error} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt is missing %q:\n%s", want, prompt)
+		}
+	}
+
+	t.Run("without-assistant-turn", func(t *testing.T) {
+		d := D{"messages": []D{messages[0], messages[2]}}
+		if _, err := m.applyJinjaTemplate(context.Background(), deepNormalize(d)); err == nil {
+			t.Fatal("expected the template to reject two user turns in a row")
+		}
+	})
+
+	t.Run("unparsed-arguments", func(t *testing.T) {
+		call := ResponseToolCall{Raw: `{"name":"get_weather","arguments":{"location":`, Function: ResponseToolCallFunction{Name: "get_weather"}}
+
+		msg := toolCallMessage(&ResponseMessage{ToolCalls: []ResponseToolCall{call}})
+
+		tc := msg["tool_calls"].([]D)[0]
+		if args := tc["function"].(D)["arguments"]; args != call.Raw {
+			t.Errorf("arguments = %v, want %q", args, call.Raw)
+		}
+	})
+}
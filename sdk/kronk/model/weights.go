@@ -0,0 +1,49 @@
+package model
+
+import (
+	"sync/atomic"
+
+	"github.com/hybridgroup/yzma/pkg/llama"
+)
+
+// weights holds the loaded model weights so several Model instances can
+// share them. Each instance has its own context with its KV cache and compute
+// buffers, and the weights are freed once the last instance is unloaded.
+type weights struct {
+	model   llama.Model
+	mParams llama.ModelParams
+	refs    atomic.Int32
+
+	// draft holds the weights of the draft model for speculative decoding,
+	// it's zero when there is no draft model.
+	draft llama.Model
+}
+
+// acquire adds a reference for a new instance.
+func (w *weights) acquire() {
+	w.refs.Add(1)
+}
+
+// release drops the reference of an instance and frees the weights when no
+// instance is left.
+func (w *weights) release() {
+	if !w.drop() {
+		return
+	}
+
+	if w.draft != 0 {
+		llama.ModelFree(w.draft)
+	}
+	llama.ModelFree(w.model)
+}
+
+// drop removes the reference of an instance and reports if it was the last
+// one.
+func (w *weights) drop() bool {
+	return w.refs.Add(-1) == 0
+}
+
+// instances returns the number of instances sharing the weights.
+func (w *weights) instances() int {
+	return int(w.refs.Load())
+}
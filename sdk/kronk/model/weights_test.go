@@ -0,0 +1,31 @@
+package model
+
+import "testing"
+
+func Test_WeightsRefs(t *testing.T) {
+	var w weights
+
+	for i := range 3 {
+		w.acquire()
+		if got := w.instances(); got != i+1 {
+			t.Fatalf("instances = %d after %d acquires, want %d", got, i+1, i+1)
+		}
+	}
+
+	for want := 2; want > 0; want-- {
+		if w.drop() {
+			t.Fatalf("drop reported the last instance with %d left", want)
+		}
+		if got := w.instances(); got != want {
+			t.Fatalf("instances = %d, want %d", got, want)
+		}
+	}
+
+	if !w.drop() {
+		t.Fatal("drop didn't report the last instance")
+	}
+
+	if got := w.instances(); got != 0 {
+		t.Errorf("instances = %d, want 0", got)
+	}
+}
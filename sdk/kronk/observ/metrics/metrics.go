@@ -62,8 +62,18 @@ var (
 	tokensPerSecondSum, tokensPerSecondCount float64
 	tokensPerSecondMinVal                    float64 = math.MaxFloat64
 	tokensPerSecondMaxVal                    float64
+
+	draftTokensSum, draftAcceptedSum float64
+
+	queueWait = make(map[string]*queueWaitStats)
 )
 
+// queueWaitStats tracks the queue wait times of a priority class.
+type queueWaitStats struct {
+	sum, count     float64
+	minVal, maxVal float64
+}
+
 type promMetrics struct {
 	goroutines prometheus.Gauge
 	requests   prometheus.Counter
@@ -117,6 +127,19 @@ type promMetrics struct {
 	tokensPerSecondAvg prometheus.Gauge
 	tokensPerSecondMin prometheus.Gauge
 	tokensPerSecondMax prometheus.Gauge
+
+	draftTokens     prometheus.Counter
+	draftAccepted   prometheus.Counter
+	draftAcceptRate prometheus.Gauge
+
+	preemptions prometheus.Counter
+
+	decodeErrors    prometheus.Counter
+	unhealthyModels prometheus.Gauge
+
+	queueWaitAvg *prometheus.GaugeVec
+	queueWaitMin *prometheus.GaugeVec
+	queueWaitMax *prometheus.GaugeVec
 }
 
 func init() {
@@ -185,6 +208,31 @@ func init() {
 		tokensPerSecondAvg: newGauge("usage_tokens_per_second_avg", "Tokens per second average"),
 		tokensPerSecondMin: newGauge("usage_tokens_per_second_min", "Tokens per second minimum"),
 		tokensPerSecondMax: newGauge("usage_tokens_per_second_max", "Tokens per second maximum"),
+
+		draftTokens: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "speculative_draft_tokens",
+			Help: "Total number of tokens proposed by draft models",
+		}),
+		draftAccepted: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "speculative_accepted_tokens",
+			Help: "Total number of draft tokens accepted by target models",
+		}),
+		draftAcceptRate: newGauge("speculative_acceptance_rate", "Ratio of draft tokens accepted by target models"),
+
+		preemptions: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "model_preemptions",
+			Help: "Total number of requests preempted by higher priority requests",
+		}),
+
+		decodeErrors: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "model_decode_errors",
+			Help: "Total number of batches that failed to decode",
+		}),
+		unhealthyModels: newGauge("model_unhealthy", "Number of loaded models marked unhealthy after repeated decode errors"),
+
+		queueWaitAvg: newGaugeVec("model_queue_wait_avg", "Queue wait time average in seconds by priority", "priority"),
+		queueWaitMin: newGaugeVec("model_queue_wait_min", "Queue wait time minimum in seconds by priority", "priority"),
+		queueWaitMax: newGaugeVec("model_queue_wait_max", "Queue wait time maximum in seconds by priority", "priority"),
 	}
 }
 
@@ -195,6 +243,13 @@ func newGauge(name, help string) prometheus.Gauge {
 	})
 }
 
+func newGaugeVec(name, help string, labels ...string) *prometheus.GaugeVec {
+	return promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: name,
+		Help: help,
+	}, labels)
+}
+
 // AddGoroutines refreshes the goroutine metric.
 func AddGoroutines() int64 {
 	g := int64(runtime.NumGoroutine())
@@ -429,3 +484,64 @@ func AddChatCompletionsUsage(promptTokens, reasoningTokens, completionTokens, ou
 		m.tokensPerSecondMax.Set(tps)
 	}
 }
+
+// AddDraftAcceptance captures the number of tokens proposed by a draft model
+// and how many of them the target model accepted.
+func AddDraftAcceptance(drafted int, accepted int) {
+	if drafted <= 0 {
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	m.draftTokens.Add(float64(drafted))
+	m.draftAccepted.Add(float64(accepted))
+
+	draftTokensSum += float64(drafted)
+	draftAcceptedSum += float64(accepted)
+	m.draftAcceptRate.Set(draftAcceptedSum / draftTokensSum)
+}
+
+// AddPreemptions increments the preemptions metric by 1.
+func AddPreemptions() {
+	m.preemptions.Inc()
+}
+
+// AddDecodeErrors increments the decode errors metric by 1.
+func AddDecodeErrors() {
+	m.decodeErrors.Inc()
+}
+
+// AddUnhealthyModels adjusts the number of loaded models marked unhealthy.
+func AddUnhealthyModels(delta int) {
+	m.unhealthyModels.Add(float64(delta))
+}
+
+// AddQueueWaitTime captures the specified duration a request waited in the
+// queue before it was started, for its priority class.
+func AddQueueWaitTime(priority string, duration time.Duration) {
+	secs := duration.Seconds()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	qw, exists := queueWait[priority]
+	if !exists {
+		qw = &queueWaitStats{minVal: math.MaxFloat64}
+		queueWait[priority] = qw
+	}
+
+	qw.sum += secs
+	qw.count++
+	m.queueWaitAvg.WithLabelValues(priority).Set(qw.sum / qw.count)
+
+	if secs < qw.minVal {
+		qw.minVal = secs
+		m.queueWaitMin.WithLabelValues(priority).Set(secs)
+	}
+	if secs > qw.maxVal {
+		qw.maxVal = secs
+		m.queueWaitMax.WithLabelValues(priority).Set(secs)
+	}
+}
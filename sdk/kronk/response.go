@@ -34,7 +34,7 @@ type ResponseResponse struct {
 	Store            bool                   `json:"store"`
 	Temperature      float64                `json:"temperature"`
 	Text             ResponseTextFormat     `json:"text"`
-	ToolChoice       string                 `json:"tool_choice"`
+	ToolChoice       any                    `json:"tool_choice"`
 	Tools            []any                  `json:"tools"`
 	TopP             float64                `json:"top_p"`
 	Truncation       string                 `json:"truncation"`
@@ -86,9 +86,13 @@ type ResponseTextFormat struct {
 	Format ResponseFormatType `json:"format"`
 }
 
-// ResponseFormatType specifies the format type.
+// ResponseFormatType specifies the format type. Name, Schema and Strict are
+// only set for the json_schema type.
 type ResponseFormatType struct {
-	Type string `json:"type"`
+	Type   string `json:"type"`
+	Name   string `json:"name,omitempty"`
+	Schema any    `json:"schema,omitempty"`
+	Strict *bool  `json:"strict,omitempty"`
 }
 
 // ResponseUsage contains token usage information.
@@ -132,15 +136,16 @@ type ResponseStreamEvent struct {
 // =============================================================================
 
 // Response provides support to interact with an inference model.
-// For text models, NSeqMax controls parallel sequence processing within a single
-// model instance. For vision/audio models, NSeqMax creates multiple model
-// instances in a pool for concurrent request handling.
+// NSeqMax controls parallel sequence processing within a single model instance,
+// for text requests as well as requests with vision or audio content.
 func (krn *Kronk) Response(ctx context.Context, d model.D) (ResponseResponse, error) {
 	if _, exists := ctx.Deadline(); !exists {
 		return ResponseResponse{}, fmt.Errorf("response: context has no deadline, provide a reasonable timeout")
 	}
 
 	d = convertInputToMessages(d)
+	d = convertTextFormat(d)
+	d = convertTruncation(d)
 
 	f := func(m *model.Model) (model.ChatResponse, error) {
 		return m.Chat(ctx, d)
@@ -155,15 +160,16 @@ func (krn *Kronk) Response(ctx context.Context, d model.D) (ResponseResponse, er
 }
 
 // ResponseStreaming provides streaming support for the Responses API.
-// For text models, NSeqMax controls parallel sequence processing within a single
-// model instance. For vision/audio models, NSeqMax creates multiple model
-// instances in a pool for concurrent request handling.
+// NSeqMax controls parallel sequence processing within a single model instance,
+// for text requests as well as requests with vision or audio content.
 func (krn *Kronk) ResponseStreaming(ctx context.Context, d model.D) (<-chan ResponseStreamEvent, error) {
 	if _, exists := ctx.Deadline(); !exists {
 		return nil, fmt.Errorf("responses-streaming: context has no deadline, provide a reasonable timeout")
 	}
 
 	d = convertInputToMessages(d)
+	d = convertTextFormat(d)
+	d = convertTruncation(d)
 
 	f := func(m *model.Model) <-chan model.ChatResponse {
 		return m.ChatStreaming(ctx, d)
@@ -194,9 +200,8 @@ func (krn *Kronk) ResponseStreaming(ctx context.Context, d model.D) (<-chan Resp
 }
 
 // ResponseStreamingHTTP provides http handler support for a responses call.
-// For text models, NSeqMax controls parallel sequence processing within a single
-// model instance. For vision/audio models, NSeqMax creates multiple model
-// instances in a pool for concurrent request handling.
+// NSeqMax controls parallel sequence processing within a single model instance,
+// for text requests as well as requests with vision or audio content.
 func (krn *Kronk) ResponseStreamingHTTP(ctx context.Context, w http.ResponseWriter, d model.D) (ResponseResponse, error) {
 	if _, exists := ctx.Deadline(); !exists {
 		return ResponseResponse{}, fmt.Errorf("responses-streaming-http: context has no deadline, provide a reasonable timeout")
@@ -289,6 +294,7 @@ type streamState struct {
 	fcIDs           []string
 	fcArgsAccum     []string
 	toolCallsSeenID map[string]int
+	toolCallDeltaID map[int]string
 }
 
 func (ss *streamState) start() []ResponseStreamEvent {
@@ -336,6 +342,10 @@ func (ss *streamState) process(chatResp model.ChatResponse) []ResponseStreamEven
 		}
 	}
 
+	if choice.Delta != nil && len(choice.Delta.ToolCallDeltas) > 0 {
+		events = append(events, ss.handleToolCallDeltas(choice.Delta.ToolCallDeltas)...)
+	}
+
 	if choice.Message != nil && len(choice.Message.ToolCalls) > 0 {
 		events = append(events, ss.handleToolCalls(choice.Message.ToolCalls)...)
 	}
@@ -346,22 +356,28 @@ func (ss *streamState) process(chatResp model.ChatResponse) []ResponseStreamEven
 func (ss *streamState) complete(lastResp model.ChatResponse) []ResponseStreamEvent {
 	var events []ResponseStreamEvent
 
+	finalResp := toChatResponseToResponses(lastResp, ss.d)
+	finalResp.ID = ss.responseID
+	finalResp.CreatedAt = ss.createdAt
+
 	if ss.msgItemEmitted {
-		events = append(events, ss.finalizeMessageItem()...)
+		events = append(events, ss.finalizeMessageItem(finalResp.Status)...)
 	}
 
 	events = append(events, ss.finalizeToolCalls()...)
 
-	finalResp := toChatResponseToResponses(lastResp, ss.d)
-	finalResp.ID = ss.responseID
-	finalResp.CreatedAt = ss.createdAt
-
 	if len(finalResp.Output) > 0 && ss.msgItemEmitted {
 		finalResp.Output[0].ID = ss.msgID
 	}
 
+	// A response cut short by the output limit ends with its own event.
+	eventType := "response.completed"
+	if finalResp.Status == "incomplete" {
+		eventType = "response.incomplete"
+	}
+
 	events = append(events, ResponseStreamEvent{
-		Type:           "response.completed",
+		Type:           eventType,
 		SequenceNumber: ss.seq,
 		Response:       &finalResp,
 	})
@@ -381,7 +397,7 @@ func (ss *streamState) buildInProgressResponse() ResponseResponse {
 		Reasoning:        ResponseReasoning{},
 		Store:            ss.params.Store,
 		Temperature:      ss.params.Temperature,
-		Text:             ResponseTextFormat{Format: ResponseFormatType{Type: "text"}},
+		Text:             ResponseTextFormat{Format: ss.params.TextFormat},
 		ToolChoice:       ss.params.ToolChoice,
 		Tools:            ss.tools,
 		TopP:             ss.params.TopP,
@@ -461,66 +477,118 @@ func (ss *streamState) emitMessageItemAdded() []ResponseStreamEvent {
 	return events
 }
 
-func (ss *streamState) handleToolCalls(toolCalls []model.ResponseToolCall) []ResponseStreamEvent {
-	if ss.toolCallsSeenID == nil {
-		ss.toolCallsSeenID = make(map[string]int)
+// handleToolCallDeltas emits the function calls while the model writes them.
+// The first delta of a call adds its item and the deltas after it stream the
+// arguments.
+func (ss *streamState) handleToolCallDeltas(deltas []model.ResponseToolCallDelta) []ResponseStreamEvent {
+	if ss.toolCallDeltaID == nil {
+		ss.toolCallDeltaID = make(map[int]string)
 	}
 
 	var events []ResponseStreamEvent
 
-	for _, tc := range toolCalls {
-		idx, seen := ss.toolCallsSeenID[tc.ID]
-		if !seen {
-			idx = len(ss.fcItems)
-			ss.toolCallsSeenID[tc.ID] = idx
+	for _, d := range deltas {
+		if d.ID != "" {
+			ss.toolCallDeltaID[d.Index] = d.ID
+			events = append(events, ss.addFunctionCall(d.ID, d.Function.Name)...)
+		}
 
-			if ss.msgItemEmitted {
-				ss.outputIndex++
-			}
+		idx, seen := ss.toolCallsSeenID[ss.toolCallDeltaID[d.Index]]
+		if !seen || d.Function.Arguments == "" {
+			continue
+		}
 
-			fcID := fmt.Sprintf("call_%s", uuid.New().String())
-			ss.fcIDs = append(ss.fcIDs, fcID)
-			ss.fcArgsAccum = append(ss.fcArgsAccum, "")
+		events = append(events, ss.functionCallArgsDelta(idx, d.Function.Arguments))
+	}
 
-			fcItem := ResponseOutputItem{
-				Type:   "function_call",
-				ID:     fcID,
-				CallID: tc.ID,
-				Name:   tc.Function.Name,
-				Status: "in_progress",
-			}
-			ss.fcItems = append(ss.fcItems, fcItem)
-
-			outIdx := ss.outputIndex + idx
-			events = append(events, ResponseStreamEvent{
-				Type:           "response.output_item.added",
-				SequenceNumber: ss.seq,
-				OutputIndex:    &outIdx,
-				Item:           &fcItem,
-			})
-			ss.seq++
+	return events
+}
+
+// handleToolCalls emits the function calls of the final response that
+// weren't streamed.
+func (ss *streamState) handleToolCalls(toolCalls []model.ResponseToolCall) []ResponseStreamEvent {
+	var events []ResponseStreamEvent
+
+	for _, tc := range toolCalls {
+		if _, seen := ss.toolCallsSeenID[tc.ID]; !seen {
+			events = append(events, ss.addFunctionCall(tc.ID, tc.Function.Name)...)
 		}
 
-		args, _ := json.Marshal(tc.Function.Arguments)
-		argsDelta := string(args)
+		idx := ss.toolCallsSeenID[tc.ID]
+		if tc.Status != model.ToolCallStatusOK {
+			ss.fcItems[idx].Status = functionCallStatus(tc)
+		}
 
-		ss.fcArgsAccum[idx] = argsDelta
+		if ss.fcArgsAccum[idx] != "" {
+			continue
+		}
 
-		outIdx := ss.outputIndex + idx
-		events = append(events, ResponseStreamEvent{
-			Type:           "response.function_call_arguments.delta",
-			SequenceNumber: ss.seq,
-			ItemID:         ss.fcIDs[idx],
-			OutputIndex:    &outIdx,
-			Delta:          argsDelta,
-		})
-		ss.seq++
+		events = append(events, ss.functionCallArgsDelta(idx, functionArguments(tc.Function.Arguments)))
 	}
 
 	return events
 }
 
-func (ss *streamState) finalizeMessageItem() []ResponseStreamEvent {
+// addFunctionCall adds the output item of a function call.
+func (ss *streamState) addFunctionCall(callID string, name string) []ResponseStreamEvent {
+	if ss.toolCallsSeenID == nil {
+		ss.toolCallsSeenID = make(map[string]int)
+	}
+
+	if _, seen := ss.toolCallsSeenID[callID]; seen {
+		return nil
+	}
+
+	idx := len(ss.fcItems)
+	ss.toolCallsSeenID[callID] = idx
+
+	if ss.msgItemEmitted {
+		ss.outputIndex++
+	}
+
+	fcID := fmt.Sprintf("call_%s", uuid.New().String())
+	ss.fcIDs = append(ss.fcIDs, fcID)
+	ss.fcArgsAccum = append(ss.fcArgsAccum, "")
+
+	fcItem := ResponseOutputItem{
+		Type:   "function_call",
+		ID:     fcID,
+		CallID: callID,
+		Name:   name,
+		Status: "in_progress",
+	}
+	ss.fcItems = append(ss.fcItems, fcItem)
+
+	outIdx := ss.outputIndex + idx
+	event := ResponseStreamEvent{
+		Type:           "response.output_item.added",
+		SequenceNumber: ss.seq,
+		OutputIndex:    &outIdx,
+		Item:           &fcItem,
+	}
+	ss.seq++
+
+	return []ResponseStreamEvent{event}
+}
+
+// functionCallArgsDelta adds a part of the arguments of a function call.
+func (ss *streamState) functionCallArgsDelta(idx int, argsDelta string) ResponseStreamEvent {
+	ss.fcArgsAccum[idx] += argsDelta
+
+	outIdx := ss.outputIndex + idx
+	event := ResponseStreamEvent{
+		Type:           "response.function_call_arguments.delta",
+		SequenceNumber: ss.seq,
+		ItemID:         ss.fcIDs[idx],
+		OutputIndex:    &outIdx,
+		Delta:          argsDelta,
+	}
+	ss.seq++
+
+	return event
+}
+
+func (ss *streamState) finalizeMessageItem(status string) []ResponseStreamEvent {
 	events := []ResponseStreamEvent{
 		{
 			Type:           "response.output_text.done",
@@ -547,7 +615,7 @@ func (ss *streamState) finalizeMessageItem() []ResponseStreamEvent {
 	outputItem := ResponseOutputItem{
 		Type:   "message",
 		ID:     ss.msgID,
-		Status: "completed",
+		Status: status,
 		Role:   model.RoleAssistant,
 		Content: []ResponseContentItem{
 			{Type: "output_text", Text: ss.fullText, Annotations: []string{}},
@@ -583,7 +651,9 @@ func (ss *streamState) finalizeToolCalls() []ResponseStreamEvent {
 		})
 		ss.seq++
 
-		fcItem.Status = "completed"
+		if fcItem.Status == "in_progress" {
+			fcItem.Status = "completed"
+		}
 		fcItem.Arguments = ss.fcArgsAccum[i]
 		events = append(events, ResponseStreamEvent{
 			Type:           "response.output_item.done",
@@ -625,13 +695,22 @@ func toChatResponseToResponses(chatResp model.ChatResponse, d model.D) ResponseR
 
 	status := "completed"
 	var respError *ResponseError
-	if finishReason == model.FinishReasonError {
+	var incompleteDetail *IncompleteDetail
+
+	switch finishReason {
+	case model.FinishReasonError:
 		status = "failed"
 		respError = &ResponseError{
 			Code:    "error",
 			Message: outputText,
 		}
 		outputText = ""
+
+	case model.FinishReasonLength:
+		status = "incomplete"
+		incompleteDetail = &IncompleteDetail{
+			Reason: "max_output_tokens",
+		}
 	}
 
 	var completedAt *int64
@@ -656,7 +735,7 @@ func toChatResponseToResponses(chatResp model.ChatResponse, d model.D) ResponseR
 		Status:           status,
 		CompletedAt:      completedAt,
 		Error:            respError,
-		IncompleteDetail: nil,
+		IncompleteDetail: incompleteDetail,
 		Instructions:     inputParams.Instructions,
 		MaxOutputTokens:  inputParams.MaxOutputTokens,
 		Model:            chatResp.Model,
@@ -670,9 +749,7 @@ func toChatResponseToResponses(chatResp model.ChatResponse, d model.D) ResponseR
 		Store:       inputParams.Store,
 		Temperature: inputParams.Temperature,
 		Text: ResponseTextFormat{
-			Format: ResponseFormatType{
-				Type: "text",
-			},
+			Format: inputParams.TextFormat,
 		},
 		ToolChoice: inputParams.ToolChoice,
 		Tools:      tools,
@@ -681,7 +758,7 @@ func toChatResponseToResponses(chatResp model.ChatResponse, d model.D) ResponseR
 		Usage: ResponseUsage{
 			InputTokens: chatResp.Usage.PromptTokens,
 			InputTokensDetails: InputTokensDetails{
-				CachedTokens: 0,
+				CachedTokens: chatResp.Usage.CachedTokens,
 			},
 			OutputTokens: chatResp.Usage.CompletionTokens,
 			OutputTokenDetail: OutputTokensDetails{
@@ -694,20 +771,44 @@ func toChatResponseToResponses(chatResp model.ChatResponse, d model.D) ResponseR
 	}
 }
 
+// functionArguments returns the arguments of a function call as JSON text,
+// the way the streamed argument deltas add up.
+func functionArguments(args model.ToolCallArguments) string {
+	if args == nil {
+		return ""
+	}
+
+	data, err := json.Marshal(map[string]any(args))
+	if err != nil {
+		return ""
+	}
+
+	return string(data)
+}
+
+// functionCallStatus returns the status of the output item of a function
+// call. Calls that failed to parse or don't match the parameters schema of
+// the tool are incomplete.
+func functionCallStatus(tc model.ResponseToolCall) string {
+	if tc.Status != model.ToolCallStatusOK {
+		return "incomplete"
+	}
+
+	return "completed"
+}
+
 func buildOutputItems(outputText string, toolCalls []model.ResponseToolCall, status string) []ResponseOutputItem {
 	var outputItems []ResponseOutputItem
 
 	if len(toolCalls) > 0 {
 		for _, tc := range toolCalls {
-			args, _ := json.Marshal(tc.Function.Arguments)
-
 			outputItems = append(outputItems, ResponseOutputItem{
 				Type:      "function_call",
 				ID:        fmt.Sprintf("call_%s", uuid.New().String()),
 				CallID:    tc.ID,
 				Name:      tc.Function.Name,
-				Arguments: string(args),
-				Status:    "completed",
+				Arguments: functionArguments(tc.Function.Arguments),
+				Status:    functionCallStatus(tc),
 			})
 		}
 
@@ -718,7 +819,7 @@ func buildOutputItems(outputText string, toolCalls []model.ResponseToolCall, sta
 		outputItems = append(outputItems, ResponseOutputItem{
 			Type:   "message",
 			ID:     "msg_" + uuid.New().String(),
-			Status: "completed",
+			Status: status,
 			Role:   model.RoleAssistant,
 			Content: []ResponseContentItem{
 				{
@@ -738,12 +839,13 @@ func buildOutputItems(outputText string, toolCalls []model.ResponseToolCall, sta
 type inputParams struct {
 	Temperature       float64
 	TopP              float64
-	ToolChoice        string
+	ToolChoice        any
 	Truncation        string
 	MaxOutputTokens   *int
 	ParallelToolCalls bool
 	Store             bool
 	Instructions      *string
+	TextFormat        ResponseFormatType
 }
 
 func extractInputParams(d model.D) inputParams {
@@ -754,6 +856,7 @@ func extractInputParams(d model.D) inputParams {
 		Truncation:        "disabled",
 		ParallelToolCalls: true,
 		Store:             true,
+		TextFormat:        ResponseFormatType{Type: model.ResponseFormatText},
 	}
 
 	if v, ok := d["temperature"].(float64); ok {
@@ -764,7 +867,7 @@ func extractInputParams(d model.D) inputParams {
 		params.TopP = v
 	}
 
-	if v, ok := d["tool_choice"].(string); ok {
+	if v, exists := d["tool_choice"]; exists && v != nil {
 		params.ToolChoice = v
 	}
 
@@ -788,6 +891,16 @@ func extractInputParams(d model.D) inputParams {
 		params.Instructions = &v
 	}
 
+	if format, ok := textFormat(d); ok {
+		params.TextFormat.Type, _ = format["type"].(string)
+		params.TextFormat.Name, _ = format["name"].(string)
+		params.TextFormat.Schema = format["schema"]
+
+		if v, ok := format["strict"].(bool); ok {
+			params.TextFormat.Strict = &v
+		}
+	}
+
 	return params
 }
 
@@ -826,6 +939,74 @@ func convertInputToMessages(d model.D) model.D {
 	return d
 }
 
+// convertTextFormat maps the Responses API text.format field onto the chat
+// response_format field so the output is constrained the same way.
+func convertTextFormat(d model.D) model.D {
+	if _, hasFormat := d["response_format"]; hasFormat {
+		return d
+	}
+
+	format, ok := textFormat(d)
+	if !ok {
+		return d
+	}
+
+	typ, _ := format["type"].(string)
+
+	switch typ {
+	case model.ResponseFormatJSONSchema:
+		d["response_format"] = model.D{
+			"type": typ,
+			"json_schema": model.D{
+				"name":   format["name"],
+				"schema": format["schema"],
+				"strict": format["strict"],
+			},
+		}
+
+	default:
+		d["response_format"] = model.D{"type": typ}
+	}
+
+	return d
+}
+
+// convertTruncation maps the Responses API truncation field onto the chat
+// context_overflow field. With auto, the oldest turns of the conversation are
+// dropped when the input doesn't fit in the context window.
+func convertTruncation(d model.D) model.D {
+	if _, hasOverflow := d["context_overflow"]; hasOverflow {
+		return d
+	}
+
+	if truncation, _ := d["truncation"].(string); truncation == "auto" {
+		d["context_overflow"] = model.ContextOverflowTruncateMiddle.String()
+	}
+
+	return d
+}
+
+// textFormat returns the text.format object from a Responses API request.
+func textFormat(d model.D) (map[string]any, bool) {
+	text, ok := asMap(d["text"])
+	if !ok {
+		return nil, false
+	}
+
+	return asMap(text["format"])
+}
+
+func asMap(v any) (map[string]any, bool) {
+	switch m := v.(type) {
+	case model.D:
+		return m, true
+	case map[string]any:
+		return m, true
+	}
+
+	return nil, false
+}
+
 func inputToMessages(input any) []model.D {
 	inputItems, ok := input.([]any)
 	if !ok {
@@ -3,6 +3,7 @@ package kronk_test
 import (
 	"context"
 	"fmt"
+	"math"
 	"testing"
 	"time"
 
@@ -86,3 +87,58 @@ func testEmbedding(t *testing.T, krn *kronk.Kronk) {
 		t.Errorf("error: %v", err)
 	}
 }
+
+// testEmbeddingOrder checks the inputs packed into batches across sequences
+// get the same embeddings, in input order, as the inputs embedded one by one.
+func testEmbeddingOrder(t *testing.T, krn *kronk.Kronk) {
+	ctx, cancel := context.WithTimeout(context.Background(), testDuration)
+	defer cancel()
+
+	// More inputs than sequences so they span several batches.
+	inputs := []string{
+		"The quick brown fox jumps over the lazy dog",
+		"Go",
+		"Machine learning is a subset of artificial intelligence and studies algorithms that learn from data",
+		"Embeddings convert text into numerical vectors",
+		"Paris",
+	}
+
+	embed, err := krn.Embeddings(ctx, model.D{"input": inputs})
+	if err != nil {
+		t.Fatalf("embed: %v", err)
+	}
+
+	if len(embed.Data) != len(inputs) {
+		t.Fatalf("unexpected data length: got %d, exp %d", len(embed.Data), len(inputs))
+	}
+
+	for i, input := range inputs {
+		single, err := krn.Embeddings(ctx, model.D{"input": input})
+		if err != nil {
+			t.Fatalf("embed input[%d]: %v", i, err)
+		}
+
+		if embed.Data[i].Index != i {
+			t.Errorf("data[%d]: unexpected index: got %d", i, embed.Data[i].Index)
+		}
+
+		if sim := cosine(embed.Data[i].Embedding, single.Data[0].Embedding); sim < 0.999 {
+			t.Errorf("data[%d]: embedding doesn't match the input embedded alone: similarity %.4f", i, sim)
+		}
+	}
+}
+
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
@@ -0,0 +1,77 @@
+package kronk_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ardanlabs/kronk/sdk/kronk"
+	"github.com/ardanlabs/kronk/sdk/kronk/model"
+	"github.com/ardanlabs/kronk/sdk/tools/templates"
+)
+
+func testInstances(t *testing.T, krn *kronk.Kronk) {
+	if got, want := krn.ModelInfo().Instances, krn.ModelConfig().NSeqMax; got != want {
+		t.Errorf("instances = %d, want one for each of the %d sequences", got, want)
+	}
+}
+
+func Test_ModelInstances(t *testing.T) {
+	ctx := context.Background()
+
+	tmpls, err := templates.New()
+	if err != nil {
+		t.Fatalf("unable to create template system: %v", err)
+	}
+
+	first, err := model.NewModel(ctx, tmpls, cfgEmbed())
+	if err != nil {
+		t.Fatalf("unable to load model %v: %v", mpEmbed.ModelFiles, err)
+	}
+
+	instances := []*model.Model{first}
+	defer func() {
+		for _, m := range instances {
+			m.Unload(ctx)
+		}
+	}()
+
+	for range 2 {
+		m, err := first.NewInstance(ctx)
+		if err != nil {
+			t.Fatalf("unable to create instance: %v", err)
+		}
+		instances = append(instances, m)
+	}
+
+	for i, m := range instances {
+		if got := m.ModelInfo().Instances; got != 3 {
+			t.Errorf("instance[%d]: instances = %d, want 3", i, got)
+		}
+	}
+
+	// The weights stay loaded for the instances that are left.
+	if err := first.Unload(ctx); err != nil {
+		t.Fatalf("unable to unload instance: %v", err)
+	}
+	instances = instances[1:]
+
+	for i, m := range instances {
+		if got := m.ModelInfo().Instances; got != 2 {
+			t.Errorf("instance[%d]: instances = %d, want 2", i, got)
+		}
+	}
+
+	if _, err := first.NewInstance(ctx); err == nil {
+		t.Error("expected an error creating an instance from an unloaded model")
+	}
+
+	m, err := instances[0].NewInstance(ctx)
+	if err != nil {
+		t.Fatalf("unable to create instance after an unload: %v", err)
+	}
+	instances = append(instances, m)
+
+	if got := m.ModelInfo().Instances; got != 3 {
+		t.Errorf("instances = %d, want 3", got)
+	}
+}
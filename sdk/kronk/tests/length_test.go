@@ -0,0 +1,96 @@
+package kronk_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ardanlabs/kronk/sdk/kronk"
+	"github.com/ardanlabs/kronk/sdk/kronk/model"
+)
+
+// testLength checks the responses of requests that run out of output tokens
+// or context window before the model is done.
+func testLength(t *testing.T, krn *kronk.Kronk) {
+	story := []model.D{{"role": "user", "content": "Write a very long story about a gorilla."}}
+
+	t.Run("chat-max-tokens", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), testDuration)
+		defer cancel()
+
+		resp, err := krn.Chat(ctx, model.D{"messages": story, "max_tokens": 16})
+		if err != nil {
+			t.Fatalf("chat: %v", err)
+		}
+
+		if got := resp.Choice[0].FinishReason(); got != model.FinishReasonLength {
+			t.Errorf("finish reason = %q, want %q", got, model.FinishReasonLength)
+		}
+	})
+
+	t.Run("chat-context-window", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), testDuration)
+		defer cancel()
+
+		// Without max_tokens the generation runs until the context window
+		// is full.
+		resp, err := krn.Chat(ctx, model.D{"messages": story})
+		if err != nil {
+			t.Fatalf("chat: %v", err)
+		}
+
+		if got := resp.Choice[0].FinishReason(); got != model.FinishReasonLength {
+			t.Errorf("finish reason = %q, want %q", got, model.FinishReasonLength)
+		}
+
+		if n := resp.Usage.PromptTokens + resp.Usage.OutputTokens; n > krn.ModelConfig().ContextWindow {
+			t.Errorf("used %d tokens, want at most the context window of %d", n, krn.ModelConfig().ContextWindow)
+		}
+	})
+
+	t.Run("response-max-tokens", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), testDuration)
+		defer cancel()
+
+		resp, err := krn.Response(ctx, model.D{"messages": story, "max_tokens": 16})
+		if err != nil {
+			t.Fatalf("response: %v", err)
+		}
+
+		if resp.Status != "incomplete" {
+			t.Errorf("status = %q, want incomplete", resp.Status)
+		}
+
+		if resp.IncompleteDetail == nil || resp.IncompleteDetail.Reason != "max_output_tokens" {
+			t.Errorf("incomplete details = %#v, want reason max_output_tokens", resp.IncompleteDetail)
+		}
+	})
+
+	t.Run("response-streaming-max-tokens", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), testDuration)
+		defer cancel()
+
+		ch, err := krn.ResponseStreaming(ctx, model.D{"messages": story, "max_tokens": 16})
+		if err != nil {
+			t.Fatalf("response streaming: %v", err)
+		}
+
+		var finalResp *kronk.ResponseResponse
+		for event := range ch {
+			switch event.Type {
+			case "response.completed":
+				t.Error("expected response.incomplete, got response.completed")
+
+			case "response.incomplete":
+				finalResp = event.Response
+			}
+		}
+
+		if finalResp == nil {
+			t.Fatal("expected response.incomplete event")
+		}
+
+		if finalResp.IncompleteDetail == nil || finalResp.IncompleteDetail.Reason != "max_output_tokens" {
+			t.Errorf("incomplete details = %#v, want reason max_output_tokens", finalResp.IncompleteDetail)
+		}
+	})
+}
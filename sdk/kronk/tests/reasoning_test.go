@@ -0,0 +1,72 @@
+package kronk_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ardanlabs/kronk/sdk/kronk"
+	"github.com/ardanlabs/kronk/sdk/kronk/model"
+)
+
+func testReasoningBudget(t *testing.T, krn *kronk.Kronk) {
+	const budget = 32
+
+	tests := []struct {
+		name string
+		d    model.D
+	}{
+		{
+			name: "text",
+			d: model.D{
+				"messages":             []model.D{{"role": "user", "content": "Echo back the word: Gorilla"}},
+				"max_tokens":           512,
+				"max_reasoning_tokens": budget,
+			},
+		},
+		{
+			name: "response-format",
+			d: model.D{
+				"messages":             []model.D{{"role": "user", "content": "Return the word Gorilla in the word field."}},
+				"max_tokens":           512,
+				"max_reasoning_tokens": budget,
+				"response_format":      model.D{"type": "json_object"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), testDuration)
+			defer cancel()
+
+			resp, err := krn.Chat(ctx, tt.d)
+			if err != nil {
+				t.Fatalf("chat: %v", err)
+			}
+
+			if resp.Choice[0].FinishReason() != model.FinishReasonStop {
+				t.Fatalf("finish reason = %q, want %q: %#v", resp.Choice[0].FinishReason(), model.FinishReasonStop, resp)
+			}
+
+			// The forced end of the reasoning counts as completion tokens.
+			if n := resp.Usage.ReasoningTokens; n == 0 || n > budget {
+				t.Errorf("reasoning tokens = %d, want 1 to %d", n, budget)
+			}
+
+			msg := resp.Choice[0].Message
+			if strings.Contains(msg.Content, "</think>") || strings.Contains(msg.Reasoning, "</think>") {
+				t.Errorf("end of the reasoning leaked into the response: %#v", msg)
+			}
+
+			if msg.Content == "" {
+				t.Fatal("expected an answer after the reasoning")
+			}
+
+			if _, ok := tt.d["response_format"]; ok && !json.Valid([]byte(msg.Content)) {
+				t.Errorf("expected a JSON document, got %q", msg.Content)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package kronk_test
 import (
 	"context"
 	"fmt"
+	"math"
 	"strings"
 	"testing"
 	"time"
@@ -117,3 +118,53 @@ func testRerank(t *testing.T, krn *kronk.Kronk) {
 		t.Errorf("error: %v", err)
 	}
 }
+
+// testRerankOrder checks the documents packed into batches across sequences
+// get the same scores, tied to their input index, as the documents scored one
+// by one.
+func testRerankOrder(t *testing.T, krn *kronk.Kronk) {
+	ctx, cancel := context.WithTimeout(context.Background(), testDuration)
+	defer cancel()
+
+	query := "What is the capital of France?"
+
+	// More documents than sequences so they span several batches.
+	documents := []string{
+		"Berlin is the capital of Germany.",
+		"Paris is the capital and largest city of France.",
+		"London is the capital of England.",
+		"France is a country in Western Europe, its capital is home to the Eiffel Tower.",
+		"Madrid is the capital of Spain.",
+	}
+
+	rerank, err := krn.Rerank(ctx, model.D{
+		"query":            query,
+		"documents":        documents,
+		"return_documents": true,
+	})
+	if err != nil {
+		t.Fatalf("rerank: %v", err)
+	}
+
+	if len(rerank.Data) != len(documents) {
+		t.Fatalf("unexpected data length: got %d, exp %d", len(rerank.Data), len(documents))
+	}
+
+	for _, result := range rerank.Data {
+		if result.Document != documents[result.Index] {
+			t.Errorf("index %d: unexpected document: got %q, exp %q", result.Index, result.Document, documents[result.Index])
+		}
+
+		single, err := krn.Rerank(ctx, model.D{
+			"query":     query,
+			"documents": []string{documents[result.Index]},
+		})
+		if err != nil {
+			t.Fatalf("rerank document[%d]: %v", result.Index, err)
+		}
+
+		if diff := math.Abs(float64(result.RelevanceScore - single.Data[0].RelevanceScore)); diff > 0.01 {
+			t.Errorf("index %d: score %.4f doesn't match the document scored alone: %.4f", result.Index, result.RelevanceScore, single.Data[0].RelevanceScore)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package kronk_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ardanlabs/kronk/sdk/kronk"
+	"github.com/ardanlabs/kronk/sdk/kronk/model"
+)
+
+func testSeed(t *testing.T, krn *kronk.Kronk) {
+	d := model.D{
+		"messages":    []model.D{{"role": "user", "content": "Write one sentence about a gorilla."}},
+		"max_tokens":  256,
+		"temperature": 1.0,
+		"seed":        1234,
+	}
+
+	chat := func() model.ChatResponse {
+		ctx, cancel := context.WithTimeout(context.Background(), testDuration)
+		defer cancel()
+
+		resp, err := krn.Chat(ctx, d)
+		if err != nil {
+			t.Fatalf("chat: %v", err)
+		}
+
+		return resp
+	}
+
+	// The requests run one after the other so they decode in the same
+	// batch layout.
+	resp1 := chat()
+	resp2 := chat()
+
+	if resp1.SystemFingerprint == "" {
+		t.Fatal("expected a system fingerprint")
+	}
+
+	if resp1.SystemFingerprint != resp2.SystemFingerprint {
+		t.Errorf("fingerprints differ: %q and %q", resp1.SystemFingerprint, resp2.SystemFingerprint)
+	}
+
+	msg1 := resp1.Choice[0].Message
+	msg2 := resp2.Choice[0].Message
+
+	if msg1.Reasoning != msg2.Reasoning || msg1.Content != msg2.Content {
+		t.Errorf("same seed produced different output:\n%#v\n%#v", msg1, msg2)
+	}
+}
@@ -26,6 +26,18 @@ func TestSuite(t *testing.T) {
 			t.Run("ThinkStreamingResponse", func(t *testing.T) { testResponseStreaming(t, krn, dResponseNoTool, false) })
 			t.Run("ToolResponse", func(t *testing.T) { testResponse(t, krn, dResponseTool, true) })
 			t.Run("ToolStreamingResponse", func(t *testing.T) { testResponseStreaming(t, krn, dResponseTool, true) })
+			t.Run("ReasoningBudget", func(t *testing.T) { testReasoningBudget(t, krn) })
+			t.Run("Seed", func(t *testing.T) { testSeed(t, krn) })
+		})
+	})
+
+	t.Run("Chat/Qwen3-SmallContext", func(t *testing.T) {
+		if runInParallel {
+			t.Parallel()
+		}
+
+		withModel(t, cfgSmallContextChat(), func(t *testing.T, krn *kronk.Kronk) {
+			t.Run("Length", func(t *testing.T) { testLength(t, krn) })
 		})
 	})
 
@@ -49,6 +61,8 @@ func TestSuite(t *testing.T) {
 
 		withModel(t, cfgEmbed(), func(t *testing.T, krn *kronk.Kronk) {
 			t.Run("Embedding", func(t *testing.T) { testEmbedding(t, krn) })
+			t.Run("EmbeddingOrder", func(t *testing.T) { testEmbeddingOrder(t, krn) })
+			t.Run("Instances", func(t *testing.T) { testInstances(t, krn) })
 		})
 	})
 
@@ -59,6 +73,8 @@ func TestSuite(t *testing.T) {
 
 		withModel(t, cfgRerank(), func(t *testing.T, krn *kronk.Kronk) {
 			t.Run("Rerank", func(t *testing.T) { testRerank(t, krn) })
+			t.Run("RerankOrder", func(t *testing.T) { testRerankOrder(t, krn) })
+			t.Run("Instances", func(t *testing.T) { testInstances(t, krn) })
 		})
 	})
 
@@ -132,6 +148,20 @@ func cfgThinkToolChat() model.Config {
 	}
 }
 
+// cfgSmallContextChat keeps the context window small so a generation can
+// fill it in a reasonable time.
+func cfgSmallContextChat() model.Config {
+	return model.Config{
+		ModelFiles:    mpThinkToolChat.ModelFiles,
+		ContextWindow: 1024,
+		NBatch:        1024,
+		NUBatch:       256,
+		CacheTypeK:    model.GGMLTypeF16,
+		CacheTypeV:    model.GGMLTypeF16,
+		NSeqMax:       1,
+	}
+}
+
 func cfgGPTChat() model.Config {
 	return model.Config{
 		ModelFiles:    mpGPTChat.ModelFiles,
@@ -165,6 +195,7 @@ func cfgEmbed() model.Config {
 		CacheTypeK:     model.GGMLTypeQ8_0,
 		CacheTypeV:     model.GGMLTypeQ8_0,
 		FlashAttention: model.FlashAttentionEnabled,
+		NSeqMax:        2,
 	}
 }
 
@@ -177,6 +208,7 @@ func cfgRerank() model.Config {
 		CacheTypeK:     model.GGMLTypeQ8_0,
 		CacheTypeV:     model.GGMLTypeQ8_0,
 		FlashAttention: model.FlashAttentionEnabled,
+		NSeqMax:        2,
 	}
 }
 
@@ -225,7 +225,13 @@ func (lib *Libs) Download(ctx context.Context, log Logger) (VersionTag, error) {
 
 // InstalledVersion retrieves the current version of llama.cpp installed.
 func (lib *Libs) InstalledVersion() (VersionTag, error) {
-	versionInfoPath := filepath.Join(lib.path, versionFile)
+	return ReadVersion(lib.path)
+}
+
+// ReadVersion retrieves the version of llama.cpp installed at the specified
+// libraries path.
+func ReadVersion(libPath string) (VersionTag, error) {
+	versionInfoPath := filepath.Join(libPath, versionFile)
 
 	d, err := os.ReadFile(versionInfoPath)
 	if err != nil {